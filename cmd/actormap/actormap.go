@@ -0,0 +1,150 @@
+package actormap
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	sourceToken    string
+	sourceHostname string
+	token          string
+	hostname       string
+	outputFile     string
+	repos          []string
+	ruleType       string
+	concurrency    int
+	debug          bool
+}
+
+func NewCmdActorMap() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken, authSourceToken string
+
+	actorMapCmd := &cobra.Command{
+		Use:   "actor-map [flags] <source-organization> <target-organization>",
+		Short: "Generate a starter bypass actor mapping file.",
+		Long:  "Generate a bypass actor mapping file listing every team, custom repository role and integration referenced by the source rulesets, with the best-guess match in the target organization.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(actorMapCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			validRuleTypes := map[string]struct{}{
+				"all":      {},
+				"repoOnly": {},
+				"orgOnly":  {},
+			}
+			if _, isValid := validRuleTypes[cmdFlags.ruleType]; !isValid {
+				return fmt.Errorf("invalid ruleType: %s. Valid values are 'all', 'repoOnly', or 'orgOnly'", cmdFlags.ruleType)
+			}
+
+			authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
+			restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
+			if err != nil {
+				return err
+			}
+
+			authSourceToken = utils.GetAuthToken(cmdFlags.sourceToken, cmdFlags.sourceHostname)
+			restSrcClient, gqlSrcClient, err := utils.InitializeClients(cmdFlags.sourceHostname, authSourceToken)
+			if err != nil {
+				return err
+			}
+
+			if len(cmdFlags.outputFile) == 0 {
+				cmdFlags.outputFile = fmt.Sprintf("%s-actor-map.csv", args[0])
+			}
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			s := utils.NewAPIGetter(gqlSrcClient, restSrcClient)
+			s.SetConcurrency(cmdFlags.concurrency)
+
+			return runCmdActorMap(args[0], args[1], &cmdFlags, s, g)
+		},
+	}
+	ruleDefault := "all"
+
+	actorMapCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub personal access token for the target organization (default "gh auth token")`)
+	actorMapCmd.PersistentFlags().StringVarP(&cmdFlags.sourceToken, "source-pat", "p", "", `GitHub personal access token for the source organization (default "gh auth token")`)
+	actorMapCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname of the target organization")
+	actorMapCmd.PersistentFlags().StringVarP(&cmdFlags.sourceHostname, "source-hostname", "", "github.com", "GitHub Enterprise Server hostname of the source organization")
+	actorMapCmd.Flags().StringVarP(&cmdFlags.outputFile, "output-file", "o", "", "Name of CSV or YAML file to write the actor map to (default \"<source-organization>-actor-map.csv\")")
+	actorMapCmd.Flags().StringSliceVarP(&cmdFlags.repos, "repos", "R", []string{}, "List of repositories names to gather bypass actors for separated by commas (i.e. repo1,repo2,repo3)")
+	actorMapCmd.PersistentFlags().StringVarP(&cmdFlags.ruleType, "ruleType", "r", ruleDefault, "Gather bypass actors for a specific application or all: {all|repoOnly|orgOnly}")
+	actorMapCmd.Flags().IntVarP(&cmdFlags.concurrency, "concurrency", "c", 1, "Number of concurrent requests used to fetch repositories and rulesets")
+	actorMapCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return actorMapCmd
+}
+
+func runCmdActorMap(sourceOrg string, targetOrg string, cmdFlags *cmdFlags, s *utils.APIGetter, g *utils.APIGetter) error {
+	zap.S().Infof("Gathering bypass actors from rulesets in %s", sourceOrg)
+
+	sourceOrgIDData, err := s.FetchOrgId(sourceOrg)
+	if err != nil {
+		zap.S().Errorf("Error raised in fetching org %s", sourceOrg)
+		return err
+	}
+	sourceOrgID := sourceOrgIDData.Organization.DatabaseID
+
+	sourceRulesets, err := s.GatherRulesets(sourceOrg, cmdFlags.repos, cmdFlags.ruleType)
+	if err != nil {
+		return err
+	}
+
+	var mappings []data.ActorMapping
+	seen := make(map[string]struct{})
+	for _, ruleset := range sourceRulesets {
+		for _, actor := range ruleset.BypassActors {
+			if actor.ActorType == "DeployKey" || actor.ActorID == nil {
+				continue
+			}
+			if _, ok := data.RolesMap[strconv.Itoa(*actor.ActorID)]; ok {
+				continue
+			}
+			key := fmt.Sprintf("%s|%d", actor.ActorType, *actor.ActorID)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			mapping := data.ActorMapping{
+				Type:     actor.ActorType,
+				SourceID: *actor.ActorID,
+				Match:    "none",
+			}
+			sourceName, err := s.SourceActorName(sourceOrg, sourceOrgID, actor)
+			if err != nil {
+				zap.S().Infof("Failed to get %s data for actor ID %d: %v", actor.ActorType, *actor.ActorID, err)
+				mapping.Source = strconv.Itoa(*actor.ActorID)
+				mappings = append(mappings, mapping)
+				continue
+			}
+			mapping.Source = sourceName
+			targetID, err := g.LookupActorID(targetOrg, actor.ActorType, sourceName)
+			if err != nil {
+				zap.S().Infof("No %s named %s found in %s", actor.ActorType, sourceName, targetOrg)
+			} else {
+				mapping.Target = sourceName
+				mapping.TargetID = targetID
+				mapping.Match = "name"
+			}
+			mappings = append(mappings, mapping)
+		}
+	}
+
+	err = utils.WriteActorMap(mappings, cmdFlags.outputFile)
+	if err != nil {
+		zap.S().Errorf("Error writing actor map: %v", err)
+		return err
+	}
+	zap.S().Infof("Wrote %d bypass actors to %s", len(mappings), cmdFlags.outputFile)
+	return nil
+}
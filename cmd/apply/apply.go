@@ -0,0 +1,69 @@
+package apply
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token    string
+	hostname string
+	debug    bool
+}
+
+func NewCmdApply() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	applyCmd := &cobra.Command{
+		Use:   "apply [flags] <plan-file>",
+		Short: "Apply a ruleset plan saved by create",
+		Long:  "Apply a ruleset plan saved by `create --plan-file`, sending each planned API request verbatim.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(applyCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
+			restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
+			if err != nil {
+				return err
+			}
+
+			return runCmdApply(args[0], utils.NewAPIGetter(gqlClient, restClient))
+		},
+	}
+
+	applyCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub personal access token for organization to write to (default "gh auth token")`)
+	applyCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	applyCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return applyCmd
+}
+
+func runCmdApply(planFile string, g *utils.APIGetter) error {
+	zap.S().Infof("Reading in plan file %s", planFile)
+	plan, err := utils.ReadPlanFromFile(planFile)
+	if err != nil {
+		zap.S().Errorf("Error arose reading plan file %s", planFile)
+		return err
+	}
+
+	zap.S().Infof("Applying plan of %d rulesets to %s", len(plan.Entries), plan.Owner)
+	errorRulesets := g.ApplyPlan(*plan, nil)
+	if len(errorRulesets) > 0 {
+		reportFileName := fmt.Sprintf("%s-ruleset-errors-%s.csv", plan.Owner, time.Now().Format("20060102150405"))
+		err := utils.WriteErrorRulesetsToCSV(errorRulesets, reportFileName)
+		if err != nil {
+			zap.S().Errorf("Error writing error rulesets to csv file: %v", err)
+		}
+	}
+	zap.S().Infof("Completed applying plan %s to org %s", planFile, plan.Owner)
+	return nil
+}
@@ -0,0 +1,259 @@
+package applytemplate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token        string
+	hostname     string
+	fileName     string
+	name         string
+	rulesetID    int
+	rulesetRepo  string
+	repos        []string
+	patterns     []string
+	topics       []string
+	visibilities []string
+	properties   []string
+	onConflict   string
+	dryRun       bool
+	planFile     string
+	concurrency  int
+	debug        bool
+}
+
+func NewCmdApplyTemplate() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	applyTemplateCmd := &cobra.Command{
+		Use:   "apply-template [flags] <organization>",
+		Short: "Apply a ruleset template to many repositories.",
+		Long:  "Create a single ruleset definition, from a file or an existing ruleset, as a repository ruleset on each repository selected by name, pattern, topic, visibility or custom property.",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(applyTemplateCmd *cobra.Command, args []string) error {
+			if len(cmdFlags.fileName) == 0 && cmdFlags.rulesetID == 0 {
+				return errors.New("a file or ruleset ID must be specified to use as the template")
+			} else if len(cmdFlags.fileName) > 0 && cmdFlags.rulesetID > 0 {
+				return errors.New("specify only one of `--from-file` or `--id`")
+			}
+			validConflictModes := map[string]struct{}{
+				"skip":    {},
+				"update":  {},
+				"replace": {},
+				"fail":    {},
+			}
+			if _, isValid := validConflictModes[cmdFlags.onConflict]; !isValid {
+				return fmt.Errorf("invalid on-conflict: %s. Valid values are 'skip', 'update', 'replace', or 'fail'", cmdFlags.onConflict)
+			}
+			return nil
+		},
+		RunE: func(applyTemplateCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
+			restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
+			if err != nil {
+				return err
+			}
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetConcurrency(cmdFlags.concurrency)
+			return runCmdApplyTemplate(args[0], &cmdFlags, g)
+		},
+	}
+	conflictDefault := "fail"
+
+	applyTemplateCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub personal access token for organization to write to (default "gh auth token")`)
+	applyTemplateCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	applyTemplateCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV, JSON or YAML file containing the ruleset template")
+	applyTemplateCmd.Flags().StringVarP(&cmdFlags.name, "name", "n", "", "Name of the ruleset to use as the template, when the file contains more than one")
+	applyTemplateCmd.Flags().IntVarP(&cmdFlags.rulesetID, "id", "", 0, "ID of an existing ruleset in the organization to use as the template")
+	applyTemplateCmd.Flags().StringVarP(&cmdFlags.rulesetRepo, "ruleset-repo", "", "", "Repository the ruleset specified by --id belongs to, for repository level rulesets")
+	applyTemplateCmd.Flags().StringSliceVarP(&cmdFlags.repos, "repos", "R", []string{}, "List of repositories names to apply the template to separated by commas (i.e. repo1,repo2,repo3)")
+	applyTemplateCmd.Flags().StringSliceVarP(&cmdFlags.patterns, "repo-pattern", "", []string{}, "Repository name patterns, such as api-*, to apply the template to separated by commas")
+	applyTemplateCmd.Flags().StringSliceVarP(&cmdFlags.topics, "topic", "", []string{}, "Apply the template to repositories with any of these topics separated by commas")
+	applyTemplateCmd.Flags().StringSliceVarP(&cmdFlags.visibilities, "visibility", "", []string{}, "Apply the template to repositories with any of these visibilities separated by commas: {public|private|internal}")
+	applyTemplateCmd.Flags().StringArrayVarP(&cmdFlags.properties, "property", "", []string{}, "Apply the template to repositories with a custom property value, as name=value (can be repeated)")
+	applyTemplateCmd.Flags().StringVarP(&cmdFlags.onConflict, "on-conflict", "", conflictDefault, "Action to take when a ruleset with the same name already exists: {skip|update|replace|fail}")
+	applyTemplateCmd.Flags().BoolVarP(&cmdFlags.dryRun, "dry-run", "", false, "Perform all lookups and print the plan of API calls without creating rulesets")
+	applyTemplateCmd.Flags().StringVarP(&cmdFlags.planFile, "plan-file", "", "", "Name of file to save the plan to, which can later be applied with the apply command")
+	applyTemplateCmd.Flags().IntVarP(&cmdFlags.concurrency, "concurrency", "c", 1, "Number of concurrent requests used to fetch repositories")
+	applyTemplateCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return applyTemplateCmd
+}
+
+func runCmdApplyTemplate(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	selector := utils.RepoSelector{
+		Patterns:     cmdFlags.patterns,
+		Topics:       cmdFlags.topics,
+		Visibilities: cmdFlags.visibilities,
+	}
+	if len(cmdFlags.properties) > 0 {
+		selector.Properties = make(map[string][]string)
+		for _, property := range cmdFlags.properties {
+			name, value, found := strings.Cut(property, "=")
+			if !found {
+				return fmt.Errorf("invalid property %s, expected name=value", property)
+			}
+			selector.Properties[name] = append(selector.Properties[name], value)
+		}
+	}
+
+	template, err := readTemplate(owner, cmdFlags, g)
+	if err != nil {
+		return err
+	}
+	createRuleset, err := utils.RepoTemplateRuleset(template)
+	if err != nil {
+		zap.S().Errorf("Error creating ruleset rules data: %v", err)
+		return err
+	}
+
+	zap.S().Infof("Gathering repositories in %s to apply ruleset %s to", owner, template.Name)
+	repos, err := g.GatherRepositories(owner, cmdFlags.repos)
+	if err != nil {
+		return err
+	}
+	var propertyValues map[string]map[string][]string
+	if len(selector.Properties) > 0 {
+		propertyValues, err = g.GetOrgPropertyValues(owner)
+		if err != nil {
+			zap.S().Errorf("Error raised in getting custom property values for %s", owner)
+			return err
+		}
+	}
+	repos = utils.SelectRepositories(repos, selector, propertyValues)
+	if len(repos) == 0 {
+		return errors.New("no repositories matched the repository selectors")
+	}
+	zap.S().Infof("Applying ruleset %s to %d repositories", template.Name, len(repos))
+
+	plan := data.RulesetPlan{
+		Owner:     owner,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	existingRulesets := make(map[string]map[string]int)
+	template.SourceType = "Repository"
+	for _, repo := range repos {
+		repoRuleset, err := utils.ApplyTemplateVariables(createRuleset, utils.TemplateVariables(owner, repo))
+		if err != nil {
+			zap.S().Errorf("Error applying template to repository %s: %v", repo.Name, err)
+			return err
+		}
+		target := fmt.Sprintf("%s/%s", owner, repo.Name)
+		entry, err := utils.NewPlanEntry(template, repoRuleset, target, target)
+		if err != nil {
+			zap.S().Errorf("Error marshaling ruleset: %v", err)
+			continue
+		}
+		entry = g.CheckExistingRuleset(entry, existingRulesets, cmdFlags.onConflict)
+		plan.Entries = append(plan.Entries, entry)
+	}
+
+	if len(cmdFlags.planFile) > 0 {
+		err := utils.WritePlanToFile(plan, cmdFlags.planFile)
+		if err != nil {
+			zap.S().Errorf("Error writing plan to file: %v", err)
+			return err
+		}
+		zap.S().Infof("Saved plan for %d rulesets to %s", len(plan.Entries), cmdFlags.planFile)
+	}
+	if cmdFlags.dryRun {
+		zap.S().Infof("Dry run complete, no rulesets were created in org %s", owner)
+		return utils.WritePlanSummary(plan, os.Stdout)
+	}
+
+	errorRulesets := g.ApplyPlan(plan, nil)
+	if len(errorRulesets) > 0 {
+		reportFileName := fmt.Sprintf("%s-ruleset-errors-%s.csv", owner, time.Now().Format("20060102150405"))
+		err := utils.WriteErrorRulesetsToCSV(errorRulesets, reportFileName)
+		if err != nil {
+			zap.S().Errorf("Error writing error rulesets to csv file: %v", err)
+		}
+	}
+	zap.S().Infof("Completed applying ruleset %s to repositories in org %s", template.Name, owner)
+	return nil
+}
+
+func readTemplate(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) (data.RepoRuleset, error) {
+	if cmdFlags.rulesetID > 0 {
+		var response []byte
+		var err error
+		if len(cmdFlags.rulesetRepo) > 0 {
+			zap.S().Debugf("Getting ruleset %d for repository %s/%s", cmdFlags.rulesetID, owner, cmdFlags.rulesetRepo)
+			response, err = g.GetRepoLevelRuleset(owner, cmdFlags.rulesetRepo, cmdFlags.rulesetID)
+		} else {
+			zap.S().Debugf("Getting ruleset %d for organization %s", cmdFlags.rulesetID, owner)
+			response, err = g.GetOrgLevelRuleset(owner, cmdFlags.rulesetID)
+		}
+		if err != nil {
+			zap.S().Errorf("Error raised in getting ruleset %d", cmdFlags.rulesetID)
+			return data.RepoRuleset{}, err
+		}
+		var ruleset data.RepoRuleset
+		if err := json.Unmarshal(response, &ruleset); err != nil {
+			return data.RepoRuleset{}, err
+		}
+		return ruleset, nil
+	}
+
+	var rulesets []data.RepoRuleset
+	var err error
+	zap.S().Infof("Reading in file %s to identify the ruleset template", cmdFlags.fileName)
+	if utils.IsRulesetFileFormat(cmdFlags.fileName, ".json") {
+		rulesets, err = utils.ReadRulesetsFromJSON(owner, cmdFlags.fileName)
+	} else if ext := filepath.Ext(cmdFlags.fileName); ext == ".yaml" || ext == ".yml" {
+		rulesets, err = g.ReadRulesetsFromYAML(owner, cmdFlags.fileName, ext)
+	} else {
+		var f *os.File
+		f, err = os.Open(cmdFlags.fileName)
+		if err != nil {
+			zap.S().Errorf("Error arose opening rulesets csv file")
+			return data.RepoRuleset{}, err
+		}
+		defer f.Close()
+		var fileData [][]string
+		fileData, err = csv.NewReader(f).ReadAll()
+		if err == nil {
+			rulesets, err = g.CreateRepoRulesetsData(owner, fileData)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", cmdFlags.fileName, err)
+			}
+		}
+	}
+	if err != nil {
+		zap.S().Errorf("Error arose reading rulesets from %s", cmdFlags.fileName)
+		return data.RepoRuleset{}, err
+	}
+
+	if len(cmdFlags.name) > 0 {
+		for _, ruleset := range rulesets {
+			if ruleset.Name == cmdFlags.name {
+				return ruleset, nil
+			}
+		}
+		return data.RepoRuleset{}, fmt.Errorf("ruleset %s was not found in %s", cmdFlags.name, cmdFlags.fileName)
+	}
+	if len(rulesets) != 1 {
+		return data.RepoRuleset{}, fmt.Errorf("%s contains %d rulesets, use --name to choose the template", cmdFlags.fileName, len(rulesets))
+	}
+	return rulesets[0], nil
+}
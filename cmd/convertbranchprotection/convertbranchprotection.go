@@ -0,0 +1,241 @@
+package convertbranchprotection
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token       string
+	hostname    string
+	outputFile  string
+	create      bool
+	onConflict  string
+	enforcement string
+	concurrency int
+	dryRun      bool
+	planFile    string
+	resume      string
+	debug       bool
+}
+
+func NewCmdConvertBranchProtection() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	convertCmd := &cobra.Command{
+		Use:   "convert-branch-protection [flags] <organization> [repo ...]",
+		Short: "Convert classic branch protection rules into rulesets.",
+		Long:  "Convert classic branch protection rules for a list of repositories and/or organization into repository rulesets, writing them to a csv file or creating them directly.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(convertCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			validConflictModes := map[string]struct{}{
+				"skip":    {},
+				"update":  {},
+				"replace": {},
+				"fail":    {},
+			}
+			if _, isValid := validConflictModes[cmdFlags.onConflict]; !isValid {
+				return fmt.Errorf("invalid on-conflict: %s. Valid values are 'skip', 'update', 'replace', or 'fail'", cmdFlags.onConflict)
+			}
+			validEnforcements := map[string]struct{}{
+				"active":   {},
+				"evaluate": {},
+				"disabled": {},
+			}
+			if _, isValid := validEnforcements[cmdFlags.enforcement]; !isValid {
+				return fmt.Errorf("invalid enforcement: %s. Valid values are 'active', 'evaluate', or 'disabled'", cmdFlags.enforcement)
+			}
+			if !cmdFlags.create && (cmdFlags.dryRun || len(cmdFlags.planFile) > 0 || len(cmdFlags.resume) > 0) {
+				return fmt.Errorf("--dry-run, --plan-file and --resume can only be used with --create")
+			}
+
+			authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
+			restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
+			if err != nil {
+				return err
+			}
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetConcurrency(cmdFlags.concurrency)
+			return runCmdConvertBranchProtection(args[0], args[1:], &cmdFlags, g)
+		},
+	}
+
+	reportFileDefault := fmt.Sprintf("branch-protection-rulesets-%s.csv", time.Now().Format("20060102150405"))
+	conflictDefault := "fail"
+	enforcementDefault := "active"
+
+	convertCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	convertCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	convertCmd.Flags().StringVarP(&cmdFlags.outputFile, "output-file", "o", reportFileDefault, "Name of csv file to write the converted rulesets to")
+	convertCmd.Flags().BoolVarP(&cmdFlags.create, "create", "", false, "Create the converted rulesets instead of writing them to a file")
+	convertCmd.Flags().StringVarP(&cmdFlags.onConflict, "on-conflict", "", conflictDefault, "Action to take when a ruleset with the same name already exists: {skip|update|replace|fail}")
+	convertCmd.Flags().StringVarP(&cmdFlags.enforcement, "enforcement", "", enforcementDefault, "Enforcement of the converted rulesets: {active|evaluate|disabled}")
+	convertCmd.Flags().IntVarP(&cmdFlags.concurrency, "concurrency", "c", 1, "Number of concurrent requests used to fetch repositories and branch protection rules")
+	convertCmd.Flags().BoolVarP(&cmdFlags.dryRun, "dry-run", "", false, "With --create, perform all lookups and print the plan of API calls without creating rulesets")
+	convertCmd.Flags().StringVarP(&cmdFlags.planFile, "plan-file", "", "", "With --create, name of file to save the plan to, which can later be applied with the apply command")
+	convertCmd.Flags().StringVarP(&cmdFlags.resume, "resume", "", "", "With --create, state file from a previous run to only create rulesets that were not completed")
+	convertCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return convertCmd
+}
+
+func runCmdConvertBranchProtection(owner string, repos []string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	zap.S().Infof("Gathering repositories and branch protection rules for %s", owner)
+	repoInfos, err := g.GatherRepositories(owner, repos)
+	if err != nil {
+		zap.S().Error("Error raised in gathering repositories")
+		return err
+	}
+	protectionRules, err := g.FetchBranchProtectionRules(owner, repoInfos)
+	if err != nil {
+		zap.S().Error("Error raised in fetching branch protection rules")
+		return err
+	}
+
+	var rulesets []data.RepoRuleset
+	var unsupported []data.UnsupportedSetting
+	for _, protection := range protectionRules {
+		ruleset, settings := utils.ConvertBranchProtection(owner, protection.RepoName, protection.Rule, cmdFlags.enforcement)
+		unsupported = append(unsupported, settings...)
+		if len(ruleset.Rules) == 0 {
+			zap.S().Infof("Branch protection for %s in %s has no equivalent ruleset rules", protection.Rule.Pattern, protection.RepoName)
+			unsupported = append(unsupported, data.UnsupportedSetting{
+				Repository: ruleset.Source,
+				Pattern:    protection.Rule.Pattern,
+				Setting:    "rules",
+				Detail:     "No settings have an equivalent ruleset rule, so no ruleset was converted",
+			})
+			continue
+		}
+		for _, setting := range settings {
+			zap.S().Infof("Branch protection for %s in %s: %s: %s", setting.Pattern, protection.RepoName, setting.Setting, setting.Detail)
+		}
+		rulesets = append(rulesets, ruleset)
+	}
+	zap.S().Infof("Converted %d of %d branch protection rules", len(rulesets), len(protectionRules))
+
+	if len(unsupported) > 0 {
+		reportFileName := fmt.Sprintf("%s-branch-protection-unsupported-%s.csv", owner, time.Now().Format("20060102150405"))
+		err := utils.WriteUnsupportedSettingsToCSV(unsupported, reportFileName)
+		if err != nil {
+			zap.S().Errorf("Error writing unsupported settings to csv file: %v", err)
+		} else {
+			zap.S().Infof("Wrote %d settings without an exact ruleset equivalent to %s", len(unsupported), reportFileName)
+		}
+	}
+
+	if !cmdFlags.create {
+		return writeRulesetsCSV(owner, rulesets, cmdFlags.outputFile, g)
+	}
+
+	var state *utils.StateFile
+	if len(cmdFlags.resume) > 0 {
+		zap.S().Infof("Resuming from %s", cmdFlags.resume)
+		state, err = utils.ReadStateFile(cmdFlags.resume)
+		if err != nil {
+			zap.S().Errorf("Error arose reading resume file %s", cmdFlags.resume)
+			return err
+		}
+	}
+
+	plan := data.RulesetPlan{
+		Owner:     owner,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	existingRulesets := make(map[string]map[string]int)
+	for _, ruleset := range rulesets {
+		createRuleset, err := utils.ProcessRulesets(ruleset)
+		if err != nil {
+			zap.S().Errorf("Error creating ruleset rules data: %v", err)
+			continue
+		}
+		createRuleset.Conditions = utils.CleanConditions(createRuleset.Conditions)
+		entry, err := utils.NewPlanEntry(ruleset, createRuleset, ruleset.Source, ruleset.Source)
+		if err != nil {
+			zap.S().Errorf("Error marshaling ruleset: %v", err)
+			continue
+		}
+		if state != nil && state.Completed(entry) {
+			zap.S().Infof("Skipping ruleset %s for %s as it was completed in a previous run", entry.RulesetName, entry.Target)
+			continue
+		}
+		plan.Entries = append(plan.Entries, g.CheckExistingRuleset(entry, existingRulesets, cmdFlags.onConflict))
+	}
+
+	if len(cmdFlags.planFile) > 0 {
+		err := utils.WritePlanToFile(plan, cmdFlags.planFile)
+		if err != nil {
+			zap.S().Errorf("Error writing plan to file: %v", err)
+			return err
+		}
+		zap.S().Infof("Saved plan for %d rulesets to %s", len(plan.Entries), cmdFlags.planFile)
+	}
+	if cmdFlags.dryRun {
+		zap.S().Infof("Dry run complete, no rulesets were created in org %s", owner)
+		return utils.WritePlanSummary(plan, os.Stdout)
+	}
+
+	if state == nil {
+		state = utils.NewStateFile(fmt.Sprintf("%s-ruleset-state-%s.json", owner, time.Now().Format("20060102150405")), owner)
+	}
+	if err := state.Track(plan.Entries); err != nil {
+		zap.S().Errorf("Error writing state file: %v", err)
+		return err
+	}
+	zap.S().Infof("Recording progress to state file %s", state.Path())
+
+	errorRulesets := g.ApplyPlan(plan, state)
+	if len(errorRulesets) > 0 {
+		reportFileName := fmt.Sprintf("%s-ruleset-errors-%s.csv", owner, time.Now().Format("20060102150405"))
+		err := utils.WriteErrorRulesetsToCSV(errorRulesets, reportFileName)
+		if err != nil {
+			zap.S().Errorf("Error writing error rulesets to csv file: %v", err)
+		}
+	}
+	zap.S().Infof("Completed converting branch protection rules in org %s", owner)
+	return nil
+}
+
+func writeRulesetsCSV(owner string, rulesets []data.RepoRuleset, fileName string, g *utils.APIGetter) error {
+	orgIDData, err := g.FetchOrgId(owner)
+	if err != nil {
+		zap.S().Error("Error raised in fetching org")
+		return err
+	}
+
+	reportFile, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer reportFile.Close()
+
+	csvWriter := csv.NewWriter(reportFile)
+	err = csvWriter.Write(data.RulesetCSVHeaders)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(rulesets))
+	for i, ruleset := range rulesets {
+		rows[i] = g.RulesetToCSVRow(ruleset, owner, orgIDData.Organization.DatabaseID)
+	}
+	err = csvWriter.WriteAll(rows)
+	if err != nil {
+		return err
+	}
+	zap.S().Infof("Wrote %d converted rulesets to %s", len(rulesets), fileName)
+	return nil
+}
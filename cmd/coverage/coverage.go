@@ -0,0 +1,97 @@
+package coverage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token       string
+	hostname    string
+	outputFile  string
+	concurrency int
+	debug       bool
+}
+
+func NewCmdCoverage() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	coverageCmd := &cobra.Command{
+		Use:   "coverage [flags] <organization> [repo ...]",
+		Short: "Report which rulesets protect each repository.",
+		Long:  "Report the organization and repository rulesets that apply to the default branch of each repository in an organization, highlighting repositories without active protection.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(coverageCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
+			restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
+			if err != nil {
+				return err
+			}
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetConcurrency(cmdFlags.concurrency)
+			return runCmdCoverage(args[0], args[1:], &cmdFlags, g)
+		},
+	}
+
+	reportFileDefault := fmt.Sprintf("coverage-%s.csv", time.Now().Format("20060102150405"))
+
+	coverageCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	coverageCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	coverageCmd.Flags().StringVarP(&cmdFlags.outputFile, "output-file", "o", reportFileDefault, "Name of csv file to write the coverage report to")
+	coverageCmd.Flags().IntVarP(&cmdFlags.concurrency, "concurrency", "c", 1, "Number of concurrent requests used to fetch repositories and rulesets")
+	coverageCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return coverageCmd
+}
+
+func runCmdCoverage(owner string, repos []string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	zap.S().Infof("Gathering repositories in %s to evaluate ruleset coverage for", owner)
+	repoInfos, err := g.GatherRepositories(owner, repos)
+	if err != nil {
+		zap.S().Error("Error raised in gathering repositories")
+		return err
+	}
+	rulesets, err := g.GatherRepoInfoRulesets(owner, repoInfos, "all")
+	if err != nil {
+		zap.S().Error("Error raised in gathering rulesets")
+		return err
+	}
+	propertyValues, err := g.GetOrgPropertyValues(owner)
+	if err != nil {
+		zap.S().Warnf("Unable to get custom property values for %s, rulesets with repository property conditions will be reported as unknown: %v", owner, err)
+		propertyValues = nil
+	}
+
+	coverage := utils.BuildRepoCoverage(repoInfos, rulesets, propertyValues)
+	var unprotected []string
+	for _, repo := range coverage {
+		if repo.Protection != "active" {
+			unprotected = append(unprotected, repo.Repository)
+			if len(repo.DefaultBranch) == 0 {
+				zap.S().Warnf("Repository %s has no default branch", repo.Repository)
+			} else {
+				zap.S().Warnf("Repository %s has no active protection on %s (%s)", repo.Repository, repo.DefaultBranch, repo.Protection)
+			}
+		}
+	}
+
+	err = utils.WriteCoverageToCSV(coverage, cmdFlags.outputFile)
+	if err != nil {
+		zap.S().Errorf("Error writing coverage report to csv file: %v", err)
+		return err
+	}
+	zap.S().Infof("%d of %d repositories in %s have no active ruleset protection on their default branch", len(unprotected), len(coverage), owner)
+	zap.S().Infof("Wrote ruleset coverage for %d repositories to %s", len(coverage), cmdFlags.outputFile)
+	return nil
+}
@@ -1,12 +1,11 @@
 package create
 
 import (
-	"bytes"
 	"encoding/csv"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -14,7 +13,6 @@ import (
 	"github.com/katiem0/gh-migrate-rulesets/internal/log"
 	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
 
-	// Add this line
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -26,8 +24,18 @@ type cmdFlags struct {
 	token          string
 	hostname       string
 	fileName       string
+	fromDir        string
 	repos          []string
 	ruleType       string
+	onConflict     string
+	dryRun         bool
+	planFile       string
+	resume         string
+	actorMap       string
+	repoMap        string
+	createEnvs     bool
+	enterprise     string
+	concurrency    int
 	debug          bool
 }
 
@@ -39,12 +47,30 @@ func NewCmdCreate() *cobra.Command {
 		Use:   "create [flags] <organization>",
 		Short: "Create repository rulesets",
 		Long:  "Create repository rulesets at the repo and/or org level from a file or list.",
-		Args:  cobra.MinimumNArgs(1),
+		Args:  cobra.ArbitraryArgs,
 		PreRunE: func(createCmd *cobra.Command, args []string) error {
-			if len(cmdFlags.fileName) == 0 && len(cmdFlags.sourceOrg) == 0 {
-				return errors.New("a file or source organization must be specified where rulesets will be created from")
-			} else if len(cmdFlags.fileName) > 0 && len(cmdFlags.sourceOrg) > 0 {
-				return errors.New("specify only one of `--source-organization` or `from-file`")
+			if len(args) == 0 && (len(cmdFlags.enterprise) == 0 || len(cmdFlags.sourceOrg) > 0) {
+				return errors.New("an organization must be specified where rulesets will be created")
+			}
+			sources := 0
+			for _, source := range []string{cmdFlags.fileName, cmdFlags.fromDir, cmdFlags.sourceOrg} {
+				if len(source) > 0 {
+					sources++
+				}
+			}
+			if sources == 0 {
+				return errors.New("a file, directory or source organization must be specified where rulesets will be created from")
+			} else if sources > 1 {
+				return errors.New("specify only one of `--source-organization`, `--from-file` or `--from-dir`")
+			}
+			validConflictModes := map[string]struct{}{
+				"skip":    {},
+				"update":  {},
+				"replace": {},
+				"fail":    {},
+			}
+			if _, isValid := validConflictModes[cmdFlags.onConflict]; !isValid {
+				return fmt.Errorf("invalid on-conflict: %s. Valid values are 'skip', 'update', 'replace', or 'fail'", cmdFlags.onConflict)
 			}
 			return nil
 		},
@@ -64,12 +90,37 @@ func NewCmdCreate() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			owner := args[0]
+			var owner string
+			if len(args) > 0 {
+				owner = args[0]
+			}
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetConcurrency(cmdFlags.concurrency)
+			s := utils.NewAPIGetter(gqlSrcClient, restSrcClient)
+			s.SetConcurrency(cmdFlags.concurrency)
+			if len(cmdFlags.actorMap) > 0 {
+				actorMap, err := utils.ReadActorMap(cmdFlags.actorMap)
+				if err != nil {
+					zap.S().Errorf("Error arose reading actor map %s", cmdFlags.actorMap)
+					return err
+				}
+				g.SetActorMap(actorMap)
+			}
+			if len(cmdFlags.repoMap) > 0 {
+				repoMap, err := utils.ReadRepoMap(cmdFlags.repoMap)
+				if err != nil {
+					zap.S().Errorf("Error arose reading repo map %s", cmdFlags.repoMap)
+					return err
+				}
+				g.SetRepoMap(repoMap)
+			}
 
-			return runCmdCreate(owner, &cmdFlags, utils.NewAPIGetter(gqlClient, restClient), utils.NewAPIGetter(gqlSrcClient, restSrcClient))
+			return runCmdCreate(owner, &cmdFlags, g, s)
 		},
 	}
 	ruleDefault := "all"
+	conflictDefault := "fail"
 
 	// Configure flags for command
 	createCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub personal access token for organization to write to (default "gh auth token")`)
@@ -77,9 +128,19 @@ func NewCmdCreate() *cobra.Command {
 	createCmd.PersistentFlags().StringVarP(&cmdFlags.sourceOrg, "source-org", "s", "", `Name of the Source Organization to copy rulesets from`)
 	createCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
 	createCmd.PersistentFlags().StringVarP(&cmdFlags.sourceHostname, "source-hostname", "", "github.com", "GitHub Enterprise Server hostname where rulesets are copied from")
-	createCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV file to create rulesets from")
+	createCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV, JSON or YAML file, or directory of JSON files, to create rulesets from")
+	createCmd.Flags().StringVarP(&cmdFlags.fromDir, "from-dir", "", "", "Directory of YAML or JSON ruleset files laid out as org/<name>.yaml and repos/<repo>/<name>.yaml to create rulesets from")
 	createCmd.Flags().StringSliceVarP(&cmdFlags.repos, "repos", "R", []string{}, "List of repositories names to recreate rulesets for separated by commas (i.e. repo1,repo2,repo3)")
 	createCmd.PersistentFlags().StringVarP(&cmdFlags.ruleType, "ruleType", "r", ruleDefault, "List rulesets for a specific application or all: {all|repoOnly|orgOnly}")
+	createCmd.Flags().StringVarP(&cmdFlags.onConflict, "on-conflict", "", conflictDefault, "Action to take when a ruleset with the same name already exists: {skip|update|replace|fail}")
+	createCmd.Flags().BoolVarP(&cmdFlags.dryRun, "dry-run", "", false, "Perform all lookups and print the plan of API calls without creating rulesets")
+	createCmd.Flags().StringVarP(&cmdFlags.planFile, "plan-file", "", "", "Name of file to save the plan to, which can later be applied with the apply command")
+	createCmd.Flags().StringVarP(&cmdFlags.resume, "resume", "", "", "State file from a previous run, or error csv file, to only create rulesets that were not completed")
+	createCmd.Flags().StringVarP(&cmdFlags.actorMap, "actor-map", "", "", "Path and Name of CSV or YAML file mapping source bypass actors to target actors, used before matching by name")
+	createCmd.Flags().StringVarP(&cmdFlags.repoMap, "repo-map", "", "", "Path and Name of CSV or YAML file mapping source repositories to target repositories, by name or regular expression")
+	createCmd.Flags().BoolVarP(&cmdFlags.createEnvs, "create-missing-environments", "", false, "Create deployment environments required by repository rulesets that do not exist in the target repository")
+	createCmd.Flags().StringVarP(&cmdFlags.enterprise, "enterprise", "", "", "Slug of the enterprise to create Enterprise level rulesets in")
+	createCmd.Flags().IntVarP(&cmdFlags.concurrency, "concurrency", "c", 1, "Number of concurrent requests used to fetch repositories and rulesets")
 	createCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
 
 	return createCmd
@@ -87,31 +148,103 @@ func NewCmdCreate() *cobra.Command {
 
 func runCmdCreate(owner string, cmdFlags *cmdFlags, g *utils.APIGetter, s *utils.APIGetter) error {
 	repos := cmdFlags.repos
-	var errorValidation string
 	var rulesetData [][]string
 	sourceOrg := cmdFlags.sourceOrg
 	var sourceOrgID int
 	var importRepoRulesetsList []data.RepoRuleset
-	var errorRulesets []data.ErrorRulesets
+	existingRulesets := make(map[string]map[string]int)
+	existingEnvironments := make(map[string]map[string]struct{})
+	plannedEnvironments := make(map[string]map[string]struct{})
+	reportName := owner
+	if len(reportName) == 0 {
+		reportName = cmdFlags.enterprise
+	}
+	plan := data.RulesetPlan{
+		Owner:     reportName,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
 
-	zap.S().Infof("Reading in file %s to identify repository rulesets", cmdFlags.fileName)
-	if len(cmdFlags.fileName) > 0 {
-		f, err := os.Open(cmdFlags.fileName)
-		zap.S().Debugf("Opening up file %s", cmdFlags.fileName)
-		if err != nil {
-			zap.S().Errorf("Error arose opening branch protection policies csv file")
-			return err
+	var state *utils.StateFile
+	var retryRulesets map[string]struct{}
+	if len(cmdFlags.resume) > 0 {
+		var err error
+		zap.S().Infof("Resuming from %s", cmdFlags.resume)
+		if filepath.Ext(cmdFlags.resume) == ".csv" {
+			retryRulesets, err = utils.ReadErrorRulesetsFromCSV(cmdFlags.resume)
+		} else {
+			state, err = utils.ReadStateFile(cmdFlags.resume)
 		}
-		defer f.Close()
-		csvReader := csv.NewReader(f)
-		rulesetData, err = csvReader.ReadAll()
-		zap.S().Debugf("Reading in all lines from csv file")
 		if err != nil {
-			zap.S().Errorf("Error arose reading assignments from csv file")
+			zap.S().Errorf("Error arose reading resume file %s", cmdFlags.resume)
 			return err
 		}
-		importRepoRulesetsList = g.CreateRepoRulesetsData(owner, rulesetData)
+	}
+
+	if len(cmdFlags.fileName) > 0 || len(cmdFlags.fromDir) > 0 {
+		if len(cmdFlags.fromDir) > 0 {
+			zap.S().Infof("Reading in directory %s to identify repository rulesets", cmdFlags.fromDir)
+			var err error
+			importRepoRulesetsList, err = g.ReadRulesetsFromDir(owner, cmdFlags.fromDir, s)
+			if err != nil {
+				zap.S().Errorf("Error arose reading rulesets from directory")
+				return err
+			}
+		} else if utils.IsRulesetFileFormat(cmdFlags.fileName, ".json") {
+			zap.S().Infof("Reading in file %s to identify repository rulesets", cmdFlags.fileName)
+			var err error
+			importRepoRulesetsList, err = g.ImportRulesetsFromJSON(owner, cmdFlags.fileName, s)
+			if err != nil {
+				zap.S().Errorf("Error arose reading rulesets from json file")
+				return err
+			}
+		} else if ext := filepath.Ext(cmdFlags.fileName); ext == ".yaml" || ext == ".yml" {
+			zap.S().Infof("Reading in file %s to identify repository rulesets", cmdFlags.fileName)
+			var err error
+			importRepoRulesetsList, err = g.ReadRulesetsFromYAML(owner, cmdFlags.fileName, ext)
+			if err != nil {
+				zap.S().Errorf("Error arose reading rulesets from yaml file")
+				return err
+			}
+		} else {
+			zap.S().Infof("Reading in file %s to identify repository rulesets", cmdFlags.fileName)
+			f, err := os.Open(cmdFlags.fileName)
+			zap.S().Debugf("Opening up file %s", cmdFlags.fileName)
+			if err != nil {
+				zap.S().Errorf("Error arose opening branch protection policies csv file")
+				return err
+			}
+			defer f.Close()
+			csvReader := csv.NewReader(f)
+			rulesetData, err = csvReader.ReadAll()
+			zap.S().Debugf("Reading in all lines from csv file")
+			if err != nil {
+				zap.S().Errorf("Error arose reading assignments from csv file")
+				return err
+			}
+			importRepoRulesetsList, err = g.CreateRepoRulesetsData(owner, rulesetData)
+			if err != nil {
+				zap.S().Errorf("Error arose reading rulesets from csv file")
+				return fmt.Errorf("%s: %w", cmdFlags.fileName, err)
+			}
+		}
 		for _, ruleset := range importRepoRulesetsList {
+			switch ruleset.SourceType {
+			case "Enterprise":
+				if len(cmdFlags.enterprise) == 0 {
+					zap.S().Infof("Skipping enterprise ruleset %s as no enterprise was specified", ruleset.Name)
+					continue
+				}
+				ruleset.Source = cmdFlags.enterprise
+			case "Organization", "Repository":
+				if len(owner) == 0 {
+					zap.S().Infof("Skipping %s ruleset %s as no organization was specified", strings.ToLower(ruleset.SourceType), ruleset.Name)
+					continue
+				}
+				ruleset = g.MapRulesetRepositories(ruleset)
+			default:
+				zap.S().Infof("Skipping ruleset %s with unknown ruleset level %s", ruleset.Name, ruleset.SourceType)
+				continue
+			}
 
 			createRuleset, err := utils.ProcessRulesets(ruleset)
 			if err != nil {
@@ -123,52 +256,27 @@ func runCmdCreate(owner string, cmdFlags *cmdFlags, g *utils.APIGetter, s *utils
 			} else {
 				createRuleset.Conditions = utils.CleanConditions(createRuleset.Conditions)
 			}
-			createRulesetJSON, err := json.Marshal(createRuleset)
+			target := owner
+			if ruleset.SourceType == "Repository" || ruleset.SourceType == "Enterprise" {
+				target = ruleset.Source
+			}
+			entry, err := utils.NewPlanEntry(ruleset, createRuleset, target, target)
 			if err != nil {
 				zap.S().Errorf("Error marshaling ruleset: %v", err)
 				continue
 			}
-			reader := bytes.NewReader(createRulesetJSON)
-			if ruleset.SourceType == "Organization" {
-				zap.S().Debugf("Creating rulesets under %s", owner)
-				err = g.CreateOrgLevelRuleset(owner, reader)
-				if err != nil {
-					if strings.Contains(err.Error(), "\n") {
-						errorValidation = strings.Split(err.Error(), "\n")[1]
-					} else {
-						errorValidation = err.Error()
-					}
-					errorRulesets = append(errorRulesets, data.ErrorRulesets{Source: owner, RulesetName: createRuleset.Name, Error: errorValidation})
-					zap.S().Infof("Error creating ruleset %s for %s: %s", ruleset.Source, createRuleset.Name, errorValidation)
-					continue
-				}
-				zap.S().Infof("Successfully create repository ruleset %s for %s", ruleset.Name, owner)
-			} else if ruleset.SourceType == "Repository" {
-				exists := g.RepoExists(ruleset.Source)
-				if !exists {
-					zap.S().Debugf("Repository %s does not exist", ruleset.Source)
-					errorRulesets = append(errorRulesets, data.ErrorRulesets{Source: ruleset.Source, RulesetName: createRuleset.Name, Error: "Repository does not exist"})
-					zap.S().Infof("Error creating ruleset %s for %s: %s", ruleset.Source, createRuleset.Name, "Repository does not exist")
-					continue
-				} else {
-					{
-						zap.S().Debugf("Creating rulesets under %s", ruleset.Source)
-						err = g.CreateRepoLevelRuleset(ruleset.Source, reader)
-						if err != nil {
-							if strings.Contains(err.Error(), "\n") {
-								errorValidation = strings.Split(err.Error(), "\n")[1]
-							} else {
-								errorValidation = err.Error()
-							}
-							errorRulesets = append(errorRulesets, data.ErrorRulesets{Source: ruleset.Source, RulesetName: createRuleset.Name, Error: errorValidation})
-							zap.S().Infof("Error creating ruleset %s for %s: %s", ruleset.Source, createRuleset.Name, errorValidation)
-
-							continue
-						}
-					}
-				}
-				zap.S().Infof("Successfully create repository ruleset %s for %s", ruleset.Name, ruleset.Source)
+			if resumeCompleted(entry, state, retryRulesets) {
+				continue
+			}
+			if ruleset.SourceType == "Repository" && !g.RepoExists(ruleset.Source) {
+				zap.S().Debugf("Repository %s does not exist", ruleset.Source)
+				entry.Requests = nil
+				entry.Error = "Repository does not exist"
+			} else {
+				entry = g.CheckExistingRuleset(entry, existingRulesets, cmdFlags.onConflict)
+				entry = g.CheckDeploymentEnvironments(entry, ruleset, existingEnvironments, plannedEnvironments, cmdFlags.createEnvs, nil, "")
 			}
+			plan.Entries = append(plan.Entries, entry)
 		}
 	} else if len(sourceOrg) > 0 {
 		zap.S().Debugln("Getting source organization ID")
@@ -176,131 +284,80 @@ func runCmdCreate(owner string, cmdFlags *cmdFlags, g *utils.APIGetter, s *utils
 		if err != nil {
 			zap.S().Error("Error raised in fetching org")
 			return err
-		} else {
-			sourceOrgID = sourceOrgIDData.Organization.DatabaseID
-
-			zap.S().Infoln("Reading in rulesets from source organization", sourceOrg)
+		}
+		sourceOrgID = sourceOrgIDData.Organization.DatabaseID
 
-			if cmdFlags.ruleType == "all" || cmdFlags.ruleType == "orgOnly" {
-				zap.S().Infof("Gathering source organization %s level rulesets", sourceOrg)
-				allOrgRules, err := s.FetchOrgRulesets(sourceOrg)
-				if err != nil {
-					zap.S().Errorf("Error raised in fetching org ruleset data for %s", sourceOrg)
-				}
-				for _, singleRule := range allOrgRules {
-					zap.S().Debugf("Gathering specific ruleset data for org rule %s", singleRule.Name)
-					orgLevelRulesetResponse, err := s.GetOrgLevelRuleset(sourceOrg, singleRule.DatabaseID)
-					if err != nil {
-						zap.S().Error("Error raised in getting org level ruleset data for %s", singleRule.DatabaseID, zap.Error(err))
-						continue
-					} else {
-						var orgLevelRuleset data.RepoRuleset
-						err = json.Unmarshal(orgLevelRulesetResponse, &orgLevelRuleset)
-						if err != nil {
-							zap.S().Error("Error raised with variable response", zap.Error(err))
-							continue
-						}
-						updatedOrgLevelRuleset := g.UpdateBypassActorID(owner, sourceOrg, sourceOrgID, orgLevelRuleset, s)
-						updatedOrgWorkflowRuleset := g.UpdateRequiredWorkflowRepoID(owner, updatedOrgLevelRuleset, s)
-						createRuleset, err := utils.ProcessRulesets(updatedOrgWorkflowRuleset)
-						if err != nil {
-							zap.S().Errorf("Error creating rulesets data: %v", err)
-							continue
-						}
-						createRulesetJSON, err := json.Marshal(createRuleset)
-						if err != nil {
-							zap.S().Errorf("Error marshaling ruleset: %v", err)
-							continue
-						}
-						reader := bytes.NewReader(createRulesetJSON)
-						zap.S().Debugf("Creating rulesets under target organization %s", owner)
-						err = g.CreateOrgLevelRuleset(owner, reader)
-						if err != nil {
-							if strings.Contains(err.Error(), "\n") {
-								errorValidation = strings.Split(err.Error(), "\n")[1]
-							} else {
-								errorValidation = err.Error()
-							}
-							errorRulesets = append(errorRulesets, data.ErrorRulesets{Source: sourceOrg, RulesetName: createRuleset.Name, Error: errorValidation})
-							zap.S().Infof("Error creating ruleset %s for %s: %s", sourceOrg, createRuleset.Name, errorValidation)
-							continue
-						}
-					}
-				}
+		zap.S().Infoln("Reading in rulesets from source organization", sourceOrg)
+		sourceRulesets, err := s.GatherRulesets(sourceOrg, repos, cmdFlags.ruleType)
+		if err != nil {
+			return err
+		}
+		for _, sourceRuleset := range sourceRulesets {
+			utils.WarnUnsupportedRules(sourceRuleset)
+			updatedRuleset := g.UpdateBypassActorID(owner, sourceOrg, sourceOrgID, sourceRuleset, s)
+			updatedRuleset = g.UpdateRequiredWorkflowRepoID(owner, updatedRuleset, s)
+			updatedRuleset = g.UpdateStatusCheckIntegrationID(owner, sourceOrg, updatedRuleset, s)
+			if sourceRuleset.SourceType == "Organization" {
+				updatedRuleset = g.MapRulesetRepositories(updatedRuleset)
 			}
-
-			if cmdFlags.ruleType == "all" || cmdFlags.ruleType == "repoOnly" {
-				zap.S().Infof("Gathering repositories specified in org %s to list rulesets for", sourceOrg)
-				allRepos, err := g.GatherRepositories(sourceOrg, repos)
-				if err != nil {
-					zap.S().Error("Error raised in gathering repos", zap.Error(err))
-					return err
-				}
-				allRepoRules, err := g.FetchRepoRulesets(sourceOrg, allRepos)
-				if err != nil {
-					zap.S().Error("Error raised in fetching repo ruleset data", zap.Error(err))
-					return err
-				}
-				for _, singleRepoRule := range allRepoRules {
-					zap.S().Debugf("Gathering specific ruleset data for repo %s rule %s", singleRepoRule.RepoName, singleRepoRule.Rule.Name)
-					repoLevelRulesetResponse, err := g.GetRepoLevelRuleset(sourceOrg, singleRepoRule.RepoName, singleRepoRule.Rule.DatabaseID)
-					if err != nil {
-						zap.S().Error("Error raised in getting repo variables", zap.Error(err))
-						continue
-					} else {
-						var repoLevelRuleset data.RepoRuleset
-						err = json.Unmarshal(repoLevelRulesetResponse, &repoLevelRuleset)
-						if err != nil {
-							zap.S().Debugf("Error raised with variable response")
-							continue
-						}
-						updatedRepoLevelRuleset := g.UpdateBypassActorID(owner, sourceOrg, sourceOrgID, repoLevelRuleset, s)
-						updatedRepoWorkflowRuleset := g.UpdateRequiredWorkflowRepoID(owner, updatedRepoLevelRuleset, s)
-						createRuleset, err := utils.ProcessRulesets(updatedRepoWorkflowRuleset)
-						if err != nil {
-							zap.S().Errorf("Error creating rulesets data: %v", err)
-							continue
-						}
-						createRulesetJSON, err := json.Marshal(createRuleset)
-						if err != nil {
-							zap.S().Errorf("Error marshaling ruleset: %v", err)
-							continue
-						}
-						reader := bytes.NewReader(createRulesetJSON)
-
-						repoName := strings.Split(repoLevelRuleset.Source, "/")[1]
-						zap.S().Debugf("Creating rulesets under %s/%s", owner, repoName)
-						newSource := fmt.Sprintf("%s/%s", owner, repoName)
-						exists := g.RepoExists(newSource)
-						if !exists {
-							zap.S().Debugf("Repository %s does not exist in %s", repoName, owner)
-							errorRulesets = append(errorRulesets, data.ErrorRulesets{Source: repoLevelRuleset.Source, RulesetName: createRuleset.Name, Error: "Repository does not exist"})
-							zap.S().Infof("Error creating ruleset %s for %s: %s", repoLevelRuleset.Source, createRuleset.Name, "Repository does not exist")
-							continue
-						} else {
-							err = g.CreateRepoLevelRuleset(newSource, reader)
-							if err != nil {
-								if strings.Contains(err.Error(), "\n") {
-									errorValidation = strings.Split(err.Error(), "\n")[1]
-								} else {
-									errorValidation = err.Error()
-								}
-								errorRulesets = append(errorRulesets, data.ErrorRulesets{Source: repoLevelRuleset.Source, RulesetName: createRuleset.Name, Error: errorValidation})
-								zap.S().Infof("Error creating ruleset %s for %s: %s", repoLevelRuleset.Source, createRuleset.Name, errorValidation)
-								continue
-							}
-						}
-
-					}
-				}
+			createRuleset, err := utils.ProcessRulesets(updatedRuleset)
+			if err != nil {
+				zap.S().Errorf("Error creating rulesets data: %v", err)
+				continue
+			}
+			source := sourceOrg
+			target := owner
+			if sourceRuleset.SourceType == "Repository" {
+				source = sourceRuleset.Source
+				target = fmt.Sprintf("%s/%s", owner, g.MappedRepo(utils.RulesetRepoName(sourceRuleset)))
+			}
+			entry, err := utils.NewPlanEntry(updatedRuleset, createRuleset, source, target)
+			if err != nil {
+				zap.S().Errorf("Error marshaling ruleset: %v", err)
+				continue
 			}
+			if resumeCompleted(entry, state, retryRulesets) {
+				continue
+			}
+			if sourceRuleset.SourceType == "Repository" && !g.RepoExists(target) {
+				zap.S().Debugf("Repository %s does not exist in %s", target, owner)
+				entry.Requests = nil
+				entry.Error = "Repository does not exist"
+			} else {
+				entry = g.CheckExistingRuleset(entry, existingRulesets, cmdFlags.onConflict)
+				entry = g.CheckDeploymentEnvironments(entry, updatedRuleset, existingEnvironments, plannedEnvironments, cmdFlags.createEnvs, s, sourceRuleset.Source)
+			}
+			plan.Entries = append(plan.Entries, entry)
 		}
 	} else {
 		zap.S().Errorf("Error arose identifying rulesets")
 	}
 
+	if len(cmdFlags.planFile) > 0 {
+		err := utils.WritePlanToFile(plan, cmdFlags.planFile)
+		if err != nil {
+			zap.S().Errorf("Error writing plan to file: %v", err)
+			return err
+		}
+		zap.S().Infof("Saved plan for %d rulesets to %s", len(plan.Entries), cmdFlags.planFile)
+	}
+	if cmdFlags.dryRun {
+		zap.S().Infof("Dry run complete, no rulesets were created in org %s", owner)
+		return utils.WritePlanSummary(plan, os.Stdout)
+	}
+
+	if state == nil {
+		state = utils.NewStateFile(fmt.Sprintf("%s-ruleset-state-%s.json", reportName, time.Now().Format("20060102150405")), reportName)
+	}
+	if err := state.Track(plan.Entries); err != nil {
+		zap.S().Errorf("Error writing state file: %v", err)
+		return err
+	}
+	zap.S().Infof("Recording progress to state file %s", state.Path())
+
+	errorRulesets := g.ApplyPlan(plan, state)
 	if len(errorRulesets) > 0 {
-		reportFileName := fmt.Sprintf("%s-ruleset-errors-%s.csv", owner, time.Now().Format("20060102150405"))
+		reportFileName := fmt.Sprintf("%s-ruleset-errors-%s.csv", reportName, time.Now().Format("20060102150405"))
 		err := utils.WriteErrorRulesetsToCSV(errorRulesets, reportFileName)
 		if err != nil {
 			zap.S().Errorf("Error writing error rulesets to csv file: %v", err)
@@ -313,3 +370,20 @@ func runCmdCreate(owner string, cmdFlags *cmdFlags, g *utils.APIGetter, s *utils
 	}
 	return nil
 }
+
+// resumeCompleted reports whether an entry should be left out of a resumed run,
+// either because the state file records it as completed or because it is not
+// listed in the error csv file being retried.
+func resumeCompleted(entry data.PlanEntry, state *utils.StateFile, retryRulesets map[string]struct{}) bool {
+	if state != nil && state.Completed(entry) {
+		zap.S().Infof("Skipping ruleset %s for %s as it was completed in a previous run", entry.RulesetName, entry.Target)
+		return true
+	}
+	if retryRulesets != nil {
+		if _, retry := retryRulesets[utils.RetryKey(entry.Source, entry.RulesetName)]; !retry {
+			zap.S().Debugf("Skipping ruleset %s for %s as it is not in the retry file", entry.RulesetName, entry.Target)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+package delete
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token       string
+	hostname    string
+	fileName    string
+	names       []string
+	ids         []int
+	ruleType    string
+	all         bool
+	yes         bool
+	concurrency int
+	debug       bool
+}
+
+func NewCmdDelete() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete [flags] <organization> [repo ...]",
+		Short: "Delete rulesets for repositories and/or organization.",
+		Long:  "Delete rulesets for a list of repositories and/or organization, selected by name, ID or a file produced by list.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(deleteCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			validRuleTypes := map[string]struct{}{
+				"all":      {},
+				"repoOnly": {},
+				"orgOnly":  {},
+			}
+			if _, isValid := validRuleTypes[cmdFlags.ruleType]; !isValid {
+				return fmt.Errorf("invalid ruleType: %s. Valid values are 'all', 'repoOnly', or 'orgOnly'", cmdFlags.ruleType)
+			}
+			selectors := len(cmdFlags.fileName) > 0 || len(cmdFlags.names) > 0 || len(cmdFlags.ids) > 0
+			if !selectors && !cmdFlags.all {
+				return errors.New("specify `--name`, `--id`, `--from-file` or `--all` to select the rulesets to delete")
+			} else if selectors && cmdFlags.all {
+				return errors.New("`--all` cannot be combined with `--name`, `--id` or `--from-file`")
+			}
+
+			authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
+			restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
+			if err != nil {
+				return err
+			}
+
+			owner := args[0]
+			repos := args[1:]
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetConcurrency(cmdFlags.concurrency)
+			return runCmdDelete(owner, repos, &cmdFlags, g, os.Stdin, os.Stdout)
+		},
+	}
+	ruleDefault := "all"
+
+	deleteCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	deleteCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	deleteCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV file produced by list with the rulesets to delete")
+	deleteCmd.Flags().StringSliceVarP(&cmdFlags.names, "name", "n", []string{}, "Names of the rulesets to delete separated by commas")
+	deleteCmd.Flags().IntSliceVarP(&cmdFlags.ids, "id", "", []int{}, "IDs of the rulesets to delete separated by commas")
+	deleteCmd.PersistentFlags().StringVarP(&cmdFlags.ruleType, "ruleType", "r", ruleDefault, "Delete rulesets for a specific application or all: {all|repoOnly|orgOnly}")
+	deleteCmd.Flags().BoolVarP(&cmdFlags.all, "all", "", false, "Delete every ruleset matching the organization, repositories and ruleType")
+	deleteCmd.Flags().BoolVarP(&cmdFlags.yes, "yes", "y", false, "Delete the rulesets without asking for confirmation")
+	deleteCmd.Flags().IntVarP(&cmdFlags.concurrency, "concurrency", "c", 1, "Number of concurrent requests used to fetch repositories and rulesets")
+	deleteCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return deleteCmd
+}
+
+func runCmdDelete(owner string, repos []string, cmdFlags *cmdFlags, g *utils.APIGetter, in io.Reader, out io.Writer) error {
+	var deleteRulesets []data.RepoRuleset
+	var reportRulesets []data.ErrorRulesets
+	var err error
+
+	if len(cmdFlags.fileName) > 0 {
+		deleteRulesets, reportRulesets, err = rulesetsFromFile(owner, cmdFlags.fileName, cmdFlags.ruleType, g)
+	} else {
+		deleteRulesets, err = selectRulesets(owner, repos, cmdFlags, g)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(deleteRulesets) == 0 {
+		zap.S().Infof("No rulesets matched for deletion in %s", owner)
+	} else {
+		fmt.Fprintf(out, "The following %d rulesets will be deleted from %s:\n", len(deleteRulesets), owner)
+		for _, ruleset := range deleteRulesets {
+			fmt.Fprintf(out, "  [%s] %s: %q (ID %d)\n", ruleset.SourceType, ruleset.Source, ruleset.Name, ruleset.ID)
+		}
+
+		if !cmdFlags.yes {
+			fmt.Fprint(out, "Delete these rulesets? [y/N]: ")
+			answer, _ := bufio.NewReader(in).ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			if answer != "y" && answer != "yes" {
+				zap.S().Infof("Deletion of rulesets in %s cancelled", owner)
+				return nil
+			}
+		}
+
+		for _, ruleset := range deleteRulesets {
+			zap.S().Debugf("Deleting ruleset %s from %s", ruleset.Name, ruleset.Source)
+			report := data.ErrorRulesets{Source: ruleset.Source, RulesetName: ruleset.Name, Action: "delete"}
+			err := g.DeleteRuleset(ruleset.SourceType, ruleset.Source, ruleset.ID)
+			if err != nil {
+				report.Error = utils.ErrorMessage(err)
+				zap.S().Infof("Error deleting ruleset %s for %s: %s", ruleset.Name, ruleset.Source, report.Error)
+			} else {
+				zap.S().Infof("Successfully deleted ruleset %s for %s", ruleset.Name, ruleset.Source)
+			}
+			reportRulesets = append(reportRulesets, report)
+		}
+	}
+
+	if len(reportRulesets) > 0 {
+		reportFileName := fmt.Sprintf("%s-ruleset-delete-%s.csv", owner, time.Now().Format("20060102150405"))
+		err := utils.WriteErrorRulesetsToCSV(reportRulesets, reportFileName)
+		if err != nil {
+			zap.S().Errorf("Error writing deleted rulesets to csv file: %v", err)
+		}
+	}
+	zap.S().Infof("Completed deleting rulesets in org %s", owner)
+	return nil
+}
+
+// selectRulesets gathers the rulesets of owner matching the ruleType, repos
+// and the name and ID filters. Every ruleset is selected when there are no
+// name or ID filters, which requires --all.
+func selectRulesets(owner string, repos []string, cmdFlags *cmdFlags, g *utils.APIGetter) ([]data.RepoRuleset, error) {
+	var candidates []data.RepoRuleset
+
+	if cmdFlags.ruleType == "all" || cmdFlags.ruleType == "orgOnly" {
+		zap.S().Infof("Gathering organization %s level rulesets", owner)
+		allOrgRules, err := g.FetchOrgRulesets(owner)
+		if err != nil {
+			zap.S().Errorf("Error raised in fetching org ruleset data for %s", owner)
+			return nil, err
+		}
+		for _, rule := range allOrgRules {
+			candidates = append(candidates, data.RepoRuleset{ID: rule.DatabaseID, Name: rule.Name, SourceType: "Organization", Source: owner})
+		}
+	}
+
+	if cmdFlags.ruleType == "all" || cmdFlags.ruleType == "repoOnly" {
+		zap.S().Infof("Gathering repositories specified in org %s to delete rulesets for", owner)
+		allRepos, err := g.GatherRepositories(owner, repos)
+		if err != nil {
+			zap.S().Error("Error raised in gathering repos", zap.Error(err))
+			return nil, err
+		}
+		allRepoRules, err := g.FetchRepoRulesets(owner, allRepos)
+		if err != nil {
+			zap.S().Error("Error raised in fetching repo ruleset data", zap.Error(err))
+			return nil, err
+		}
+		for _, repoRule := range allRepoRules {
+			candidates = append(candidates, data.RepoRuleset{ID: repoRule.Rule.DatabaseID, Name: repoRule.Rule.Name, SourceType: "Repository", Source: fmt.Sprintf("%s/%s", owner, repoRule.RepoName)})
+		}
+	}
+
+	if len(cmdFlags.names) == 0 && len(cmdFlags.ids) == 0 {
+		return candidates, nil
+	}
+	var selected []data.RepoRuleset
+	for _, candidate := range candidates {
+		if utils.Contains(cmdFlags.names, candidate.Name) || containsID(cmdFlags.ids, candidate.ID) {
+			selected = append(selected, candidate)
+		}
+	}
+	return selected, nil
+}
+
+// rulesetsFromFile matches the rulesets listed in a CSV file produced by list
+// against the rulesets defined under owner by level, repository and name,
+// leaving out rulesets of a level not selected by ruleType. When the existing
+// rulesets of a target cannot be looked up, its rows are reported with an
+// error rather than as rulesets that do not exist.
+func rulesetsFromFile(owner string, fileName string, ruleType string, g *utils.APIGetter) ([]data.RepoRuleset, []data.ErrorRulesets, error) {
+	zap.S().Infof("Reading in file %s to identify rulesets to delete", fileName)
+	f, err := os.Open(fileName)
+	if err != nil {
+		zap.S().Errorf("Error arose opening rulesets csv file")
+		return nil, nil, err
+	}
+	defer f.Close()
+	rulesetData, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		zap.S().Errorf("Error arose reading rulesets from csv file")
+		return nil, nil, err
+	}
+	if len(rulesetData) == 0 {
+		return nil, nil, nil
+	}
+	headerMap := make(map[string]int)
+	for i, header := range rulesetData[0] {
+		headerMap[header] = i
+	}
+	for _, header := range []string{"RulesetLevel", "RepositoryName", "RulesetName"} {
+		if _, ok := headerMap[header]; !ok {
+			return nil, nil, fmt.Errorf("missing column %s in %s", header, fileName)
+		}
+	}
+
+	var deleteRulesets []data.RepoRuleset
+	var unmatched []data.ErrorRulesets
+	existingRulesets := make(map[string]map[string]int)
+	for _, each := range rulesetData[1:] {
+		level := each[headerMap["RulesetLevel"]]
+		name := each[headerMap["RulesetName"]]
+		target := owner
+		if level == "Repository" {
+			target = fmt.Sprintf("%s/%s", owner, each[headerMap["RepositoryName"]])
+		} else if level != "Organization" {
+			zap.S().Infof("Skipping ruleset %s with unknown ruleset level %s", name, level)
+			continue
+		}
+		if (ruleType == "orgOnly" && level != "Organization") || (ruleType == "repoOnly" && level != "Repository") {
+			zap.S().Debugf("Skipping %s ruleset %s as ruleType is %s", strings.ToLower(level), name, ruleType)
+			continue
+		}
+		existing, ok := existingRulesets[target]
+		if !ok {
+			existing, err = g.ExistingRulesetIDs(level, target)
+			if err != nil {
+				zap.S().Errorf("Error gathering existing rulesets for %s: %v", target, err)
+				existing = nil
+			}
+			existingRulesets[target] = existing
+		}
+		if existing == nil {
+			unmatched = append(unmatched, data.ErrorRulesets{Source: target, RulesetName: name, Action: "delete", Error: fmt.Sprintf("Existing rulesets of %s could not be looked up", target)})
+			continue
+		}
+		rulesetID, exists := existing[name]
+		if !exists {
+			zap.S().Infof("Ruleset %s does not exist in %s", name, target)
+			unmatched = append(unmatched, data.ErrorRulesets{Source: target, RulesetName: name, Action: "delete", Error: "Ruleset does not exist"})
+			continue
+		}
+		deleteRulesets = append(deleteRulesets, data.RepoRuleset{ID: rulesetID, Name: name, SourceType: level, Source: target})
+	}
+	return deleteRulesets, unmatched, nil
+}
+
+func containsID(ids []int, id int) bool {
+	for _, each := range ids {
+		if each == id {
+			return true
+		}
+	}
+	return false
+}
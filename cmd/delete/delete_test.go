@@ -0,0 +1,187 @@
+package delete
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// rulesetServer serves the rulesets of organization org and of its
+// repositories app and down, failing the lookup of the rulesets of down, and
+// records the DELETE requests it receives.
+type rulesetServer struct {
+	mu      sync.Mutex
+	deleted []string
+}
+
+func (s *rulesetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		s.mu.Lock()
+		s.deleted = append(s.deleted, r.URL.Path)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var body struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	json.NewDecoder(r.Body).Decode(&body) // nolint:errcheck
+	rulesets := `{"nodes":[%s],"pageInfo":{"hasNextPage":false}}`
+	switch {
+	case strings.Contains(body.Query, "organization("):
+		fmt.Fprintf(w, `{"data":{"organization":{"rulesets":`+rulesets+`}}}`, `{"databaseId":10,"name":"org-rule"}`)
+	case body.Variables["name"] == "app":
+		fmt.Fprintf(w, `{"data":{"repository":{"rulesets":`+rulesets+`}}}`, `{"databaseId":20,"name":"repo-rule"}`)
+	default:
+		w.Write([]byte(`{"errors":[{"message":"Could not resolve to a Repository"}]}`)) // nolint:errcheck
+	}
+}
+
+// newTestGetter returns an APIGetter whose REST and GraphQL requests are
+// served by handler, without going over the network.
+func newTestGetter(t *testing.T, handler http.Handler) *utils.APIGetter {
+	t.Helper()
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		resp := recorder.Result()
+		resp.Request = req
+		return resp, nil
+	})
+	options := &api.ClientOptions{
+		Host:      "github.com",
+		AuthToken: "token",
+		Transport: transport,
+	}
+	restClient, err := gh.RESTClient(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gqlClient, err := gh.GQLClient(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return utils.NewAPIGetter(gqlClient, restClient)
+}
+
+// inTempDir runs the test from a temporary directory, where delete writes its
+// report.
+func inTempDir(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) }) // nolint:errcheck
+}
+
+func TestRulesetsFromFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "rulesets.csv")
+	file := strings.Join([]string{
+		"RulesetLevel,RepositoryName,RuleID,RulesetName",
+		"Organization,N/A,1,org-rule",
+		"Repository,app,2,repo-rule",
+		"Repository,app,3,gone",
+		"Repository,down,4,repo-rule",
+		"Enterprise,N/A,5,ent-rule",
+	}, "\n")
+	if err := os.WriteFile(fileName, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	g := newTestGetter(t, &rulesetServer{})
+
+	tests := []struct {
+		ruleType   string
+		wantDelete []data.RepoRuleset
+		wantReport []data.ErrorRulesets
+	}{
+		{
+			ruleType: "all",
+			wantDelete: []data.RepoRuleset{
+				{ID: 10, Name: "org-rule", SourceType: "Organization", Source: "org"},
+				{ID: 20, Name: "repo-rule", SourceType: "Repository", Source: "org/app"},
+			},
+			wantReport: []data.ErrorRulesets{
+				{Source: "org/app", RulesetName: "gone", Action: "delete", Error: "Ruleset does not exist"},
+				{Source: "org/down", RulesetName: "repo-rule", Action: "delete", Error: "Existing rulesets of org/down could not be looked up"},
+			},
+		},
+		{
+			ruleType:   "orgOnly",
+			wantDelete: []data.RepoRuleset{{ID: 10, Name: "org-rule", SourceType: "Organization", Source: "org"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ruleType, func(t *testing.T) {
+			deleteRulesets, report, err := rulesetsFromFile("org", fileName, tt.ruleType, g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(deleteRulesets, tt.wantDelete) {
+				t.Errorf("rulesets = %+v, want %+v", deleteRulesets, tt.wantDelete)
+			}
+			if !reflect.DeepEqual(report, tt.wantReport) {
+				t.Errorf("report = %+v, want %+v", report, tt.wantReport)
+			}
+		})
+	}
+}
+
+func TestRunCmdDeleteConfirmation(t *testing.T) {
+	inTempDir(t)
+	tests := []struct {
+		name        string
+		yes         bool
+		input       string
+		wantDeleted []string
+	}{
+		{name: "declined", input: "n\n"},
+		{name: "no answer", input: ""},
+		{name: "confirmed", input: "y\n", wantDeleted: []string{"/orgs/org/rulesets/10"}},
+		{name: "yes flag", yes: true, wantDeleted: []string{"/orgs/org/rulesets/10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &rulesetServer{}
+			g := newTestGetter(t, server)
+			flags := &cmdFlags{names: []string{"org-rule"}, ruleType: "orgOnly", yes: tt.yes}
+			var out strings.Builder
+
+			if err := runCmdDelete("org", nil, flags, g, strings.NewReader(tt.input), &out); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), `"org-rule" (ID 10)`) {
+				t.Errorf("output %q does not list the ruleset", out.String())
+			}
+			if prompted := strings.Contains(out.String(), "[y/N]"); prompted == tt.yes {
+				t.Errorf("prompted = %v with --yes %v", prompted, tt.yes)
+			}
+			if !reflect.DeepEqual(server.deleted, tt.wantDeleted) {
+				t.Errorf("deleted %v, want %v", server.deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
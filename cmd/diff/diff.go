@@ -0,0 +1,183 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	sourceToken    string
+	sourceHostname string
+	token          string
+	hostname       string
+	outputFile     string
+	format         string
+	repos          []string
+	ruleType       string
+	concurrency    int
+	debug          bool
+}
+
+func NewCmdDiff() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken, authSourceToken string
+
+	diffCmd := &cobra.Command{
+		Use:   "diff [flags] <source-organization> <target-organization>",
+		Short: "Compare rulesets between a source and target organization.",
+		Long:  "Compare repository/organization level rulesets between a source and target organization, reporting added, removed and changed rulesets.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(diffCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			validRuleTypes := map[string]struct{}{
+				"all":      {},
+				"repoOnly": {},
+				"orgOnly":  {},
+			}
+			if _, isValid := validRuleTypes[cmdFlags.ruleType]; !isValid {
+				return fmt.Errorf("invalid ruleType: %s. Valid values are 'all', 'repoOnly', or 'orgOnly'", cmdFlags.ruleType)
+			}
+			if cmdFlags.format != "text" && cmdFlags.format != "json" {
+				return fmt.Errorf("invalid format: %s. Valid values are 'text' or 'json'", cmdFlags.format)
+			}
+
+			authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
+			restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
+			if err != nil {
+				return err
+			}
+
+			authSourceToken = utils.GetAuthToken(cmdFlags.sourceToken, cmdFlags.sourceHostname)
+			restSrcClient, gqlSrcClient, err := utils.InitializeClients(cmdFlags.sourceHostname, authSourceToken)
+			if err != nil {
+				return err
+			}
+
+			var reportWriter io.Writer = os.Stdout
+			if len(cmdFlags.outputFile) > 0 {
+				reportFile, err := os.Create(cmdFlags.outputFile)
+				if err != nil {
+					return err
+				}
+				defer reportFile.Close()
+				reportWriter = reportFile
+			}
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetConcurrency(cmdFlags.concurrency)
+			s := utils.NewAPIGetter(gqlSrcClient, restSrcClient)
+			s.SetConcurrency(cmdFlags.concurrency)
+
+			diffs, err := runCmdDiff(args[0], args[1], &cmdFlags, s, g, reportWriter)
+			if err != nil {
+				return err
+			}
+			if len(diffs) > 0 {
+				diffCmd.SilenceUsage = true
+				return fmt.Errorf("found %d ruleset differences between %s and %s", len(diffs), args[0], args[1])
+			}
+			return nil
+		},
+	}
+	ruleDefault := "all"
+
+	diffCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub personal access token for the target organization (default "gh auth token")`)
+	diffCmd.PersistentFlags().StringVarP(&cmdFlags.sourceToken, "source-pat", "p", "", `GitHub personal access token for the source organization (default "gh auth token")`)
+	diffCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname of the target organization")
+	diffCmd.PersistentFlags().StringVarP(&cmdFlags.sourceHostname, "source-hostname", "", "github.com", "GitHub Enterprise Server hostname of the source organization")
+	diffCmd.Flags().StringVarP(&cmdFlags.outputFile, "output-file", "o", "", "Name of file to write the differences to (default stdout)")
+	diffCmd.Flags().StringVarP(&cmdFlags.format, "format", "", "text", "Output format of the differences: {text|json}")
+	diffCmd.Flags().StringSliceVarP(&cmdFlags.repos, "repos", "R", []string{}, "List of repositories names to compare rulesets for separated by commas (i.e. repo1,repo2,repo3)")
+	diffCmd.PersistentFlags().StringVarP(&cmdFlags.ruleType, "ruleType", "r", ruleDefault, "Compare rulesets for a specific application or all: {all|repoOnly|orgOnly}")
+	diffCmd.Flags().IntVarP(&cmdFlags.concurrency, "concurrency", "c", 1, "Number of concurrent requests used to fetch repositories and rulesets")
+	diffCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return diffCmd
+}
+
+func runCmdDiff(sourceOrg string, targetOrg string, cmdFlags *cmdFlags, s *utils.APIGetter, g *utils.APIGetter, reportWriter io.Writer) ([]data.RulesetDiff, error) {
+	zap.S().Infof("Comparing rulesets between %s and %s", sourceOrg, targetOrg)
+
+	sourceOrgIDData, err := s.FetchOrgId(sourceOrg)
+	if err != nil {
+		zap.S().Errorf("Error raised in fetching org %s", sourceOrg)
+		return nil, err
+	}
+	targetOrgIDData, err := g.FetchOrgId(targetOrg)
+	if err != nil {
+		zap.S().Errorf("Error raised in fetching org %s", targetOrg)
+		return nil, err
+	}
+
+	sourceRulesets, err := s.GatherRulesets(sourceOrg, cmdFlags.repos, cmdFlags.ruleType)
+	if err != nil {
+		return nil, err
+	}
+	targetRulesets, err := g.GatherRulesets(targetOrg, cmdFlags.repos, cmdFlags.ruleType)
+	if err != nil {
+		return nil, err
+	}
+
+	zap.S().Infof("Comparing %d source rulesets with %d target rulesets", len(sourceRulesets), len(targetRulesets))
+	diffs := utils.DiffRulesets(
+		s.FlattenRulesets(sourceRulesets, sourceOrg, sourceOrgIDData.Organization.DatabaseID),
+		g.FlattenRulesets(targetRulesets, targetOrg, targetOrgIDData.Organization.DatabaseID),
+	)
+
+	if cmdFlags.format == "json" {
+		if diffs == nil {
+			diffs = []data.RulesetDiff{}
+		}
+		encoder := json.NewEncoder(reportWriter)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diffs); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := writeTextDiff(diffs, sourceOrg, targetOrg, reportWriter); err != nil {
+			return nil, err
+		}
+	}
+	zap.S().Infof("Completed comparing rulesets between %s and %s", sourceOrg, targetOrg)
+	return diffs, nil
+}
+
+func writeTextDiff(diffs []data.RulesetDiff, sourceOrg string, targetOrg string, w io.Writer) error {
+	if len(diffs) == 0 {
+		_, err := fmt.Fprintf(w, "No ruleset differences found between %s and %s\n", sourceOrg, targetOrg)
+		return err
+	}
+	statusPrefix := map[string]string{
+		"added":   "+",
+		"removed": "-",
+		"changed": "~",
+	}
+	for _, diff := range diffs {
+		location := diff.RulesetLevel
+		if diff.RulesetLevel == "Repository" {
+			location = fmt.Sprintf("%s %s", diff.RulesetLevel, diff.RepositoryName)
+		}
+		_, err := fmt.Fprintf(w, "%s %s ruleset %q (%s)\n", statusPrefix[diff.Status], location, diff.RulesetName, diff.Status)
+		if err != nil {
+			return err
+		}
+		for _, change := range diff.Changes {
+			_, err = fmt.Fprintf(w, "    %s: %q -> %q\n", change.Field, change.Source, change.Target)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package insights
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token           string
+	hostname        string
+	outputFile      string
+	evaluationsFile string
+	format          string
+	timePeriod      string
+	result          string
+	ruleType        string
+	concurrency     int
+	debug           bool
+}
+
+func NewCmdInsights() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	insightsCmd := &cobra.Command{
+		Use:   "insights [flags] <organization> [repo ...]",
+		Short: "Report what rulesets blocked or would have blocked.",
+		Long:  "Report the rule suite evaluations of the rulesets in an organization over a time period, counting the passes, failures and bypasses of each ruleset and rule, to show what rulesets in evaluate mode would block once active.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(insightsCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			validTimePeriods := map[string]struct{}{
+				"hour":  {},
+				"day":   {},
+				"week":  {},
+				"month": {},
+			}
+			if _, isValid := validTimePeriods[cmdFlags.timePeriod]; !isValid {
+				return fmt.Errorf("invalid time-period: %s. Valid values are 'hour', 'day', 'week', or 'month'", cmdFlags.timePeriod)
+			}
+			validResults := map[string]struct{}{
+				"all":    {},
+				"pass":   {},
+				"fail":   {},
+				"bypass": {},
+			}
+			if _, isValid := validResults[cmdFlags.result]; !isValid {
+				return fmt.Errorf("invalid result: %s. Valid values are 'all', 'pass', 'fail', or 'bypass'", cmdFlags.result)
+			}
+			validRuleTypes := map[string]struct{}{
+				"all":      {},
+				"repoOnly": {},
+				"orgOnly":  {},
+			}
+			if _, isValid := validRuleTypes[cmdFlags.ruleType]; !isValid {
+				return fmt.Errorf("invalid ruleType: %s. Valid values are 'all', 'repoOnly', or 'orgOnly'", cmdFlags.ruleType)
+			}
+			if cmdFlags.format != "csv" && cmdFlags.format != "json" {
+				return fmt.Errorf("invalid format: %s. Valid values are 'csv' or 'json'", cmdFlags.format)
+			}
+			if !insightsCmd.Flags().Changed("output-file") && cmdFlags.format != "csv" {
+				cmdFlags.outputFile = strings.TrimSuffix(cmdFlags.outputFile, ".csv") + "." + cmdFlags.format
+			}
+
+			authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
+			restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
+			if err != nil {
+				return err
+			}
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetConcurrency(cmdFlags.concurrency)
+			return runCmdInsights(args[0], args[1:], &cmdFlags, g)
+		},
+	}
+
+	timestamp := time.Now().Format("20060102150405")
+	reportFileDefault := fmt.Sprintf("insights-%s.csv", timestamp)
+	evaluationsFileDefault := fmt.Sprintf("insights-evaluations-%s.csv", timestamp)
+
+	insightsCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	insightsCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	insightsCmd.Flags().StringVarP(&cmdFlags.outputFile, "output-file", "o", reportFileDefault, "Name of file to write the ruleset and rule counts to")
+	insightsCmd.Flags().StringVarP(&cmdFlags.evaluationsFile, "evaluations-file", "", evaluationsFileDefault, "Name of csv file to write the individual rule evaluations to")
+	insightsCmd.Flags().StringVarP(&cmdFlags.format, "format", "", "csv", "Output format of the report: {csv|json}")
+	insightsCmd.Flags().StringVarP(&cmdFlags.timePeriod, "time-period", "", "day", "Time period to report rule suites for: {hour|day|week|month}")
+	insightsCmd.Flags().StringVarP(&cmdFlags.result, "result", "", "all", "Only report rule suites with this result: {all|pass|fail|bypass}")
+	insightsCmd.PersistentFlags().StringVarP(&cmdFlags.ruleType, "ruleType", "r", "all", "Report rulesets for a specific application or all: {all|repoOnly|orgOnly}")
+	insightsCmd.Flags().IntVarP(&cmdFlags.concurrency, "concurrency", "c", 1, "Number of concurrent requests used to fetch rule suites")
+	insightsCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return insightsCmd
+}
+
+func runCmdInsights(owner string, repos []string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	zap.S().Infof("Gathering rulesets in %s to report insights for", owner)
+	refs, err := g.GatherRulesetRefs(owner, repos, cmdFlags.ruleType)
+	if err != nil {
+		zap.S().Error("Error raised in gathering rulesets")
+		return err
+	}
+	suites, err := g.GatherRuleSuites(owner, repos, cmdFlags.timePeriod, cmdFlags.result)
+	if err != nil {
+		zap.S().Error("Error raised in gathering rule suites")
+		return err
+	}
+
+	summary, evaluations := utils.BuildRuleInsights(suites, refs)
+	for _, insight := range summary {
+		if insight.RuleType == "" && insight.Enforcement == "evaluate" && insight.Failures > 0 {
+			zap.S().Warnf("Ruleset %s in evaluate mode would have blocked %d of %d pushes", insight.RulesetName, insight.Failures, insight.Evaluations)
+		}
+	}
+
+	if cmdFlags.format == "json" {
+		report := data.RuleInsightsReport{
+			Owner:       owner,
+			TimePeriod:  cmdFlags.timePeriod,
+			Summary:     summary,
+			Evaluations: evaluations,
+		}
+		if err := utils.WriteRuleInsightsToJSON(report, cmdFlags.outputFile); err != nil {
+			zap.S().Errorf("Error writing insights to json file: %v", err)
+			return err
+		}
+	} else {
+		if err := utils.WriteRuleInsightsToCSV(summary, cmdFlags.outputFile); err != nil {
+			zap.S().Errorf("Error writing insights to csv file: %v", err)
+			return err
+		}
+		if err := utils.WriteRuleEvaluationsToCSV(evaluations, cmdFlags.evaluationsFile); err != nil {
+			zap.S().Errorf("Error writing rule evaluations to csv file: %v", err)
+			return err
+		}
+		zap.S().Infof("Wrote %d rule evaluations to %s", len(evaluations), cmdFlags.evaluationsFile)
+	}
+	zap.S().Infof("Wrote insights from %d rule suites in the last %s to %s", len(suites), cmdFlags.timePeriod, cmdFlags.outputFile)
+	return nil
+}
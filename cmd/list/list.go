@@ -2,12 +2,10 @@ package list
 
 import (
 	"encoding/csv"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -19,11 +17,15 @@ import (
 )
 
 type cmdFlags struct {
-	token    string
-	hostname string
-	listFile string
-	ruleType string
-	debug    bool
+	token       string
+	hostname    string
+	listFile    string
+	outputDir   string
+	format      string
+	ruleType    string
+	enterprise  string
+	concurrency int
+	debug       bool
 }
 
 func NewCmdList() *cobra.Command {
@@ -34,12 +36,16 @@ func NewCmdList() *cobra.Command {
 		Use:   "list [flags] <organization> [repo ...]",
 		Short: "Generate a report of rulesets for repositories and/or organization.",
 		Long:  "Generate a report of rulesets for a list of repositories and/or organization.",
-		Args:  cobra.MinimumNArgs(1),
+		Args:  cobra.ArbitraryArgs,
 		RunE: func(listCmd *cobra.Command, args []string) error {
 			logger, _ := log.NewLogger(cmdFlags.debug)
 			defer logger.Sync() // nolint:errcheck
 			zap.ReplaceGlobals(logger)
 
+			if len(args) == 0 && len(cmdFlags.enterprise) == 0 {
+				return errors.New("an organization or `--enterprise` must be specified to list rulesets for")
+			}
+
 			validRuleTypes := map[string]struct{}{
 				"all":      {},
 				"repoOnly": {},
@@ -48,6 +54,17 @@ func NewCmdList() *cobra.Command {
 			if _, isValid := validRuleTypes[cmdFlags.ruleType]; !isValid {
 				return fmt.Errorf("invalid ruleType: %s. Valid values are 'all', 'repoOnly', or 'orgOnly'", cmdFlags.ruleType)
 			}
+			validFormats := map[string]struct{}{
+				"csv":  {},
+				"json": {},
+				"yaml": {},
+			}
+			if _, isValid := validFormats[cmdFlags.format]; !isValid {
+				return fmt.Errorf("invalid format: %s. Valid values are 'csv', 'json', or 'yaml'", cmdFlags.format)
+			}
+			if len(cmdFlags.outputDir) > 0 && cmdFlags.format == "csv" {
+				return errors.New("`--output-dir` is only supported with `--format json` or `--format yaml`")
+			}
 
 			authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
 			restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
@@ -55,245 +72,126 @@ func NewCmdList() *cobra.Command {
 				return err
 			}
 
-			owner := args[0]
-			repos := args[1:]
-
-			if _, err := os.Stat(cmdFlags.listFile); errors.Is(err, os.ErrExist) {
-				return err
+			var owner string
+			var repos []string
+			if len(args) > 0 {
+				owner = args[0]
+				repos = args[1:]
 			}
 
-			reportWriter, err := os.OpenFile(cmdFlags.listFile, os.O_WRONLY|os.O_CREATE, 0644)
-			if err != nil {
-				return err
+			var reportWriter io.Writer
+			if len(cmdFlags.outputDir) == 0 {
+				if !listCmd.Flags().Changed("output-file") && cmdFlags.format != "csv" {
+					cmdFlags.listFile = strings.TrimSuffix(cmdFlags.listFile, ".csv") + "." + cmdFlags.format
+				}
+				if _, err := os.Stat(cmdFlags.listFile); errors.Is(err, os.ErrExist) {
+					return err
+				}
+
+				reportFile, err := os.OpenFile(cmdFlags.listFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+				if err != nil {
+					return err
+				}
+				defer reportFile.Close()
+				reportWriter = reportFile
 			}
 
-			return runCmdList(owner, repos, &cmdFlags, utils.NewAPIGetter(gqlClient, restClient), reportWriter)
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetConcurrency(cmdFlags.concurrency)
+			return runCmdList(owner, repos, &cmdFlags, g, reportWriter)
 		},
 	}
 
 	reportFileDefault := fmt.Sprintf("ruleset-%s.csv", time.Now().Format("20060102150405"))
 	ruleDefault := "all"
+	formatDefault := "csv"
 
 	listCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
 	listCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
-	listCmd.Flags().StringVarP(&cmdFlags.listFile, "output-file", "o", reportFileDefault, "Name of file to write CSV list to")
+	listCmd.Flags().StringVarP(&cmdFlags.listFile, "output-file", "o", reportFileDefault, "Name of file to write the list to")
+	listCmd.Flags().StringVarP(&cmdFlags.outputDir, "output-dir", "", "", "Directory to write one file per ruleset to, instead of a single output file")
+	listCmd.Flags().StringVarP(&cmdFlags.format, "format", "", formatDefault, "Output format of the rulesets: {csv|json|yaml}")
 	listCmd.PersistentFlags().StringVarP(&cmdFlags.ruleType, "ruleType", "r", ruleDefault, "List rulesets for a specific application or all: {all|repoOnly|orgOnly}")
+	listCmd.Flags().StringVarP(&cmdFlags.enterprise, "enterprise", "", "", "Slug of the enterprise to also list enterprise level rulesets for")
+	listCmd.Flags().IntVarP(&cmdFlags.concurrency, "concurrency", "c", 1, "Number of concurrent requests used to fetch repositories and rulesets")
 	listCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
 	return listCmd
 }
 
 func runCmdList(owner string, repos []string, cmdFlags *cmdFlags, g *utils.APIGetter, reportWriter io.Writer) error {
-	zap.S().Infof("Gathering repositories and/or rulesets for %s", owner)
+	var allRulesets []data.RepoRuleset
 	var orgID int
-
-	orgIDData, err := g.FetchOrgId(owner)
-	if err != nil {
-		zap.S().Error("Error raised in fetching org")
-		return err
-	} else {
-		orgID = orgIDData.Organization.DatabaseID
-
-		csvWriter := csv.NewWriter(reportWriter)
-		err = csvWriter.Write([]string{
-			"RulesetLevel",
-			"RepositoryName",
-			"RuleID",
-			"RulesetName",
-			"Target",
-			"Enforcement",
-			"BypassActors",
-			"ConditionsRefNameInclude",
-			"ConditionsRefNameExclude",
-			"ConditionsRepoNameInclude",
-			"ConditionsRepoNameExclude",
-			"ConditionsRepoNameProtected",
-			"ConditionRepoPropertyInclude",
-			"ConditionRepoPropertyExclude",
-			"RulesCreation",
-			"RulesUpdate",
-			"RulesDeletion",
-			"RulesRequiredLinearHistory",
-			"RulesMergeQueue",
-			"RulesRequiredDeployments",
-			"RulesRequiredSignatures",
-			"RulesPullRequest",
-			"RulesRequiredStatusChecks",
-			"RulesNonFastForward",
-			"RulesCommitMessagePattern",
-			"RulesCommitAuthorEmailPattern",
-			"RulesCommitterEmailPattern",
-			"RulesBranchNamePattern",
-			"RulesTagNamePattern",
-			"RulesFilePathRestriction",
-			"RulesFilePathLength",
-			"RulesFileExtensionRestriction",
-			"RulesMaxFileSize",
-			"RulesWorkflows",
-			"RulesCodeScanning",
-			"CreatedAt",
-			"UpdatedAt",
-		})
-
+	if len(cmdFlags.enterprise) > 0 {
+		enterpriseRulesets, err := g.GatherEnterpriseRulesets(cmdFlags.enterprise)
 		if err != nil {
 			return err
 		}
+		allRulesets = append(allRulesets, enterpriseRulesets...)
+	}
 
-		if cmdFlags.ruleType == "all" || cmdFlags.ruleType == "orgOnly" {
-			zap.S().Infof("Gathering organization %s level rulesets", owner)
-			allOrgRules, err := g.FetchOrgRulesets(owner)
-			if err != nil {
-				zap.S().Error("Error raised in fetching org  ruleset data for %s", owner)
-			}
-
-			for _, singleRule := range allOrgRules {
-				zap.S().Debugf("Gathering specific ruleset data for org rule %s", singleRule.Name)
-				orgLevelRulesetResponse, err := g.GetOrgLevelRuleset(owner, singleRule.DatabaseID)
-				if err != nil {
-					zap.S().Error("Error raised in getting org level ruleset data for %s", singleRule.DatabaseID)
-					continue
-				} else {
-					var orgLevelRuleset data.RepoRuleset
-					err = json.Unmarshal(orgLevelRulesetResponse, &orgLevelRuleset)
-					if err != nil {
-						zap.S().Error("Error raised with variable response")
-						continue
-					}
-					Actors := g.ProcessActorsForExport(orgLevelRuleset.BypassActors, owner, orgID, singleRule.ID)
-					orgConditions := utils.ProcessConditions(orgLevelRuleset)
-					rulesMap := g.ProcessRules(orgLevelRuleset.Rules)
-
-					zap.S().Debugf("Writing output for org rule %s", singleRule.Name)
+	if len(owner) > 0 {
+		zap.S().Infof("Gathering repositories and/or rulesets for %s", owner)
 
-					err = csvWriter.Write([]string{
-						orgLevelRuleset.SourceType,
-						"N/A",
-						strconv.Itoa(orgLevelRuleset.ID),
-						orgLevelRuleset.Name,
-						orgLevelRuleset.Target,
-						orgLevelRuleset.Enforcement,
-						strings.Join(Actors, "|"),
-						orgConditions.IncludeRefNames,
-						orgConditions.ExcludeRefNames,
-						orgConditions.IncludeNames,
-						orgConditions.ExcludeNames,
-						orgConditions.BoolNames,
-						strings.Join(orgConditions.PropertyInclude, "|"),
-						strings.Join(orgConditions.PropertyExclude, "|"),
-						rulesMap["creation"],
-						rulesMap["update"],
-						rulesMap["deletion"],
-						rulesMap["required_linear_history"],
-						rulesMap["merge_queue"],
-						rulesMap["required_deployments"],
-						rulesMap["required_signatures"],
-						rulesMap["pull_request"],
-						rulesMap["required_status_checks"],
-						rulesMap["non_fast_forward"],
-						rulesMap["commit_message_pattern"],
-						rulesMap["commit_author_email_pattern"],
-						rulesMap["committer_email_pattern"],
-						rulesMap["branch_name_pattern"],
-						rulesMap["tag_name_pattern"],
-						rulesMap["file_path_restriction"],
-						rulesMap["max_file_path_length"],
-						rulesMap["file_extension_restriction"],
-						rulesMap["max_file_size"],
-						rulesMap["workflows"],
-						rulesMap["code_scanning"],
-						orgLevelRuleset.CreatedAt,
-						orgLevelRuleset.UpdatedAt,
-					})
-					if err != nil {
-						zap.S().Error("Error raised in writing output", zap.Error(err))
-						return err
-					}
+		orgIDData, err := g.FetchOrgId(owner)
+		if err != nil {
+			zap.S().Error("Error raised in fetching org")
+			return err
+		}
+		orgID = orgIDData.Organization.DatabaseID
 
-				}
-			}
-			zap.S().Infof("Successfully listed organization level rulesets for %s", owner)
+		orgRulesets, err := g.GatherRulesets(owner, repos, cmdFlags.ruleType)
+		if err != nil {
+			return err
 		}
+		allRulesets = append(allRulesets, orgRulesets...)
+	} else {
+		owner = cmdFlags.enterprise
+	}
 
-		if cmdFlags.ruleType == "all" || cmdFlags.ruleType == "repoOnly" {
-			zap.S().Infof("Gathering repositories specified in org %s to list rulesets for", owner)
-			allRepos, err := g.GatherRepositories(owner, repos)
-			if err != nil {
-				zap.S().Error("Error raised in gathering repos", zap.Error(err))
-				return err
-			}
-			allRepoRules, err := g.FetchRepoRulesets(owner, allRepos)
-			if err != nil {
-				zap.S().Error("Error raised in fetching repo ruleset data", zap.Error(err))
-				return err
-			}
-			for _, singleRepoRule := range allRepoRules {
-				zap.S().Infof("Gathering specific ruleset data for repo %s rule %s", singleRepoRule.RepoName, singleRepoRule.Rule.Name)
-				repoLevelRulesetResponse, err := g.GetRepoLevelRuleset(owner, singleRepoRule.RepoName, singleRepoRule.Rule.DatabaseID)
-				if err != nil {
-					zap.S().Error("Error raised in getting repo variables", zap.Error(err))
-					continue
-				}
-				var repoLevelRuleset data.RepoRuleset
-				err = json.Unmarshal(repoLevelRulesetResponse, &repoLevelRuleset)
-				if err != nil {
-					zap.S().Error("Error raised with variable response", zap.Error(err))
-					continue
-				}
-				Actors := g.ProcessActorsForExport(repoLevelRuleset.BypassActors, owner, orgID, singleRepoRule.Rule.ID)
-				repoRulesMap := g.ProcessRules(repoLevelRuleset.Rules)
+	for _, ruleset := range allRulesets {
+		utils.WarnUnsupportedRules(ruleset)
+	}
 
-				repoConditions := utils.ProcessConditions(repoLevelRuleset)
+	var err error
+
+	switch {
+	case len(cmdFlags.outputDir) > 0 && cmdFlags.format == "yaml":
+		zap.S().Infof("Writing %d rulesets to directory %s", len(allRulesets), cmdFlags.outputDir)
+		err = utils.WriteRulesetFilesToDir(allRulesets, cmdFlags.outputDir, ".yaml", g.YAMLMarshaler(owner, orgID))
+	case len(cmdFlags.outputDir) > 0:
+		zap.S().Infof("Writing %d rulesets to directory %s", len(allRulesets), cmdFlags.outputDir)
+		err = utils.WriteRulesetFilesToDir(allRulesets, cmdFlags.outputDir, ".json", utils.MarshalRulesetJSON)
+	case cmdFlags.format == "json":
+		err = utils.WriteRulesetsToJSON(allRulesets, reportWriter)
+	case cmdFlags.format == "yaml":
+		err = g.WriteRulesetsToYAML(allRulesets, owner, orgID, reportWriter)
+	default:
+		err = writeRulesetsCSV(allRulesets, owner, orgID, g, reportWriter)
+	}
+	if err != nil {
+		zap.S().Error("Error raised in writing output", zap.Error(err))
+		return err
+	}
 
-				zap.S().Debugf("Writing output for repo %s rule %s", singleRepoRule.RepoName, singleRepoRule.Rule.Name)
+	zap.S().Infof("Successfully listed all rulesets for %s", owner)
+	return nil
+}
 
-				err = csvWriter.Write([]string{
-					repoLevelRuleset.SourceType,
-					singleRepoRule.RepoName,
-					strconv.Itoa(repoLevelRuleset.ID),
-					repoLevelRuleset.Name,
-					repoLevelRuleset.Target,
-					repoLevelRuleset.Enforcement,
-					strings.Join(Actors, "|"),
-					repoConditions.IncludeRefNames,
-					repoConditions.ExcludeRefNames,
-					repoConditions.IncludeNames,
-					repoConditions.ExcludeNames,
-					repoConditions.BoolNames,
-					strings.Join(repoConditions.PropertyInclude, "|"),
-					strings.Join(repoConditions.PropertyExclude, "|"),
-					repoRulesMap["creation"],
-					repoRulesMap["update"],
-					repoRulesMap["deletion"],
-					repoRulesMap["required_linear_history"],
-					repoRulesMap["merge_queue"],
-					repoRulesMap["required_deployments"],
-					repoRulesMap["required_signatures"],
-					repoRulesMap["pull_request"],
-					repoRulesMap["required_status_checks"],
-					repoRulesMap["non_fast_forward"],
-					repoRulesMap["commit_message_pattern"],
-					repoRulesMap["commit_author_email_pattern"],
-					repoRulesMap["committer_email_pattern"],
-					repoRulesMap["branch_name_pattern"],
-					repoRulesMap["tag_name_pattern"],
-					repoRulesMap["file_path_restriction"],
-					repoRulesMap["max_file_path_length"],
-					repoRulesMap["file_extension_restriction"],
-					repoRulesMap["max_file_size"],
-					repoRulesMap["workflows"],
-					repoRulesMap["code_scanning"],
-					repoLevelRuleset.CreatedAt,
-					repoLevelRuleset.UpdatedAt,
-				})
-				if err != nil {
-					zap.S().Error("Error raised in writing output", zap.Error(err))
-					return err
-				}
-			}
-			zap.S().Infof("Successfully listed repository level rulesets for %s", owner)
-		}
+func writeRulesetsCSV(rulesets []data.RepoRuleset, owner string, orgID int, g *utils.APIGetter, reportWriter io.Writer) error {
+	csvWriter := csv.NewWriter(reportWriter)
+	err := csvWriter.Write(data.RulesetCSVHeaders)
+	if err != nil {
+		return err
+	}
 
-		zap.S().Infof("Successfully listed all rulesets for %s", owner)
-		csvWriter.Flush()
+	rows := make([][]string, len(rulesets))
+	utils.ForEachConcurrently(len(rulesets), g.Concurrency(), func(i int) {
+		zap.S().Debugf("Processing output for %s rule %s", rulesets[i].Source, rulesets[i].Name)
+		rows[i] = g.RulesetToCSVRow(rulesets[i], owner, orgID)
+	})
+	err = csvWriter.WriteAll(rows)
+	if err != nil {
+		return err
 	}
 	return nil
 }
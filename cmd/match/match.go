@@ -0,0 +1,183 @@
+package match
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/evaluate"
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token         string
+	hostname      string
+	fileName      string
+	names         []string
+	rulesetID     int
+	rulesetRepo   string
+	refs          []string
+	repositories  []string
+	properties    []string
+	defaultBranch string
+	debug         bool
+}
+
+func NewCmdMatch() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	matchCmd := &cobra.Command{
+		Use:   "match [flags] [organization]",
+		Short: "Evaluate which refs and repositories a ruleset matches.",
+		Long:  "Evaluate the ref name, repository name and repository property conditions of rulesets from a file, or of a live ruleset, against a list of refs and repositories.",
+		Args:  cobra.MaximumNArgs(1),
+		PreRunE: func(matchCmd *cobra.Command, args []string) error {
+			if len(cmdFlags.fileName) == 0 && cmdFlags.rulesetID == 0 {
+				return errors.New("a file or ruleset ID must be specified to evaluate")
+			} else if len(cmdFlags.fileName) > 0 && cmdFlags.rulesetID > 0 {
+				return errors.New("specify only one of `--from-file` or `--id`")
+			}
+			if cmdFlags.rulesetID > 0 && len(args) == 0 {
+				return errors.New("an organization must be specified to get a ruleset by ID")
+			}
+			if len(cmdFlags.refs) == 0 && len(cmdFlags.repositories) == 0 {
+				return errors.New("at least one `--ref` or `--repository` must be specified to evaluate")
+			}
+			return nil
+		},
+		RunE: func(matchCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			var owner string
+			if len(args) > 0 {
+				owner = args[0]
+			}
+			var g *utils.APIGetter
+			if cmdFlags.rulesetID > 0 {
+				authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
+				restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
+				if err != nil {
+					return err
+				}
+				g = utils.NewAPIGetter(gqlClient, restClient)
+			}
+			return runCmdMatch(owner, &cmdFlags, g)
+		},
+	}
+
+	defaultBranchDefault := "main"
+
+	matchCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token, only used with --id (default "gh auth token")`)
+	matchCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	matchCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV or JSON file of rulesets to evaluate")
+	matchCmd.Flags().StringSliceVarP(&cmdFlags.names, "name", "n", []string{}, "Names of rulesets in the file to evaluate separated by commas (default all)")
+	matchCmd.Flags().IntVarP(&cmdFlags.rulesetID, "id", "", 0, "ID of a live ruleset in the organization to evaluate")
+	matchCmd.Flags().StringVarP(&cmdFlags.rulesetRepo, "ruleset-repo", "", "", "Repository the live ruleset specified by --id belongs to, for repository level rulesets")
+	matchCmd.Flags().StringSliceVarP(&cmdFlags.refs, "ref", "", []string{}, "Ref names to evaluate separated by commas, either fully qualified (refs/heads/main) or short (main)")
+	matchCmd.Flags().StringSliceVarP(&cmdFlags.repositories, "repository", "", []string{}, "Repository names to evaluate separated by commas")
+	matchCmd.Flags().StringArrayVarP(&cmdFlags.properties, "property", "", []string{}, "Custom property value of the evaluated repositories as name=value, repeated for each value")
+	matchCmd.Flags().StringVarP(&cmdFlags.defaultBranch, "default-branch", "", defaultBranchDefault, "Default branch used to evaluate ~DEFAULT_BRANCH")
+	matchCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return matchCmd
+}
+
+func runCmdMatch(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	properties := make(map[string][]string)
+	for _, property := range cmdFlags.properties {
+		name, value, found := strings.Cut(property, "=")
+		if !found {
+			return fmt.Errorf("invalid property %s, expected name=value", property)
+		}
+		properties[name] = append(properties[name], value)
+	}
+	repos := make([]evaluate.Repository, 0, len(cmdFlags.repositories))
+	for _, repo := range cmdFlags.repositories {
+		repos = append(repos, evaluate.Repository{
+			Name:          repo,
+			DefaultBranch: cmdFlags.defaultBranch,
+			Properties:    properties,
+		})
+	}
+
+	rulesets, err := readRulesets(owner, cmdFlags, g)
+	if err != nil {
+		return err
+	}
+	if len(cmdFlags.names) > 0 {
+		names := make(map[string]struct{})
+		for _, name := range cmdFlags.names {
+			names[name] = struct{}{}
+		}
+		var selected []data.RepoRuleset
+		for _, ruleset := range rulesets {
+			if _, ok := names[ruleset.Name]; ok {
+				selected = append(selected, ruleset)
+			}
+		}
+		rulesets = selected
+	}
+	if len(rulesets) == 0 {
+		return errors.New("no rulesets found to evaluate")
+	}
+
+	var results []data.MatchResult
+	for _, ruleset := range rulesets {
+		results = append(results, utils.MatchRulesetConditions(ruleset, cmdFlags.refs, repos, cmdFlags.defaultBranch)...)
+	}
+	return utils.WriteMatchResults(results, os.Stdout)
+}
+
+func readRulesets(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) ([]data.RepoRuleset, error) {
+	if cmdFlags.rulesetID > 0 {
+		var response []byte
+		var err error
+		if len(cmdFlags.rulesetRepo) > 0 {
+			zap.S().Debugf("Getting ruleset %d for repository %s/%s", cmdFlags.rulesetID, owner, cmdFlags.rulesetRepo)
+			response, err = g.GetRepoLevelRuleset(owner, cmdFlags.rulesetRepo, cmdFlags.rulesetID)
+		} else {
+			zap.S().Debugf("Getting ruleset %d for organization %s", cmdFlags.rulesetID, owner)
+			response, err = g.GetOrgLevelRuleset(owner, cmdFlags.rulesetID)
+		}
+		if err != nil {
+			zap.S().Errorf("Error raised in getting ruleset %d", cmdFlags.rulesetID)
+			return nil, err
+		}
+		var ruleset data.RepoRuleset
+		if err := json.Unmarshal(response, &ruleset); err != nil {
+			return nil, err
+		}
+		return []data.RepoRuleset{ruleset}, nil
+	}
+
+	if utils.IsRulesetFileFormat(cmdFlags.fileName, ".json") {
+		zap.S().Debugf("Reading in rulesets from %s", cmdFlags.fileName)
+		return utils.ReadRulesetsFromJSON(owner, cmdFlags.fileName)
+	}
+	f, err := os.Open(cmdFlags.fileName)
+	if err != nil {
+		zap.S().Errorf("Error arose opening rulesets csv file")
+		return nil, err
+	}
+	defer f.Close()
+	fileData, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		zap.S().Errorf("Error arose reading rulesets from csv file")
+		return nil, err
+	}
+	rulesets, err := utils.ReadRulesetConditionsFromCSV(owner, fileData)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cmdFlags.fileName, err)
+	}
+	return rulesets, nil
+}
@@ -0,0 +1,139 @@
+package properties
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	sourceToken    string
+	sourceHostname string
+	token          string
+	hostname       string
+	outputFile     string
+	repos          []string
+	repoMap        string
+	schemaOnly     bool
+	dryRun         bool
+	debug          bool
+}
+
+func NewCmdProperties() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken, authSourceToken string
+
+	propertiesCmd := &cobra.Command{
+		Use:   "properties [flags] <source-organization> <target-organization>",
+		Short: "Copy custom properties and repository values between organizations.",
+		Long:  "Copy the custom property definitions of a source organization, and the property values of each repository, to a target organization, reporting properties that already exist with incompatible definitions.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(propertiesCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			authToken = utils.GetAuthToken(cmdFlags.token, cmdFlags.hostname)
+			restClient, gqlClient, err := utils.InitializeClients(cmdFlags.hostname, authToken)
+			if err != nil {
+				return err
+			}
+
+			authSourceToken = utils.GetAuthToken(cmdFlags.sourceToken, cmdFlags.sourceHostname)
+			restSrcClient, gqlSrcClient, err := utils.InitializeClients(cmdFlags.sourceHostname, authSourceToken)
+			if err != nil {
+				return err
+			}
+
+			if len(cmdFlags.outputFile) == 0 {
+				cmdFlags.outputFile = fmt.Sprintf("%s-properties-%s.csv", args[1], time.Now().Format("20060102150405"))
+			}
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			s := utils.NewAPIGetter(gqlSrcClient, restSrcClient)
+			if len(cmdFlags.repoMap) > 0 {
+				repoMap, err := utils.ReadRepoMap(cmdFlags.repoMap)
+				if err != nil {
+					zap.S().Errorf("Error arose reading repo map %s", cmdFlags.repoMap)
+					return err
+				}
+				g.SetRepoMap(repoMap)
+			}
+
+			return runCmdProperties(args[0], args[1], &cmdFlags, s, g)
+		},
+	}
+
+	propertiesCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub personal access token for the target organization (default "gh auth token")`)
+	propertiesCmd.PersistentFlags().StringVarP(&cmdFlags.sourceToken, "source-pat", "p", "", `GitHub personal access token for the source organization (default "gh auth token")`)
+	propertiesCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname of the target organization")
+	propertiesCmd.PersistentFlags().StringVarP(&cmdFlags.sourceHostname, "source-hostname", "", "github.com", "GitHub Enterprise Server hostname of the source organization")
+	propertiesCmd.Flags().StringVarP(&cmdFlags.outputFile, "output-file", "o", "", "Name of csv file to write the report to (default \"<target-organization>-properties-<date>.csv\")")
+	propertiesCmd.Flags().StringSliceVarP(&cmdFlags.repos, "repos", "R", []string{}, "List of repositories names to copy property values for separated by commas (i.e. repo1,repo2,repo3)")
+	propertiesCmd.Flags().StringVarP(&cmdFlags.repoMap, "repo-map", "", "", "Path and Name of CSV or YAML file mapping source repositories to target repositories, by name or regular expression")
+	propertiesCmd.Flags().BoolVarP(&cmdFlags.schemaOnly, "schema-only", "", false, "Only copy the custom property definitions, without repository values")
+	propertiesCmd.Flags().BoolVarP(&cmdFlags.dryRun, "dry-run", "", false, "Compare custom properties and report the changes without making them")
+	propertiesCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return propertiesCmd
+}
+
+func runCmdProperties(sourceOrg string, targetOrg string, cmdFlags *cmdFlags, s *utils.APIGetter, g *utils.APIGetter) error {
+	zap.S().Infof("Gathering custom properties from %s", sourceOrg)
+	sourceProperties, err := s.GetOrgPropertySchema(sourceOrg)
+	if err != nil {
+		zap.S().Errorf("Error raised in getting custom properties for %s", sourceOrg)
+		return err
+	}
+	if len(sourceProperties) == 0 {
+		zap.S().Infof("No custom properties found in %s", sourceOrg)
+		return nil
+	}
+	targetProperties, err := g.GetOrgPropertySchema(targetOrg)
+	if err != nil {
+		zap.S().Errorf("Error raised in getting custom properties for %s", targetOrg)
+		return err
+	}
+
+	results, compatible := g.MigratePropertySchema(targetOrg, sourceProperties, targetProperties, cmdFlags.dryRun)
+	if !cmdFlags.schemaOnly {
+		zap.S().Infof("Gathering repository custom property values from %s", sourceOrg)
+		values, err := s.GetAllOrgPropertyValues(sourceOrg)
+		if err != nil {
+			zap.S().Errorf("Error raised in getting custom property values for %s", sourceOrg)
+			return err
+		}
+		if len(cmdFlags.repos) > 0 {
+			var selected []data.RepoPropertyValues
+			for _, repo := range values {
+				for _, name := range cmdFlags.repos {
+					if strings.EqualFold(repo.RepositoryName, name) {
+						selected = append(selected, repo)
+						break
+					}
+				}
+			}
+			values = selected
+		}
+		results = append(results, g.MigratePropertyValues(targetOrg, values, compatible, cmdFlags.dryRun)...)
+	}
+
+	err = utils.WritePropertyMigrationToCSV(results, cmdFlags.outputFile)
+	if err != nil {
+		zap.S().Errorf("Error writing custom property report: %v", err)
+		return err
+	}
+	if cmdFlags.dryRun {
+		zap.S().Infof("Dry run complete, no custom properties were changed in org %s", targetOrg)
+	} else {
+		zap.S().Infof("Completed copying custom properties from %s to %s", sourceOrg, targetOrg)
+	}
+	zap.S().Infof("Wrote report of %d custom properties and repositories to %s", len(results), cmdFlags.outputFile)
+	return nil
+}
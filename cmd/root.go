@@ -1,8 +1,19 @@
 package cmd
 
 import (
+	actorMapCmd "github.com/katiem0/gh-migrate-rulesets/cmd/actormap"
+	applyCmd "github.com/katiem0/gh-migrate-rulesets/cmd/apply"
+	applyTemplateCmd "github.com/katiem0/gh-migrate-rulesets/cmd/applytemplate"
+	convertBranchProtectionCmd "github.com/katiem0/gh-migrate-rulesets/cmd/convertbranchprotection"
+	coverageCmd "github.com/katiem0/gh-migrate-rulesets/cmd/coverage"
 	createCmd "github.com/katiem0/gh-migrate-rulesets/cmd/create"
+	deleteCmd "github.com/katiem0/gh-migrate-rulesets/cmd/delete"
+	diffCmd "github.com/katiem0/gh-migrate-rulesets/cmd/diff"
+	insightsCmd "github.com/katiem0/gh-migrate-rulesets/cmd/insights"
 	listCmd "github.com/katiem0/gh-migrate-rulesets/cmd/list"
+	matchCmd "github.com/katiem0/gh-migrate-rulesets/cmd/match"
+	propertiesCmd "github.com/katiem0/gh-migrate-rulesets/cmd/properties"
+	validateCmd "github.com/katiem0/gh-migrate-rulesets/cmd/validate"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +27,17 @@ func NewCmdRoot() *cobra.Command {
 
 	cmdRoot.AddCommand(listCmd.NewCmdList())
 	cmdRoot.AddCommand(createCmd.NewCmdCreate())
+	cmdRoot.AddCommand(diffCmd.NewCmdDiff())
+	cmdRoot.AddCommand(applyCmd.NewCmdApply())
+	cmdRoot.AddCommand(deleteCmd.NewCmdDelete())
+	cmdRoot.AddCommand(actorMapCmd.NewCmdActorMap())
+	cmdRoot.AddCommand(convertBranchProtectionCmd.NewCmdConvertBranchProtection())
+	cmdRoot.AddCommand(coverageCmd.NewCmdCoverage())
+	cmdRoot.AddCommand(matchCmd.NewCmdMatch())
+	cmdRoot.AddCommand(validateCmd.NewCmdValidate())
+	cmdRoot.AddCommand(applyTemplateCmd.NewCmdApplyTemplate())
+	cmdRoot.AddCommand(propertiesCmd.NewCmdProperties())
+	cmdRoot.AddCommand(insightsCmd.NewCmdInsights())
 	cmdRoot.CompletionOptions.DisableDefaultCmd = true
 	cmdRoot.SetHelpCommand(&cobra.Command{
 		Use:    "no-help",
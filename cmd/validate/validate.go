@@ -0,0 +1,83 @@
+package validate
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/log"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	fileName string
+	debug    bool
+}
+
+func NewCmdValidate() *cobra.Command {
+	cmdFlags := cmdFlags{}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate [flags]",
+		Short: "Validate a ruleset csv file without creating rulesets.",
+		Long:  "Validate the header, values, parameters and rule targets of a ruleset csv file offline, reporting the row and column of every issue found.",
+		Args:  cobra.NoArgs,
+		PreRunE: func(validateCmd *cobra.Command, args []string) error {
+			if len(cmdFlags.fileName) == 0 {
+				return errors.New("a csv file must be specified to validate")
+			}
+			return nil
+		},
+		RunE: func(validateCmd *cobra.Command, args []string) error {
+			logger, _ := log.NewLogger(cmdFlags.debug)
+			defer logger.Sync() // nolint:errcheck
+			zap.ReplaceGlobals(logger)
+
+			return runCmdValidate(&cmdFlags)
+		},
+	}
+
+	validateCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV file to validate")
+	validateCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return validateCmd
+}
+
+func runCmdValidate(cmdFlags *cmdFlags) error {
+	f, err := os.Open(cmdFlags.fileName)
+	if err != nil {
+		zap.S().Errorf("Error arose opening rulesets csv file")
+		return err
+	}
+	defer f.Close()
+	csvReader := csv.NewReader(f)
+	csvReader.FieldsPerRecord = -1
+	fileData, err := csvReader.ReadAll()
+	if err != nil {
+		zap.S().Errorf("Error arose reading rulesets from csv file")
+		return err
+	}
+
+	issues := utils.ValidateRulesetsCSV(fileData)
+	if err := utils.WriteValidationIssues(cmdFlags.fileName, issues, os.Stdout); err != nil {
+		return err
+	}
+	errorCount := 0
+	for _, issue := range issues {
+		if issue.Severity == "error" {
+			errorCount++
+		}
+	}
+	rulesetCount := 0
+	if len(fileData) > 0 {
+		rulesetCount = len(fileData) - 1
+	}
+	zap.S().Infof("Validated %d rulesets in %s: %d errors, %d warnings", rulesetCount, cmdFlags.fileName, errorCount, len(issues)-errorCount)
+	if errorCount > 0 {
+		return fmt.Errorf("%s has %d errors", cmdFlags.fileName, errorCount)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package data
+
+type BranchProtectionRulesQuery struct {
+	Repository struct {
+		BranchProtectionRules struct {
+			Nodes    []BranchProtectionRule
+			PageInfo struct {
+				EndCursor   string
+				HasNextPage bool
+			}
+		} `graphql:"branchProtectionRules(first: 100, after: $endCursor)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+type BranchProtectionRule struct {
+	Pattern                        string
+	AllowsDeletions                bool
+	AllowsForcePushes              bool
+	BlocksCreations                bool
+	DismissesStaleReviews          bool
+	IsAdminEnforced                bool
+	LockAllowsFetchAndSync         bool
+	LockBranch                     bool
+	RequireLastPushApproval        bool
+	RequiredApprovingReviewCount   int
+	RequiredDeploymentEnvironments []string
+	RequiredStatusChecks           []RequiredStatusCheck
+	RequiresApprovingReviews       bool
+	RequiresCodeOwnerReviews       bool
+	RequiresCommitSignatures       bool
+	RequiresConversationResolution bool
+	RequiresDeployments            bool
+	RequiresLinearHistory          bool
+	RequiresStatusChecks           bool
+	RequiresStrictStatusChecks     bool
+	RestrictsPushes                bool
+	RestrictsReviewDismissals      bool
+	BypassForcePushAllowances      ActorAllowances `graphql:"bypassForcePushAllowances(first: 100)"`
+	BypassPullRequestAllowances    ActorAllowances `graphql:"bypassPullRequestAllowances(first: 100)"`
+	PushAllowances                 ActorAllowances `graphql:"pushAllowances(first: 100)"`
+	ReviewDismissalAllowances      ActorAllowances `graphql:"reviewDismissalAllowances(first: 100)"`
+}
+
+type RequiredStatusCheck struct {
+	Context string
+	App     *struct {
+		DatabaseID int
+		Slug       string
+	}
+}
+
+type ActorAllowances struct {
+	Nodes []struct {
+		Actor AllowanceActor
+	}
+	PageInfo struct {
+		HasNextPage bool
+	}
+}
+
+type AllowanceActor struct {
+	Typename string `graphql:"__typename"`
+	App      struct {
+		DatabaseID int
+		Slug       string
+	} `graphql:"... on App"`
+	Team struct {
+		DatabaseID int
+		Name       string
+	} `graphql:"... on Team"`
+	User struct {
+		Login string
+	} `graphql:"... on User"`
+}
+
+type RepoBranchProtection struct {
+	RepoName string
+	Rule     BranchProtectionRule
+}
+
+type UnsupportedSetting struct {
+	Repository string
+	Pattern    string
+	Setting    string
+	Detail     string
+}
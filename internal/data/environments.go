@@ -0,0 +1,60 @@
+package data
+
+type Environments struct {
+	TotalCount   int           `json:"total_count"`
+	Environments []Environment `json:"environments"`
+}
+
+type Environment struct {
+	Name                   string                      `json:"name"`
+	ProtectionRules        []EnvironmentProtectionRule `json:"protection_rules"`
+	DeploymentBranchPolicy *DeploymentBranchPolicy     `json:"deployment_branch_policy"`
+}
+
+type EnvironmentProtectionRule struct {
+	Type              string                `json:"type"`
+	WaitTimer         int                   `json:"wait_timer,omitempty"`
+	PreventSelfReview bool                  `json:"prevent_self_review,omitempty"`
+	Reviewers         []EnvironmentReviewer `json:"reviewers,omitempty"`
+}
+
+type EnvironmentReviewer struct {
+	Type     string `json:"type"`
+	Reviewer struct {
+		ID    int    `json:"id"`
+		Login string `json:"login"`
+		Slug  string `json:"slug"`
+	} `json:"reviewer"`
+}
+
+type DeploymentBranchPolicy struct {
+	ProtectedBranches    bool `json:"protected_branches"`
+	CustomBranchPolicies bool `json:"custom_branch_policies"`
+}
+
+type DeploymentBranchPolicies struct {
+	TotalCount     int            `json:"total_count"`
+	BranchPolicies []BranchPolicy `json:"branch_policies"`
+}
+
+type BranchPolicy struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+type CreateEnvironment struct {
+	WaitTimer              int                         `json:"wait_timer,omitempty"`
+	PreventSelfReview      bool                        `json:"prevent_self_review,omitempty"`
+	Reviewers              []CreateEnvironmentReviewer `json:"reviewers,omitempty"`
+	DeploymentBranchPolicy *DeploymentBranchPolicy     `json:"deployment_branch_policy,omitempty"`
+}
+
+type CreateEnvironmentReviewer struct {
+	Type string `json:"type"`
+	ID   int    `json:"id"`
+}
+
+type UserInfo struct {
+	Login string `json:"login"`
+	ID    int    `json:"id"`
+}
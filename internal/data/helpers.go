@@ -1,5 +1,63 @@
 package data
 
+// RulesetCSVSchemaVersion is written to the SchemaVersion column of ruleset csv
+// files, and is increased when columns are added or their format changes.
+const RulesetCSVSchemaVersion = 4
+
+// RulesetCSVEscapingSchemaVersion is the first schema version where special
+// characters in composite columns are escaped with a backslash.
+const RulesetCSVEscapingSchemaVersion = 3
+
+var RulesetCSVHeaders = []string{
+	"RulesetLevel",
+	"RepositoryName",
+	"RuleID",
+	"RulesetName",
+	"Target",
+	"Enforcement",
+	"BypassActors",
+	"ConditionsRefNameInclude",
+	"ConditionsRefNameExclude",
+	"ConditionsRepoNameInclude",
+	"ConditionsRepoNameExclude",
+	"ConditionsRepoNameProtected",
+	"ConditionRepoPropertyInclude",
+	"ConditionRepoPropertyExclude",
+	"ConditionsOrgNameInclude",
+	"ConditionsOrgNameExclude",
+	"ConditionsOrgID",
+	"RulesCreation",
+	"RulesUpdate",
+	"RulesDeletion",
+	"RulesRequiredLinearHistory",
+	"RulesMergeQueue",
+	"RulesRequiredDeployments",
+	"RulesRequiredSignatures",
+	"RulesPullRequest",
+	"RulesRequiredStatusChecks",
+	"RulesNonFastForward",
+	"RulesCommitMessagePattern",
+	"RulesCommitAuthorEmailPattern",
+	"RulesCommitterEmailPattern",
+	"RulesBranchNamePattern",
+	"RulesTagNamePattern",
+	"RulesFilePathRestriction",
+	"RulesFilePathLength",
+	"RulesFileExtensionRestriction",
+	"RulesMaxFileSize",
+	"RulesWorkflows",
+	"RulesCodeScanning",
+	"RulesRepositoryCreate",
+	"RulesRepositoryDelete",
+	"RulesRepositoryTransfer",
+	"RulesRepositoryName",
+	"RulesRepositoryVisibility",
+	"RulesRawJSON",
+	"CreatedAt",
+	"UpdatedAt",
+	"SchemaVersion",
+}
+
 var HeaderMap = map[string]string{
 	"RulesCreation":                 "creation",
 	"RulesUpdate":                   "update",
@@ -22,6 +80,11 @@ var HeaderMap = map[string]string{
 	"RulesMaxFileSize":              "max_file_size",
 	"RulesWorkflows":                "workflows",
 	"RulesCodeScanning":             "code_scanning",
+	"RulesRepositoryCreate":         "repository_create",
+	"RulesRepositoryDelete":         "repository_delete",
+	"RulesRepositoryTransfer":       "repository_transfer",
+	"RulesRepositoryName":           "repository_name",
+	"RulesRepositoryVisibility":     "repository_visibility",
 }
 
 var NonOmitEmptyFields = map[string][]string{
@@ -40,7 +103,9 @@ var NonOmitEmptyFields = map[string][]string{
 	"file_extension_restriction":  {"RestrictedFileExtensions"},
 	"max_file_size":               {"MaxFileSize"},
 	"workflows":                   {"Workflows"},
-	"update":                      {"update_allows_fetch_and_merge"},
+	"update":                      {"UpdateAllowsFetchAndMerge"},
+	"repository_name":             {"Negate", "Pattern"},
+	"repository_visibility":       {"Internal", "Private"},
 }
 
 type ProcessedConditions struct {
@@ -51,6 +116,8 @@ type ProcessedConditions struct {
 	PropertyExclude []string
 	IncludeRefNames string
 	ExcludeRefNames string
+	IncludeOrgNames string
+	ExcludeOrgNames string
 }
 
 var RolesMap = map[string]string{
@@ -60,3 +127,33 @@ var RolesMap = map[string]string{
 	"4": "Write",
 	"5": "Admin",
 }
+
+// KnownIntegrations are the slugs of apps that report status checks without
+// being installed in an organization, keyed by their GitHub.com app ID. Other
+// hosts give these apps different IDs.
+var KnownIntegrations = map[int]string{
+	15368: "github-actions",
+}
+
+var CoverageRuleTypes = []string{
+	"creation",
+	"update",
+	"deletion",
+	"required_linear_history",
+	"merge_queue",
+	"required_deployments",
+	"required_signatures",
+	"pull_request",
+	"required_status_checks",
+	"non_fast_forward",
+	"commit_message_pattern",
+	"commit_author_email_pattern",
+	"committer_email_pattern",
+	"branch_name_pattern",
+	"file_path_restriction",
+	"max_file_path_length",
+	"file_extension_restriction",
+	"max_file_size",
+	"workflows",
+	"code_scanning",
+}
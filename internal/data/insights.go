@@ -0,0 +1,75 @@
+package data
+
+type RuleSuite struct {
+	ID               int              `json:"id"`
+	ActorID          int              `json:"actor_id"`
+	ActorName        string           `json:"actor_name"`
+	BeforeSHA        string           `json:"before_sha"`
+	AfterSHA         string           `json:"after_sha"`
+	Ref              string           `json:"ref"`
+	RepositoryID     int              `json:"repository_id"`
+	RepositoryName   string           `json:"repository_name"`
+	PushedAt         string           `json:"pushed_at"`
+	Result           string           `json:"result"`
+	EvaluationResult string           `json:"evaluation_result"`
+	RuleEvaluations  []RuleEvaluation `json:"rule_evaluations"`
+}
+
+type RuleEvaluation struct {
+	RuleSource  RuleSource `json:"rule_source"`
+	Enforcement string     `json:"enforcement"`
+	Result      string     `json:"result"`
+	RuleType    string     `json:"rule_type"`
+	Details     string     `json:"details"`
+}
+
+type RuleSource struct {
+	Type string `json:"type"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// RulesetRef identifies a ruleset that rule suite evaluations are joined with.
+type RulesetRef struct {
+	Level          string
+	RepositoryName string
+	Name           string
+}
+
+type RuleInsight struct {
+	RulesetLevel   string `json:"ruleset_level"`
+	RepositoryName string `json:"repository_name,omitempty"`
+	RulesetID      int    `json:"ruleset_id"`
+	RulesetName    string `json:"ruleset_name"`
+	Enforcement    string `json:"enforcement"`
+	RuleType       string `json:"rule_type,omitempty"`
+	Evaluations    int    `json:"evaluations"`
+	Passes         int    `json:"passes"`
+	Failures       int    `json:"failures"`
+	Bypasses       int    `json:"bypasses"`
+}
+
+type RuleEvaluationRecord struct {
+	RuleSuiteID       int    `json:"rule_suite_id"`
+	PushedAt          string `json:"pushed_at"`
+	RepositoryName    string `json:"repository_name"`
+	Ref               string `json:"ref"`
+	ActorName         string `json:"actor_name"`
+	AfterSHA          string `json:"after_sha"`
+	SuiteResult       string `json:"suite_result"`
+	RulesetLevel      string `json:"ruleset_level"`
+	RulesetRepository string `json:"ruleset_repository,omitempty"`
+	RulesetID         int    `json:"ruleset_id"`
+	RulesetName       string `json:"ruleset_name"`
+	Enforcement       string `json:"enforcement"`
+	RuleType          string `json:"rule_type"`
+	Result            string `json:"result"`
+	Details           string `json:"details,omitempty"`
+}
+
+type RuleInsightsReport struct {
+	Owner       string                 `json:"owner"`
+	TimePeriod  string                 `json:"time_period"`
+	Summary     []RuleInsight          `json:"summary"`
+	Evaluations []RuleEvaluationRecord `json:"evaluations"`
+}
@@ -27,6 +27,11 @@ type CustomRole struct {
 	BaseRole string `json:"base_role"`
 }
 
+type OrgInfo struct {
+	Login string `json:"login"`
+	ID    int    `json:"id"`
+}
+
 type OrgIdQuery struct {
 	Organization struct {
 		DatabaseID int `json:"databaseId"`
@@ -38,9 +43,65 @@ type OrgID struct {
 }
 
 type RepoInfo struct {
-	DatabaseId int    `json:"databaseId"`
-	Name       string `json:"name"`
-	Visibility string `json:"visibility"`
+	DatabaseId       int    `json:"databaseId"`
+	Name             string `json:"name"`
+	Visibility       string `json:"visibility"`
+	DefaultBranchRef struct {
+		Name string `json:"name"`
+	} `json:"defaultBranchRef"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+		PageInfo struct {
+			HasNextPage bool `json:"hasNextPage"`
+		} `json:"pageInfo"`
+	} `json:"repositoryTopics" graphql:"repositoryTopics(first: 100)"`
+}
+
+type RepoPropertyValues struct {
+	RepositoryID   int             `json:"repository_id"`
+	RepositoryName string          `json:"repository_name"`
+	Properties     []PropertyValue `json:"properties"`
+}
+
+type PropertyValue struct {
+	PropertyName string      `json:"property_name"`
+	Value        interface{} `json:"value"`
+}
+
+type CustomProperty struct {
+	PropertyName     string      `json:"property_name,omitempty"`
+	ValueType        string      `json:"value_type"`
+	Required         bool        `json:"required"`
+	DefaultValue     interface{} `json:"default_value,omitempty"`
+	Description      string      `json:"description,omitempty"`
+	AllowedValues    []string    `json:"allowed_values,omitempty"`
+	ValuesEditableBy string      `json:"values_editable_by,omitempty"`
+}
+
+type RepoPropertyValuesUpdate struct {
+	RepositoryNames []string        `json:"repository_names"`
+	Properties      []PropertyValue `json:"properties"`
+}
+
+type PropertyMigration struct {
+	Type    string
+	Name    string
+	Action  string
+	Status  string
+	Message string
+}
+
+type RepoCoverage struct {
+	Repository    string
+	DefaultBranch string
+	Visibility    string
+	Protection    string
+	Rulesets      []string
+	Rules         map[string]string
 }
 
 type ReposQuery struct {
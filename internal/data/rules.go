@@ -1,5 +1,7 @@
 package data
 
+import "encoding/json"
+
 type BypassActor struct {
 	ActorID    *int   `json:"actor_id,omitempty"`
 	ActorType  string `json:"actor_type"`
@@ -7,9 +9,12 @@ type BypassActor struct {
 }
 
 type Conditions struct {
-	RefName            *RefPatterns      `json:"ref_name,omitempty"`
-	RepositoryName     *NamePatterns     `json:"repository_name,omitempty"`
-	RepositoryProperty *PropertyPatterns `json:"repository_property,omitempty"`
+	RefName            *RefPatterns      `json:"ref_name,omitempty" yaml:"ref_name,omitempty"`
+	RepositoryName     *NamePatterns     `json:"repository_name,omitempty" yaml:"repository_name,omitempty"`
+	RepositoryProperty *PropertyPatterns `json:"repository_property,omitempty" yaml:"repository_property,omitempty"`
+	RepositoryID       *RepoIDPatterns   `json:"repository_id,omitempty" yaml:"repository_id,omitempty"`
+	OrganizationName   *OrgNamePatterns  `json:"organization_name,omitempty" yaml:"organization_name,omitempty"`
+	OrganizationID     *OrgIDPatterns    `json:"organization_id,omitempty" yaml:"organization_id,omitempty"`
 }
 
 type CreateRuleset struct {
@@ -21,6 +26,40 @@ type CreateRuleset struct {
 	Rules        []CreateRules `json:"rules"`
 }
 
+type ExportRuleset struct {
+	ID           int           `json:"id"`
+	Name         string        `json:"name"`
+	Target       string        `json:"target"`
+	SourceType   string        `json:"source_type"`
+	Source       string        `json:"source"`
+	Enforcement  string        `json:"enforcement"`
+	Conditions   *Conditions   `json:"conditions"`
+	Rules        []CreateRules `json:"rules"`
+	BypassActors []BypassActor `json:"bypass_actors"`
+}
+
+type YAMLRuleset struct {
+	Name         string            `yaml:"name"`
+	Level        string            `yaml:"level"`
+	Repository   string            `yaml:"repository,omitempty"`
+	Target       string            `yaml:"target"`
+	Enforcement  string            `yaml:"enforcement"`
+	BypassActors []YAMLBypassActor `yaml:"bypass_actors,omitempty"`
+	Conditions   *Conditions       `yaml:"conditions,omitempty"`
+	Rules        []YAMLRule        `yaml:"rules"`
+}
+
+type YAMLBypassActor struct {
+	Type string `yaml:"type"`
+	Name string `yaml:"name"`
+	Mode string `yaml:"mode"`
+}
+
+type YAMLRule struct {
+	Type       string                 `yaml:"type"`
+	Parameters map[string]interface{} `yaml:"parameters,omitempty"`
+}
+
 type CreateRules struct {
 	Type       string      `json:"type"`
 	Parameters interface{} `json:"parameters,omitempty"`
@@ -61,6 +100,8 @@ type RepoRuleset struct {
 	Rules        []Rules       `json:"rules"`
 	CreatedAt    string        `json:"created_at"`
 	UpdatedAt    string        `json:"updated_at"`
+
+	Substitutions []Substitution `json:"-"`
 }
 
 type RepoRulesetsQuery struct {
@@ -78,28 +119,79 @@ type RepoRulesetsQuery struct {
 type Rules struct {
 	Type       string      `json:"type"`
 	Parameters *Parameters `json:"parameters,omitempty"`
+
+	RawParameters json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes a rule, keeping its raw parameters so rule types and
+// parameters missing from Parameters can be passed through unchanged. The
+// parameters of unknown rule types may not fit Parameters, so errors decoding
+// them are ignored.
+func (r *Rules) UnmarshalJSON(b []byte) error {
+	var rule struct {
+		Type       string          `json:"type"`
+		Parameters json.RawMessage `json:"parameters"`
+	}
+	if err := json.Unmarshal(b, &rule); err != nil {
+		return err
+	}
+	*r = Rules{Type: rule.Type}
+	if len(rule.Parameters) == 0 || string(rule.Parameters) == "null" {
+		return nil
+	}
+	r.RawParameters = rule.Parameters
+	var parameters Parameters
+	if err := json.Unmarshal(rule.Parameters, &parameters); err != nil {
+		for _, ruleType := range HeaderMap {
+			if ruleType == rule.Type {
+				return err
+			}
+		}
+	}
+	r.Parameters = &parameters
+	return nil
+}
+
+// RawRule is a rule written to the RulesRawJSON column of ruleset csv files.
+type RawRule struct {
+	Type       string          `json:"type"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
 }
 
 type RefPatterns struct {
-	Exclude []string `json:"exclude"`
-	Include []string `json:"include"`
+	Exclude []string `json:"exclude" yaml:"exclude"`
+	Include []string `json:"include" yaml:"include"`
 }
 
 type NamePatterns struct {
-	Exclude   []string `json:"exclude"`
-	Include   []string `json:"include"`
-	Protected bool     `json:"protected"`
+	Exclude   []string `json:"exclude" yaml:"exclude"`
+	Include   []string `json:"include" yaml:"include"`
+	Protected bool     `json:"protected" yaml:"protected"`
+}
+
+type RepoIDPatterns struct {
+	RepositoryIDs   []int    `json:"repository_ids" yaml:"repository_ids,omitempty"`
+	RepositoryNames []string `json:"-" yaml:"repository_names,omitempty"`
+}
+
+type OrgNamePatterns struct {
+	Exclude []string `json:"exclude" yaml:"exclude"`
+	Include []string `json:"include" yaml:"include"`
+}
+
+type OrgIDPatterns struct {
+	OrganizationIDs []int `json:"organization_ids" yaml:"organization_ids"`
 }
 
 type PropertyPatterns struct {
-	Exclude []PropertyPattern `json:"exclude"`
-	Include []PropertyPattern `json:"include"`
+	Exclude []PropertyPattern `json:"exclude" yaml:"exclude"`
+	Include []PropertyPattern `json:"include" yaml:"include"`
 }
 
 type PropertyPattern struct {
-	Name           string   `json:"name"`
-	Source         string   `json:"source"`
-	PropertyValues []string `json:"property_values"`
+	Name           string   `json:"name" yaml:"name"`
+	Source         string   `json:"source" yaml:"source"`
+	PropertyValues []string `json:"property_values" yaml:"property_values"`
 }
 
 type Parameters struct {
@@ -130,11 +222,14 @@ type Parameters struct {
 	RestrictedFileExtensions         []string       `json:"restricted_file_extensions,omitempty"`
 	MaxFileSize                      int            `json:"max_file_size,omitempty"`
 	CodeScanningTools                []CodeScanning `json:"code_scanning_tools,omitempty"`
+	Internal                         bool           `json:"internal,omitempty"`
+	Private                          bool           `json:"private,omitempty"`
 }
 
 type StatusChecks struct {
-	Context       string `json:"context,omitempty"`
-	IntegrationID *int   `json:"integration_id,omitempty"`
+	Context         string `json:"context,omitempty"`
+	IntegrationID   *int   `json:"integration_id,omitempty"`
+	IntegrationSlug string `json:"-"`
 }
 
 type CodeScanning struct {
@@ -153,5 +248,111 @@ type Workflows struct {
 type ErrorRulesets struct {
 	Source      string
 	RulesetName string
+	Action      string
 	Error       string
 }
+
+type RulesetDiff struct {
+	RulesetLevel   string        `json:"ruleset_level"`
+	RepositoryName string        `json:"repository_name"`
+	RulesetName    string        `json:"ruleset_name"`
+	Status         string        `json:"status"`
+	Changes        []FieldChange `json:"changes,omitempty"`
+}
+
+type FieldChange struct {
+	Field  string `json:"field"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type FlatRuleset struct {
+	RulesetLevel   string
+	RepositoryName string
+	RulesetName    string
+	Fields         map[string]string
+}
+
+type RulesetPlan struct {
+	Owner     string      `json:"owner"`
+	CreatedAt string      `json:"created_at"`
+	Entries   []PlanEntry `json:"entries"`
+}
+
+type ActorMapping struct {
+	Type     string `yaml:"type"`
+	Source   string `yaml:"source"`
+	Target   string `yaml:"target"`
+	SourceID int    `yaml:"source_id,omitempty"`
+	TargetID int    `yaml:"target_id,omitempty"`
+	Match    string `yaml:"match,omitempty"`
+}
+
+type RepoMapping struct {
+	Source string `yaml:"source"`
+	Target string `yaml:"target"`
+	Match  string `yaml:"match,omitempty"`
+}
+
+type MigrationState struct {
+	Owner     string       `json:"owner"`
+	UpdatedAt string       `json:"updated_at"`
+	Entries   []StateEntry `json:"entries"`
+}
+
+type StateEntry struct {
+	SourceID     int    `json:"source_id,omitempty"`
+	RulesetLevel string `json:"ruleset_level"`
+	Source       string `json:"source"`
+	Target       string `json:"target"`
+	RulesetName  string `json:"ruleset_name"`
+	Status       string `json:"status"`
+	TargetID     int    `json:"target_id,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+type PlanEntry struct {
+	RulesetLevel  string         `json:"ruleset_level"`
+	Source        string         `json:"source"`
+	Target        string         `json:"target"`
+	RulesetName   string         `json:"ruleset_name"`
+	SourceID      int            `json:"source_id,omitempty"`
+	Action        string         `json:"action"`
+	ExistingID    int            `json:"existing_id,omitempty"`
+	Requests      []PlanRequest  `json:"requests,omitempty"`
+	Substitutions []Substitution `json:"substitutions,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+type PlanRequest struct {
+	Method   string          `json:"method"`
+	Endpoint string          `json:"endpoint"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+type Substitution struct {
+	Field    string `json:"field"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	SourceID int    `json:"source_id"`
+	TargetID int    `json:"target_id,omitempty"`
+	Status   string `json:"status"`
+}
+
+type MatchResult struct {
+	RulesetLevel string
+	RulesetName  string
+	Kind         string
+	Value        string
+	Matched      bool
+	Unknown      bool
+	Reason       string
+}
+
+type ValidationIssue struct {
+	Row      int
+	Column   int
+	Header   string
+	Severity string
+	Message  string
+}
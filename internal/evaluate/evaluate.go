@@ -0,0 +1,388 @@
+package evaluate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+const (
+	AllPattern           = "~ALL"
+	DefaultBranchPattern = "~DEFAULT_BRANCH"
+)
+
+// Repository holds the repository details a ruleset's conditions are
+// evaluated against. An ID of 0 means the ID of the repository is not known,
+// and PropertiesUnknown means its custom property values are not known.
+type Repository struct {
+	ID                int
+	Name              string
+	DefaultBranch     string
+	Properties        map[string][]string
+	PropertiesUnknown bool
+}
+
+var (
+	patternCacheMu sync.Mutex
+	patternCache   = make(map[string]*regexp.Regexp)
+)
+
+// Match reports whether name matches an fnmatch pattern as used by ruleset
+// conditions: * and ? do not match /, ** matches across /, **/ matches zero or
+// more directories, [...] and [!...] match character classes, {a,b} matches
+// alternatives, and \ escapes the next character.
+func Match(pattern string, name string, ignoreCase bool) bool {
+	key := pattern
+	if ignoreCase {
+		key = "(?i)" + pattern
+	}
+	patternCacheMu.Lock()
+	re, ok := patternCache[key]
+	if !ok {
+		expr := "^" + translate(pattern) + "$"
+		if ignoreCase {
+			expr = "(?i)" + expr
+		}
+		var err error
+		re, err = regexp.Compile(expr)
+		if err != nil {
+			re = regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "$")
+		}
+		patternCache[key] = re
+	}
+	patternCacheMu.Unlock()
+	return re.MatchString(name)
+}
+
+func translate(pattern string) string {
+	runes := []rune(pattern)
+	var expr strings.Builder
+	braceDepth := 0
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					expr.WriteString("(?:.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := classEnd(runes, i)
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			expr.WriteString(translateClass(runes[i+1 : end]))
+			i = end
+		case '{':
+			braceDepth++
+			expr.WriteString("(?:")
+		case '}':
+			if braceDepth > 0 {
+				braceDepth--
+				expr.WriteString(")")
+			} else {
+				expr.WriteString(`\}`)
+			}
+		case ',':
+			if braceDepth > 0 {
+				expr.WriteString("|")
+			} else {
+				expr.WriteString(",")
+			}
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				expr.WriteString(regexp.QuoteMeta(string(runes[i])))
+			} else {
+				expr.WriteString(`\\`)
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	for ; braceDepth > 0; braceDepth-- {
+		expr.WriteString(")")
+	}
+	return expr.String()
+}
+
+// classEnd returns the index of the ] closing the character class opened at
+// start, or -1 when the class is not closed. A ] right after the opening [,
+// or after the ! or ^ negating the class, is a literal member of the class.
+func classEnd(runes []rune, start int) int {
+	i := start + 1
+	if i < len(runes) && (runes[i] == '!' || runes[i] == '^') {
+		i++
+	}
+	if i < len(runes) && runes[i] == ']' {
+		i++
+	}
+	for ; i < len(runes); i++ {
+		if runes[i] == '\\' {
+			i++
+		} else if runes[i] == ']' {
+			return i
+		}
+	}
+	return -1
+}
+
+// translateClass translates the members of an fnmatch character class into a
+// regular expression class, keeping ranges and quoting every other character
+// that is special inside a class. Like * and ?, a negated class does not match
+// a /.
+func translateClass(class []rune) string {
+	var expr strings.Builder
+	expr.WriteString("[")
+	if len(class) > 0 && (class[0] == '!' || class[0] == '^') {
+		expr.WriteString("^/")
+		class = class[1:]
+	}
+	for i := 0; i < len(class); i++ {
+		c := class[i]
+		if c == '\\' && i+1 < len(class) {
+			i++
+			c = class[i]
+		} else if c == '-' && i > 0 && i+1 < len(class) {
+			expr.WriteString("-")
+			continue
+		}
+		if strings.ContainsRune(`\[]^-`, c) {
+			expr.WriteString(`\`)
+		}
+		expr.WriteRune(c)
+	}
+	expr.WriteString("]")
+	return expr.String()
+}
+
+// Result is the outcome of evaluating a condition, with the reason it did or
+// did not match. Unknown is set instead of Matched when a condition could not
+// be evaluated with the details known about a repository.
+type Result struct {
+	Matched bool
+	Unknown bool
+	Reason  string
+}
+
+// EvaluateRef evaluates whether a fully qualified ref, such as refs/heads/main,
+// is targeted by ref name patterns. A nil pattern set targets every ref, as for
+// push rulesets.
+func EvaluateRef(patterns *data.RefPatterns, ref string, defaultBranch string) Result {
+	if patterns == nil {
+		return Result{Matched: true, Reason: "no ref_name condition"}
+	}
+	include, ok := matchAny(patterns.Include, ref, defaultBranch)
+	if !ok {
+		return Result{Reason: "no include pattern matches"}
+	}
+	if exclude, ok := matchAny(patterns.Exclude, ref, defaultBranch); ok {
+		return Result{Reason: "excluded by " + exclude}
+	}
+	return Result{Matched: true, Reason: "included by " + include}
+}
+
+// MatchRef reports whether a fully qualified ref is targeted by ref name
+// patterns.
+func MatchRef(patterns *data.RefPatterns, ref string, defaultBranch string) bool {
+	return EvaluateRef(patterns, ref, defaultBranch).Matched
+}
+
+func matchAny(patterns []string, ref string, defaultBranch string) (string, bool) {
+	for _, pattern := range patterns {
+		switch pattern {
+		case "":
+			continue
+		case AllPattern:
+			return pattern, true
+		case DefaultBranchPattern:
+			if len(defaultBranch) > 0 && ref == "refs/heads/"+defaultBranch {
+				return pattern, true
+			}
+		default:
+			if Match(pattern, ref, false) {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// EvaluateRepositoryName evaluates whether a repository name is targeted by
+// repository name patterns, which are matched case-insensitively. A nil
+// pattern set targets every repository.
+func EvaluateRepositoryName(patterns *data.NamePatterns, name string) Result {
+	if patterns == nil {
+		return Result{Matched: true, Reason: "no repository_name condition"}
+	}
+	include, ok := matchAnyName(patterns.Include, name)
+	if !ok {
+		return Result{Reason: "no include pattern matches"}
+	}
+	if exclude, ok := matchAnyName(patterns.Exclude, name); ok {
+		return Result{Reason: "excluded by " + exclude}
+	}
+	return Result{Matched: true, Reason: "included by " + include}
+}
+
+// MatchRepositoryName reports whether a repository name is targeted by
+// repository name patterns.
+func MatchRepositoryName(patterns *data.NamePatterns, name string) bool {
+	return EvaluateRepositoryName(patterns, name).Matched
+}
+
+func matchAnyName(patterns []string, name string) (string, bool) {
+	for _, pattern := range patterns {
+		if pattern == AllPattern || (len(pattern) > 0 && Match(pattern, name, true)) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// EvaluateRepositoryProperty evaluates whether repository property values are
+// targeted by property patterns. Every included property must match and no
+// excluded property may match. A nil pattern set targets every repository.
+func EvaluateRepositoryProperty(patterns *data.PropertyPatterns, properties map[string][]string) Result {
+	if patterns == nil {
+		return Result{Matched: true, Reason: "no repository_property condition"}
+	}
+	for _, pattern := range patterns.Include {
+		if !matchProperty(pattern, properties) {
+			return Result{Reason: fmt.Sprintf("property %s is not one of %s", pattern.Name, strings.Join(pattern.PropertyValues, ", "))}
+		}
+	}
+	for _, pattern := range patterns.Exclude {
+		if matchProperty(pattern, properties) {
+			return Result{Reason: fmt.Sprintf("excluded by property %s in %s", pattern.Name, strings.Join(pattern.PropertyValues, ", "))}
+		}
+	}
+	return Result{Matched: true, Reason: "repository properties match"}
+}
+
+// MatchRepositoryProperty reports whether repository property values are
+// targeted by property patterns.
+func MatchRepositoryProperty(patterns *data.PropertyPatterns, properties map[string][]string) bool {
+	return EvaluateRepositoryProperty(patterns, properties).Matched
+}
+
+func matchProperty(pattern data.PropertyPattern, properties map[string][]string) bool {
+	for _, value := range properties[pattern.Name] {
+		for _, propertyValue := range pattern.PropertyValues {
+			if strings.EqualFold(value, propertyValue) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EvaluateRepositoryID evaluates whether a repository is targeted by
+// repository ID patterns. A nil pattern set targets every repository, and the
+// result is unknown when the ID of the repository is not known.
+func EvaluateRepositoryID(patterns *data.RepoIDPatterns, id int) Result {
+	if patterns == nil {
+		return Result{Matched: true, Reason: "no repository_id condition"}
+	}
+	if id == 0 {
+		return Result{Unknown: true, Reason: "repository ID is not known"}
+	}
+	for _, repoID := range patterns.RepositoryIDs {
+		if repoID == id {
+			return Result{Matched: true, Reason: fmt.Sprintf("included by repository ID %d", id)}
+		}
+	}
+	return Result{Reason: fmt.Sprintf("repository ID %d is not included", id)}
+}
+
+// EvaluateRepository evaluates whether a repository is targeted by the
+// repository name, ID and property conditions of a ruleset. The result is
+// unknown when no condition rules the repository out but one of them could not
+// be evaluated.
+func EvaluateRepository(conditions *data.Conditions, repo Repository) Result {
+	if conditions == nil {
+		return Result{Matched: true, Reason: "no repository conditions"}
+	}
+	var results []Result
+	if conditions.RepositoryName != nil {
+		results = append(results, EvaluateRepositoryName(conditions.RepositoryName, repo.Name))
+	}
+	if conditions.RepositoryID != nil {
+		results = append(results, EvaluateRepositoryID(conditions.RepositoryID, repo.ID))
+	}
+	if conditions.RepositoryProperty != nil {
+		if repo.PropertiesUnknown {
+			results = append(results, Result{Unknown: true, Reason: "repository properties are not known"})
+		} else {
+			results = append(results, EvaluateRepositoryProperty(conditions.RepositoryProperty, repo.Properties))
+		}
+	}
+	if len(results) == 0 {
+		return Result{Matched: true, Reason: "no repository conditions"}
+	}
+	unknown := false
+	reasons := make([]string, 0, len(results))
+	for _, result := range results {
+		if !result.Matched && !result.Unknown {
+			return result
+		}
+		unknown = unknown || result.Unknown
+		reasons = append(reasons, result.Reason)
+	}
+	return Result{Matched: !unknown, Unknown: unknown, Reason: strings.Join(reasons, " and ")}
+}
+
+// MatchRepository reports whether a repository is targeted by the repository
+// name, ID and property conditions of a ruleset.
+func MatchRepository(conditions *data.Conditions, repo Repository) bool {
+	return EvaluateRepository(conditions, repo).Matched
+}
+
+// EvaluateRulesetRepository evaluates whether a ruleset targets a repository.
+// Repository rulesets only target their own repository, while organization
+// rulesets are evaluated against their repository conditions.
+func EvaluateRulesetRepository(ruleset data.RepoRuleset, repo Repository) Result {
+	if ruleset.SourceType == "Repository" {
+		repoName := ruleset.Source[strings.Index(ruleset.Source, "/")+1:]
+		if strings.EqualFold(repoName, repo.Name) {
+			return Result{Matched: true, Reason: "repository ruleset for " + repoName}
+		}
+		return Result{Reason: "repository ruleset for " + repoName}
+	}
+	return EvaluateRepository(ruleset.Conditions, repo)
+}
+
+// EvaluateAppliesTo evaluates whether a ruleset applies to a ref of a
+// repository. The result is unknown when the ruleset targets the ref but its
+// repository conditions could not be evaluated.
+func EvaluateAppliesTo(ruleset data.RepoRuleset, repo Repository, ref string) Result {
+	repoResult := EvaluateRulesetRepository(ruleset, repo)
+	if !repoResult.Matched && !repoResult.Unknown {
+		return repoResult
+	}
+	if ruleset.Conditions == nil {
+		return repoResult
+	}
+	if refResult := EvaluateRef(ruleset.Conditions.RefName, ref, repo.DefaultBranch); !refResult.Matched {
+		return refResult
+	}
+	return repoResult
+}
+
+// AppliesTo reports whether a ruleset applies to a ref of a repository.
+func AppliesTo(ruleset data.RepoRuleset, repo Repository, ref string) bool {
+	return EvaluateAppliesTo(ruleset, repo, ref).Matched
+}
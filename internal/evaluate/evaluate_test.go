@@ -0,0 +1,147 @@
+package evaluate
+
+import (
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		name       string
+		ignoreCase bool
+		want       bool
+	}{
+		{pattern: "refs/heads/main", name: "refs/heads/main", want: true},
+		{pattern: "refs/heads/main", name: "refs/heads/main2"},
+		{pattern: "refs/heads/*", name: "refs/heads/feature", want: true},
+		{pattern: "refs/heads/*", name: "refs/heads/feature/login"},
+		{pattern: "refs/heads/**", name: "refs/heads/feature/login", want: true},
+		{pattern: "refs/heads/**/hotfix", name: "refs/heads/hotfix", want: true},
+		{pattern: "refs/heads/**/hotfix", name: "refs/heads/release/1.0/hotfix", want: true},
+		{pattern: "refs/heads/release-?", name: "refs/heads/release-1", want: true},
+		{pattern: "refs/heads/release-?", name: "refs/heads/release-10"},
+		{pattern: "refs/heads/release?1", name: "refs/heads/release/1"},
+		{pattern: "refs/heads/v[0-9]", name: "refs/heads/v7", want: true},
+		{pattern: "refs/heads/v[0-9]", name: "refs/heads/vx"},
+		{pattern: "refs/heads/v[!0-9]", name: "refs/heads/vx", want: true},
+		{pattern: "refs/heads/v[!0-9]", name: "refs/heads/v7"},
+		{pattern: "refs/heads/v[^0-9]", name: "refs/heads/vx", want: true},
+		{pattern: "refs/heads/a[!x]b", name: "refs/heads/a/b"},
+		{pattern: "refs/heads/[]]", name: "refs/heads/]", want: true},
+		{pattern: "refs/heads/[]]", name: "refs/heads/x"},
+		{pattern: "refs/heads/[!]]", name: "refs/heads/x", want: true},
+		{pattern: "refs/heads/[!]]", name: "refs/heads/]"},
+		{pattern: "refs/heads/[a-]", name: "refs/heads/-", want: true},
+		{pattern: "refs/heads/[\\]]", name: "refs/heads/]", want: true},
+		{pattern: "refs/heads/[", name: "refs/heads/[", want: true},
+		{pattern: "refs/heads/[]", name: "refs/heads/[]", want: true},
+		{pattern: "refs/heads/{main,master}", name: "refs/heads/master", want: true},
+		{pattern: "refs/heads/{main,master}", name: "refs/heads/develop"},
+		{pattern: "refs/heads/a,b", name: "refs/heads/a,b", want: true},
+		{pattern: "refs/heads/\\*", name: "refs/heads/*", want: true},
+		{pattern: "refs/heads/\\*", name: "refs/heads/main"},
+		{pattern: "refs/heads/\\[x]", name: "refs/heads/[x]", want: true},
+		{pattern: "refs/heads/v1.0", name: "refs/heads/v1x0"},
+		{pattern: "refs/heads/café-*", name: "refs/heads/café-1", want: true},
+		{pattern: "refs/heads/[é]", name: "refs/heads/é", want: true},
+		{pattern: "API", name: "api", ignoreCase: true, want: true},
+		{pattern: "API", name: "api"},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name, tt.ignoreCase); got != tt.want {
+			t.Errorf("Match(%q, %q, %v) = %v, want %v", tt.pattern, tt.name, tt.ignoreCase, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateRef(t *testing.T) {
+	tests := []struct {
+		name          string
+		patterns      *data.RefPatterns
+		ref           string
+		defaultBranch string
+		want          bool
+	}{
+		{name: "no condition", patterns: nil, ref: "refs/heads/main", want: true},
+		{name: "all", patterns: &data.RefPatterns{Include: []string{"~ALL"}}, ref: "refs/tags/v1", want: true},
+		{name: "default branch", patterns: &data.RefPatterns{Include: []string{"~DEFAULT_BRANCH"}}, ref: "refs/heads/trunk", defaultBranch: "trunk", want: true},
+		{name: "other branch", patterns: &data.RefPatterns{Include: []string{"~DEFAULT_BRANCH"}}, ref: "refs/heads/main", defaultBranch: "trunk"},
+		{name: "unknown default branch", patterns: &data.RefPatterns{Include: []string{"~DEFAULT_BRANCH"}}, ref: "refs/heads/"},
+		{name: "excluded", patterns: &data.RefPatterns{Include: []string{"~ALL"}, Exclude: []string{"refs/heads/dependabot/**"}}, ref: "refs/heads/dependabot/npm/x"},
+		{name: "exclude default branch", patterns: &data.RefPatterns{Include: []string{"refs/heads/*"}, Exclude: []string{"~DEFAULT_BRANCH"}}, ref: "refs/heads/main", defaultBranch: "main"},
+		{name: "empty include", patterns: &data.RefPatterns{Include: []string{""}}, ref: "refs/heads/main"},
+		{name: "no include", patterns: &data.RefPatterns{}, ref: "refs/heads/main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluateRef(tt.patterns, tt.ref, tt.defaultBranch)
+			if result.Matched != tt.want {
+				t.Errorf("EvaluateRef(%q) = %+v, want matched %v", tt.ref, result, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateRepository(t *testing.T) {
+	repo := Repository{ID: 10, Name: "API", Properties: map[string][]string{"team": {"Web"}}}
+	tests := []struct {
+		name       string
+		conditions *data.Conditions
+		repo       Repository
+		matched    bool
+		unknown    bool
+	}{
+		{name: "no conditions", repo: repo, matched: true},
+		{name: "name", conditions: &data.Conditions{RepositoryName: &data.NamePatterns{Include: []string{"api*"}}}, repo: repo, matched: true},
+		{name: "name excluded", conditions: &data.Conditions{RepositoryName: &data.NamePatterns{Include: []string{"~ALL"}, Exclude: []string{"api"}}}, repo: repo},
+		{name: "property", conditions: &data.Conditions{RepositoryProperty: &data.PropertyPatterns{Include: []data.PropertyPattern{{Name: "team", PropertyValues: []string{"web"}}}}}, repo: repo, matched: true},
+		{name: "property excluded", conditions: &data.Conditions{RepositoryProperty: &data.PropertyPatterns{Exclude: []data.PropertyPattern{{Name: "team", PropertyValues: []string{"web"}}}}}, repo: repo},
+		{name: "property unknown", conditions: &data.Conditions{RepositoryProperty: &data.PropertyPatterns{Include: []data.PropertyPattern{{Name: "team", PropertyValues: []string{"web"}}}}}, repo: Repository{Name: "api", PropertiesUnknown: true}, unknown: true},
+		{name: "property unknown and name excluded", conditions: &data.Conditions{
+			RepositoryName:     &data.NamePatterns{Include: []string{"web"}},
+			RepositoryProperty: &data.PropertyPatterns{Include: []data.PropertyPattern{{Name: "team", PropertyValues: []string{"web"}}}},
+		}, repo: Repository{Name: "api", PropertiesUnknown: true}},
+		{name: "id", conditions: &data.Conditions{RepositoryID: &data.RepoIDPatterns{RepositoryIDs: []int{9, 10}}}, repo: repo, matched: true},
+		{name: "id not included", conditions: &data.Conditions{RepositoryID: &data.RepoIDPatterns{RepositoryIDs: []int{9}}}, repo: repo},
+		{name: "id unknown", conditions: &data.Conditions{RepositoryID: &data.RepoIDPatterns{RepositoryIDs: []int{10}}}, repo: Repository{Name: "api"}, unknown: true},
+		{name: "id unknown and name excluded", conditions: &data.Conditions{
+			RepositoryName: &data.NamePatterns{Include: []string{"web"}},
+			RepositoryID:   &data.RepoIDPatterns{RepositoryIDs: []int{10}},
+		}, repo: Repository{Name: "api"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluateRepository(tt.conditions, tt.repo)
+			if result.Matched != tt.matched || result.Unknown != tt.unknown {
+				t.Errorf("EvaluateRepository = %+v, want matched %v and unknown %v", result, tt.matched, tt.unknown)
+			}
+		})
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	repo := Repository{Name: "api", DefaultBranch: "main"}
+	orgRuleset := data.RepoRuleset{
+		SourceType: "Organization",
+		Conditions: &data.Conditions{
+			RefName:        &data.RefPatterns{Include: []string{"~DEFAULT_BRANCH"}},
+			RepositoryName: &data.NamePatterns{Include: []string{"~ALL"}},
+		},
+	}
+	if !AppliesTo(orgRuleset, repo, "refs/heads/main") {
+		t.Error("organization ruleset does not apply to the default branch")
+	}
+	if AppliesTo(orgRuleset, repo, "refs/heads/feature") {
+		t.Error("organization ruleset applies to another branch")
+	}
+
+	repoRuleset := data.RepoRuleset{SourceType: "Repository", Source: "org/api"}
+	if !AppliesTo(repoRuleset, repo, "refs/heads/feature") {
+		t.Error("repository ruleset without conditions does not apply to its repository")
+	}
+	if AppliesTo(repoRuleset, Repository{Name: "web"}, "refs/heads/main") {
+		t.Error("repository ruleset applies to another repository")
+	}
+}
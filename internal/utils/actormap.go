@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"gopkg.in/yaml.v3"
+)
+
+// ActorMapHeaders are the columns of an actor map csv file. Only Type, Source
+// and Target are required when reading one.
+var ActorMapHeaders = []string{"Type", "Source", "Target", "SourceID", "TargetID", "Match"}
+
+// ActorMap holds bypass actor overrides keyed by actor type and source name or
+// ID, mapping to a target name or ID.
+type ActorMap struct {
+	targets map[string]string
+}
+
+func actorMapKey(actorType string, source string) string {
+	return fmt.Sprintf("%s|%s", actorType, source)
+}
+
+// Lookup returns the target name or ID mapped to the source name or ID of an
+// actor. A nil ActorMap has no overrides.
+func (m *ActorMap) Lookup(actorType string, source string) (string, bool) {
+	if m == nil || len(source) == 0 {
+		return "", false
+	}
+	target, ok := m.targets[actorMapKey(actorType, source)]
+	return target, ok
+}
+
+// ReadActorMap reads actor overrides from a csv or yaml file. Mappings without
+// a target are ignored, so a generated file can be used before it is complete.
+func ReadActorMap(fileName string) (*ActorMap, error) {
+	var mappings []data.ActorMapping
+	switch filepath.Ext(fileName) {
+	case ".yaml", ".yml":
+		yamlData, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read actor map: %w", err)
+		}
+		if err := yaml.Unmarshal(yamlData, &mappings); err != nil {
+			return nil, fmt.Errorf("failed to parse actor map: %w", err)
+		}
+	default:
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open actor map: %w", err)
+		}
+		defer f.Close()
+		records, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read actor map: %w", err)
+		}
+		if len(records) == 0 {
+			return nil, fmt.Errorf("actor map %s is empty", fileName)
+		}
+		columns := make(map[string]int)
+		for i, header := range records[0] {
+			columns[header] = i
+		}
+		for _, header := range []string{"Type", "Source", "Target"} {
+			if _, ok := columns[header]; !ok {
+				return nil, fmt.Errorf("actor map %s is missing the %s column", fileName, header)
+			}
+		}
+		for _, record := range records[1:] {
+			mappings = append(mappings, data.ActorMapping{
+				Type:   record[columns["Type"]],
+				Source: record[columns["Source"]],
+				Target: record[columns["Target"]],
+			})
+		}
+	}
+
+	actorMap := &ActorMap{targets: make(map[string]string)}
+	for _, mapping := range mappings {
+		if len(mapping.Target) == 0 {
+			continue
+		}
+		actorMap.targets[actorMapKey(mapping.Type, mapping.Source)] = mapping.Target
+	}
+	return actorMap, nil
+}
+
+// WriteActorMap writes actor mappings to a csv file, or a yaml file when the
+// file name has a yaml extension.
+func WriteActorMap(mappings []data.ActorMapping, fileName string) error {
+	if ext := filepath.Ext(fileName); ext == ".yaml" || ext == ".yml" {
+		yamlData, err := yaml.Marshal(mappings)
+		if err != nil {
+			return fmt.Errorf("failed to marshal actor map: %w", err)
+		}
+		return os.WriteFile(fileName, yamlData, 0644)
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write(ActorMapHeaders); err != nil {
+		return fmt.Errorf("failed to write headers: %w", err)
+	}
+	for _, mapping := range mappings {
+		var targetID string
+		if mapping.TargetID != 0 {
+			targetID = strconv.Itoa(mapping.TargetID)
+		}
+		record := []string{
+			mapping.Type,
+			mapping.Source,
+			mapping.Target,
+			strconv.Itoa(mapping.SourceID),
+			targetID,
+			mapping.Match,
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/shurcooL/graphql"
+	"go.uber.org/zap"
+)
+
+func (g *APIGetter) GetBranchProtectionRules(owner string, repo string, endCursor *string) (*data.BranchProtectionRulesQuery, error) {
+	query := new(data.BranchProtectionRulesQuery)
+	variables := map[string]interface{}{
+		"endCursor": (*graphql.String)(endCursor),
+		"owner":     graphql.String(owner),
+		"name":      graphql.String(repo),
+	}
+
+	err := g.gqlClient.Query("getBranchProtectionRules", &query, variables)
+
+	return query, err
+}
+
+func (g *APIGetter) FetchBranchProtectionRules(owner string, repos []data.RepoInfo) ([]data.RepoBranchProtection, error) {
+	var allRules []data.RepoBranchProtection
+	repoRules := make([][]data.RepoBranchProtection, len(repos))
+	repoErrors := make([]error, len(repos))
+
+	ForEachConcurrently(len(repos), g.concurrency, func(i int) {
+		var cursor *string
+		repo := repos[i]
+		zap.S().Debugf("Checking for branch protection rules in repo %s", repo.Name)
+		for {
+			rulesQuery, err := g.GetBranchProtectionRules(owner, repo.Name, cursor)
+			if err != nil {
+				repoErrors[i] = err
+				return
+			}
+			for _, rule := range rulesQuery.Repository.BranchProtectionRules.Nodes {
+				if truncated := truncatedAllowances(rule); len(truncated) > 0 {
+					repoErrors[i] = fmt.Errorf("branch protection for %s in %s has more than 100 %s, which cannot all be converted", rule.Pattern, repo.Name, strings.Join(truncated, ", "))
+					return
+				}
+				repoRules[i] = append(repoRules[i], data.RepoBranchProtection{RepoName: repo.Name, Rule: rule})
+			}
+			cursor = &rulesQuery.Repository.BranchProtectionRules.PageInfo.EndCursor
+			if !rulesQuery.Repository.BranchProtectionRules.PageInfo.HasNextPage {
+				break
+			}
+		}
+	})
+
+	for i := range repos {
+		if repoErrors[i] != nil {
+			return nil, repoErrors[i]
+		}
+		allRules = append(allRules, repoRules[i]...)
+	}
+	return allRules, nil
+}
+
+// truncatedAllowances returns the allowance connections of a branch protection
+// rule that have more actors than the query fetched, as converting them would
+// silently drop bypass actors.
+func truncatedAllowances(rule data.BranchProtectionRule) []string {
+	var truncated []string
+	if rule.BypassForcePushAllowances.PageInfo.HasNextPage {
+		truncated = append(truncated, "bypassForcePushAllowances")
+	}
+	if rule.BypassPullRequestAllowances.PageInfo.HasNextPage {
+		truncated = append(truncated, "bypassPullRequestAllowances")
+	}
+	if rule.PushAllowances.PageInfo.HasNextPage {
+		truncated = append(truncated, "pushAllowances")
+	}
+	if rule.ReviewDismissalAllowances.PageInfo.HasNextPage {
+		truncated = append(truncated, "reviewDismissalAllowances")
+	}
+	return truncated
+}
+
+// ConvertBranchProtection translates a classic branch protection rule into an
+// equivalent repository ruleset, returning the settings that have no ruleset
+// equivalent or whose behavior changes.
+func ConvertBranchProtection(owner string, repoName string, rule data.BranchProtectionRule, enforcement string) (data.RepoRuleset, []data.UnsupportedSetting) {
+	source := fmt.Sprintf("%s/%s", owner, repoName)
+	ruleset := data.RepoRuleset{
+		Name:        fmt.Sprintf("Branch protection for %s", rule.Pattern),
+		Target:      "branch",
+		SourceType:  "Repository",
+		Source:      source,
+		Enforcement: enforcement,
+		Conditions: &data.Conditions{
+			RefName: &data.RefPatterns{
+				Include: []string{"refs/heads/" + rule.Pattern},
+				Exclude: []string{},
+			},
+		},
+	}
+	var unsupported []data.UnsupportedSetting
+	report := func(setting string, detail string) {
+		unsupported = append(unsupported, data.UnsupportedSetting{Repository: source, Pattern: rule.Pattern, Setting: setting, Detail: detail})
+	}
+
+	if rule.BlocksCreations {
+		ruleset.Rules = append(ruleset.Rules, data.Rules{Type: "creation"})
+	}
+	if !rule.AllowsDeletions {
+		ruleset.Rules = append(ruleset.Rules, data.Rules{Type: "deletion"})
+	}
+	if !rule.AllowsForcePushes {
+		ruleset.Rules = append(ruleset.Rules, data.Rules{Type: "non_fast_forward"})
+	}
+	if rule.LockBranch {
+		ruleset.Rules = append(ruleset.Rules, data.Rules{
+			Type:       "update",
+			Parameters: &data.Parameters{UpdateAllowsFetchAndMerge: rule.LockAllowsFetchAndSync},
+		})
+	}
+	if rule.RequiresLinearHistory {
+		ruleset.Rules = append(ruleset.Rules, data.Rules{Type: "required_linear_history"})
+	}
+	if rule.RequiresDeployments {
+		ruleset.Rules = append(ruleset.Rules, data.Rules{
+			Type:       "required_deployments",
+			Parameters: &data.Parameters{RequiredDeploymentEnvironments: rule.RequiredDeploymentEnvironments},
+		})
+	}
+	if rule.RequiresCommitSignatures {
+		ruleset.Rules = append(ruleset.Rules, data.Rules{Type: "required_signatures"})
+	}
+	if rule.RequiresApprovingReviews {
+		ruleset.Rules = append(ruleset.Rules, data.Rules{
+			Type: "pull_request",
+			Parameters: &data.Parameters{
+				RequiredApprovingReviewCount:   rule.RequiredApprovingReviewCount,
+				DismissStaleReviewsOnPush:      rule.DismissesStaleReviews,
+				RequireCodeOwnerReview:         rule.RequiresCodeOwnerReviews,
+				RequireLastPushApproval:        rule.RequireLastPushApproval,
+				RequiredReviewThreadResolution: rule.RequiresConversationResolution,
+			},
+		})
+	} else if rule.RequiresConversationResolution {
+		report("requiresConversationResolution", "Conversation resolution is part of the pull_request rule, which would also require pull requests")
+	}
+	if rule.RequiresStatusChecks {
+		statusChecks := make([]data.StatusChecks, 0, len(rule.RequiredStatusChecks))
+		for _, check := range rule.RequiredStatusChecks {
+			statusCheck := data.StatusChecks{Context: check.Context}
+			if check.App != nil {
+				integrationID := check.App.DatabaseID
+				statusCheck.IntegrationID = &integrationID
+			}
+			statusChecks = append(statusChecks, statusCheck)
+		}
+		ruleset.Rules = append(ruleset.Rules, data.Rules{
+			Type: "required_status_checks",
+			Parameters: &data.Parameters{
+				RequiredStatusChecks:             statusChecks,
+				StrictRequiredStatusChecksPolicy: rule.RequiresStrictStatusChecks,
+			},
+		})
+	}
+
+	if !rule.IsAdminEnforced {
+		orgAdminID, repoAdminID := 1, 5
+		ruleset.BypassActors = append(ruleset.BypassActors,
+			data.BypassActor{ActorID: &orgAdminID, ActorType: "OrganizationAdmin", BypassMode: "always"},
+			data.BypassActor{ActorID: &repoAdminID, ActorType: "RepositoryRole", BypassMode: "always"},
+		)
+	}
+	bypassActors := make(map[string]struct{})
+	allowanceSettings := []string{"bypassPullRequestAllowances", "bypassForcePushAllowances"}
+	for i, allowances := range []data.ActorAllowances{rule.BypassPullRequestAllowances, rule.BypassForcePushAllowances} {
+		setting := allowanceSettings[i]
+		for _, node := range allowances.Nodes {
+			actor := node.Actor
+			var bypassActor data.BypassActor
+			switch actor.Typename {
+			case "Team":
+				teamID := actor.Team.DatabaseID
+				bypassActor = data.BypassActor{ActorID: &teamID, ActorType: "Team", BypassMode: "always"}
+			case "App":
+				appID := actor.App.DatabaseID
+				bypassActor = data.BypassActor{ActorID: &appID, ActorType: "Integration", BypassMode: "always"}
+			default:
+				report(setting, fmt.Sprintf("%s %s cannot be a ruleset bypass actor", actor.Typename, allowanceActorName(actor)))
+				continue
+			}
+			key := fmt.Sprintf("%s|%d", bypassActor.ActorType, *bypassActor.ActorID)
+			if _, ok := bypassActors[key]; ok {
+				continue
+			}
+			bypassActors[key] = struct{}{}
+			report(setting, fmt.Sprintf("%s %s added as a bypass actor for every rule in the ruleset", actor.Typename, allowanceActorName(actor)))
+			ruleset.BypassActors = append(ruleset.BypassActors, bypassActor)
+		}
+	}
+
+	if rule.RestrictsPushes {
+		report("restrictsPushes", fmt.Sprintf("Push restrictions for %d actors have no ruleset equivalent", len(rule.PushAllowances.Nodes)))
+	}
+	if rule.RestrictsReviewDismissals {
+		report("restrictsReviewDismissals", fmt.Sprintf("Review dismissal restrictions for %d actors have no ruleset equivalent", len(rule.ReviewDismissalAllowances.Nodes)))
+	}
+	return ruleset, unsupported
+}
+
+func allowanceActorName(actor data.AllowanceActor) string {
+	switch actor.Typename {
+	case "Team":
+		return actor.Team.Name
+	case "App":
+		return actor.App.Slug
+	case "User":
+		return actor.User.Login
+	}
+	return ""
+}
+
+func WriteUnsupportedSettingsToCSV(unsupported []data.UnsupportedSetting, fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{"Repository", "Pattern", "Setting", "Detail"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("failed to write headers: %w", err)
+	}
+	for _, setting := range unsupported {
+		record := []string{setting.Repository, setting.Pattern, setting.Setting, setting.Detail}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+	return nil
+}
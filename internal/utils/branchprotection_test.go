@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func TestFetchBranchProtectionRulesTruncatedAllowances(t *testing.T) {
+	tests := []struct {
+		name    string
+		hasNext bool
+		wantErr string
+	}{
+		{name: "complete allowances", hasNext: false},
+		{name: "truncated allowances", hasNext: true, wantErr: "more than 100 pushAllowances"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+				hasNext := "false"
+				if tt.hasNext {
+					hasNext = "true"
+				}
+				w.Write([]byte(`{"data":{"repository":{"branchProtectionRules":{"nodes":[{"pattern":"main",` + // nolint:errcheck
+					`"pushAllowances":{"nodes":[],"pageInfo":{"hasNextPage":` + hasNext + `}}}],` +
+					`"pageInfo":{"endCursor":"","hasNextPage":false}}}}}`))
+			})
+			g := newTestGetter(t, mux)
+
+			rules, err := g.FetchBranchProtectionRules("org", []data.RepoInfo{{Name: "repo"}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(rules) != 1 || rules[0].Rule.Pattern != "main" {
+					t.Errorf("rules = %+v, want the main rule", rules)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/evaluate"
+	"go.uber.org/zap"
+)
+
+var enforcementRank = map[string]int{
+	"disabled": 1,
+	"evaluate": 2,
+	"active":   3,
+}
+
+func (g *APIGetter) GetOrgPropertyValuesPage(owner string, page int) ([]data.RepoPropertyValues, error) {
+	url := fmt.Sprintf("orgs/%s/properties/values?per_page=100&page=%d", owner, page)
+
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var values []data.RepoPropertyValues
+	err = json.Unmarshal(responseData, &values)
+	return values, err
+}
+
+// GetAllOrgPropertyValues returns the custom property values of every
+// repository in an organization, as returned by the API.
+func (g *APIGetter) GetAllOrgPropertyValues(owner string) ([]data.RepoPropertyValues, error) {
+	var allValues []data.RepoPropertyValues
+	for page := 1; ; page++ {
+		zap.S().Debugf("Gathering page %d of custom property values for %s", page, owner)
+		values, err := g.GetOrgPropertyValuesPage(owner, page)
+		if err != nil {
+			return nil, err
+		}
+		allValues = append(allValues, values...)
+		if len(values) < 100 {
+			break
+		}
+	}
+	return allValues, nil
+}
+
+// GetOrgPropertyValues returns the custom property values of every repository
+// in an organization, keyed by repository name and property name.
+func (g *APIGetter) GetOrgPropertyValues(owner string) (map[string]map[string][]string, error) {
+	values, err := g.GetAllOrgPropertyValues(owner)
+	if err != nil {
+		return nil, err
+	}
+	propertyValues := make(map[string]map[string][]string)
+	for _, repo := range values {
+		properties := make(map[string][]string)
+		for _, property := range repo.Properties {
+			properties[property.PropertyName] = propertyValueStrings(property.Value)
+		}
+		propertyValues[strings.ToLower(repo.RepositoryName)] = properties
+	}
+	return propertyValues, nil
+}
+
+// propertyValueStrings converts a custom property value, which is a string for
+// single value properties or an array for multi select properties, to a list.
+func propertyValueStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	}
+	return nil
+}
+
+// BuildRepoCoverage evaluates which branch and push rulesets apply to the
+// default branch of each repository, recording the strongest enforcement of
+// each rule type and whether the repository has any active protection.
+// Rulesets whose conditions cannot be evaluated for a repository are listed as
+// unknown, and the protection of a repository without an active ruleset is
+// unknown when any of them might apply. A nil propertyValues means the custom
+// property values could not be fetched, so property conditions are unknown.
+func BuildRepoCoverage(repos []data.RepoInfo, rulesets []data.RepoRuleset, propertyValues map[string]map[string][]string) []data.RepoCoverage {
+	coverage := make([]data.RepoCoverage, 0, len(repos))
+	for _, repo := range repos {
+		defaultBranch := repo.DefaultBranchRef.Name
+		evalRepo := evaluate.Repository{
+			ID:                repo.DatabaseId,
+			Name:              repo.Name,
+			DefaultBranch:     defaultBranch,
+			Properties:        propertyValues[strings.ToLower(repo.Name)],
+			PropertiesUnknown: propertyValues == nil,
+		}
+		repoCoverage := data.RepoCoverage{
+			Repository:    repo.Name,
+			DefaultBranch: defaultBranch,
+			Visibility:    repo.Visibility,
+			Protection:    "none",
+			Rules:         make(map[string]string),
+		}
+		strongest := 0
+		unknown := false
+		for _, ruleset := range rulesets {
+			if ruleset.Target != "branch" && ruleset.Target != "push" {
+				continue
+			}
+			if len(defaultBranch) == 0 {
+				continue
+			}
+			result := evaluate.EvaluateAppliesTo(ruleset, evalRepo, "refs/heads/"+defaultBranch)
+			if result.Unknown {
+				unknown = true
+				repoCoverage.Rulesets = append(repoCoverage.Rulesets, fmt.Sprintf("%s (%s, unknown: %s)", ruleset.Name, ruleset.Enforcement, result.Reason))
+				continue
+			}
+			if !result.Matched {
+				continue
+			}
+			rank := enforcementRank[ruleset.Enforcement]
+			repoCoverage.Rulesets = append(repoCoverage.Rulesets, fmt.Sprintf("%s (%s)", ruleset.Name, ruleset.Enforcement))
+			for _, rule := range ruleset.Rules {
+				if rank > enforcementRank[repoCoverage.Rules[rule.Type]] {
+					repoCoverage.Rules[rule.Type] = ruleset.Enforcement
+				}
+			}
+			if len(ruleset.Rules) > 0 && rank > strongest {
+				strongest = rank
+			}
+		}
+		switch strongest {
+		case enforcementRank["active"]:
+			repoCoverage.Protection = "active"
+		case enforcementRank["evaluate"]:
+			repoCoverage.Protection = "evaluate only"
+		}
+		if unknown && strongest != enforcementRank["active"] {
+			repoCoverage.Protection = "unknown"
+		}
+		coverage = append(coverage, repoCoverage)
+	}
+	return coverage
+}
+
+func WriteCoverageToCSV(coverage []data.RepoCoverage, fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{"Repository", "DefaultBranch", "Visibility", "Protection", "Rulesets"}
+	headers = append(headers, data.CoverageRuleTypes...)
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("failed to write headers: %w", err)
+	}
+	for _, repo := range coverage {
+		record := []string{repo.Repository, repo.DefaultBranch, repo.Visibility, repo.Protection, strings.Join(repo.Rulesets, "|")}
+		for _, ruleType := range data.CoverageRuleTypes {
+			record = append(record, repo.Rules[ruleType])
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func TestBuildRepoCoverageRepositoryID(t *testing.T) {
+	repo := func(id int, name string) data.RepoInfo {
+		repoInfo := data.RepoInfo{DatabaseId: id, Name: name}
+		repoInfo.DefaultBranchRef.Name = "main"
+		return repoInfo
+	}
+	repos := []data.RepoInfo{repo(10, "api"), repo(11, "web"), repo(0, "new")}
+	rulesets := []data.RepoRuleset{{
+		Name:        "by id",
+		Target:      "branch",
+		SourceType:  "Organization",
+		Source:      "org",
+		Enforcement: "active",
+		Conditions: &data.Conditions{
+			RefName:      &data.RefPatterns{Include: []string{"~DEFAULT_BRANCH"}},
+			RepositoryID: &data.RepoIDPatterns{RepositoryIDs: []int{10}},
+		},
+		Rules: []data.Rules{{Type: "deletion"}},
+	}}
+
+	coverage := BuildRepoCoverage(repos, rulesets, nil)
+	want := map[string]string{"api": "active", "web": "none", "new": "unknown"}
+	for _, repoCoverage := range coverage {
+		if repoCoverage.Protection != want[repoCoverage.Repository] {
+			t.Errorf("%s protection = %s, want %s", repoCoverage.Repository, repoCoverage.Protection, want[repoCoverage.Repository])
+		}
+		if repoCoverage.Repository == "web" && len(repoCoverage.Rulesets) != 0 {
+			t.Errorf("web rulesets = %v, want none", repoCoverage.Rulesets)
+		}
+	}
+}
+
+func TestBuildRepoCoveragePropertyValuesUnknown(t *testing.T) {
+	repoInfo := data.RepoInfo{DatabaseId: 10, Name: "api"}
+	repoInfo.DefaultBranchRef.Name = "main"
+	rulesets := []data.RepoRuleset{{
+		Name:        "by property",
+		Target:      "branch",
+		SourceType:  "Organization",
+		Source:      "org",
+		Enforcement: "active",
+		Conditions: &data.Conditions{
+			RefName:            &data.RefPatterns{Include: []string{"~DEFAULT_BRANCH"}},
+			RepositoryProperty: &data.PropertyPatterns{Include: []data.PropertyPattern{{Name: "team", PropertyValues: []string{"web"}}}},
+		},
+		Rules: []data.Rules{{Type: "deletion"}},
+	}}
+
+	tests := []struct {
+		name           string
+		propertyValues map[string]map[string][]string
+		want           string
+	}{
+		{name: "fetched", propertyValues: map[string]map[string][]string{"api": {"team": {"web"}}}, want: "active"},
+		{name: "not set", propertyValues: map[string]map[string][]string{}, want: "none"},
+		{name: "not fetched", want: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coverage := BuildRepoCoverage([]data.RepoInfo{repoInfo}, rulesets, tt.propertyValues)
+			if coverage[0].Protection != tt.want {
+				t.Errorf("protection = %s, want %s", coverage[0].Protection, tt.want)
+			}
+		})
+	}
+}
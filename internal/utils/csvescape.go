@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"strings"
+)
+
+// csvSpecialChars are the characters that structure composite csv cells, such
+// as bypass actors, conditions and rule parameters. They are escaped with a
+// backslash when they appear in a value.
+const csvSpecialChars = `\|;:={}[]`
+
+// EscapeCSVValue escapes the characters of a value that would otherwise be
+// read as part of the structure of a composite csv cell.
+func EscapeCSVValue(value string) string {
+	return escapeChars(value, csvSpecialChars)
+}
+
+// escapeCSVListItem escapes a value written to a [item item] list, where
+// spaces also separate items.
+func escapeCSVListItem(value string) string {
+	return escapeChars(value, csvSpecialChars+" ")
+}
+
+func escapeChars(value string, chars string) string {
+	if !strings.ContainsAny(value, chars) {
+		return value
+	}
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		if strings.IndexByte(chars, value[i]) >= 0 {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(value[i])
+	}
+	return escaped.String()
+}
+
+// UnescapeCSVValue reverses EscapeCSVValue, removing the backslash before each
+// escaped character. A trailing backslash is kept as is.
+func UnescapeCSVValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var unescaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		unescaped.WriteByte(value[i])
+	}
+	return unescaped.String()
+}
+
+// JoinCSVValues escapes each value and joins them with sep.
+func JoinCSVValues(values []string, sep string) string {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = EscapeCSVValue(value)
+	}
+	return strings.Join(escaped, sep)
+}
+
+// SplitEscaped splits a composite csv cell on each sep that is not escaped or
+// inside braces. The parts are returned still escaped, so they can be split
+// further.
+func SplitEscaped(value string, sep byte) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				parts = append(parts, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// SplitCSVValue splits a composite csv cell on sep and unescapes each part.
+func SplitCSVValue(value string, sep byte) []string {
+	parts := SplitEscaped(value, sep)
+	for i, part := range parts {
+		parts[i] = UnescapeCSVValue(part)
+	}
+	return parts
+}
+
+// CutEscaped slices value around the first sep that is not escaped or inside
+// braces, returning the parts still escaped.
+func CutEscaped(value string, sep byte) (string, string, bool) {
+	parts := SplitEscaped(value, sep)
+	if len(parts) < 2 {
+		return value, "", false
+	}
+	return parts[0], value[len(parts[0])+1:], true
+}
+
+// trimEscapedDelimiters removes the open and close delimiters around a value,
+// such as the braces around a group or the brackets around a list, reporting
+// whether both were present and not escaped.
+func trimEscapedDelimiters(value string, open byte, close byte) (string, bool) {
+	if len(value) < 2 || value[0] != open || value[len(value)-1] != close {
+		return value, false
+	}
+	backslashes := 0
+	for i := len(value) - 2; i >= 0 && value[i] == '\\'; i-- {
+		backslashes++
+	}
+	if backslashes%2 == 1 {
+		return value, false
+	}
+	return value[1 : len(value)-1], true
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+var csvRoundTripValues = []string{
+	"plain",
+	"with space",
+	"a|b",
+	"a;b",
+	"key:value",
+	"key=value",
+	"{braced}",
+	"[listed]",
+	`back\slash`,
+	`trailing\`,
+	`\`,
+	`\\`,
+	`\|`,
+	"} unbalanced {{",
+	`all | ; : = { } [ ] \ `,
+}
+
+func TestEscapeCSVValueRoundTrip(t *testing.T) {
+	for _, value := range csvRoundTripValues {
+		if got := UnescapeCSVValue(EscapeCSVValue(value)); got != value {
+			t.Errorf("UnescapeCSVValue(EscapeCSVValue(%q)) = %q", value, got)
+		}
+		joined := JoinCSVValues([]string{value, value, value}, ";")
+		if got := SplitCSVValue(joined, ';'); !reflect.DeepEqual(got, []string{value, value, value}) {
+			t.Errorf("SplitCSVValue(JoinCSVValues(%q)) = %q", value, got)
+		}
+		key, rest, found := CutEscaped(EscapeCSVValue(value)+":"+EscapeCSVValue(value), ':')
+		if !found || UnescapeCSVValue(key) != value || UnescapeCSVValue(rest) != value {
+			t.Errorf("CutEscaped(%q) = %q, %q, %v", value, key, rest, found)
+		}
+	}
+}
+
+func TestRulesCSVRoundTrip(t *testing.T) {
+	g := newTestGetter(t, http.NotFoundHandler())
+	integrationID := 15368
+	for _, value := range csvRoundTripValues {
+		rules := []data.Rules{
+			{Type: "commit_message_pattern", Parameters: &data.Parameters{
+				Name:     value,
+				Negate:   true,
+				Operator: "regex",
+				Pattern:  value,
+			}},
+			{Type: "file_path_restriction", Parameters: &data.Parameters{
+				RestrictedFilePaths: []string{value, "docs/" + value, value},
+			}},
+			{Type: "required_deployments", Parameters: &data.Parameters{
+				RequiredDeploymentEnvironments: []string{value},
+			}},
+			{Type: "required_status_checks", Parameters: &data.Parameters{
+				RequiredStatusChecks: []data.StatusChecks{
+					{Context: value, IntegrationID: &integrationID},
+					{Context: value + value},
+				},
+				StrictRequiredStatusChecksPolicy: true,
+			}},
+			{Type: "code_scanning", Parameters: &data.Parameters{
+				CodeScanningTools: []data.CodeScanning{{
+					Tool:                    value,
+					SecurityAlertsThreshold: "high_or_higher",
+					AlertsThreshold:         "errors",
+				}},
+			}},
+			{Type: "merge_queue", Parameters: &data.Parameters{
+				CheckResponseTimeoutMinutes: 60,
+				GroupingStrategy:            value,
+				MaxEntriesToBuild:           5,
+				MergeMethod:                 "SQUASH",
+			}},
+		}
+
+		rulesMap := g.ProcessRules(rules)
+		for _, rule := range rules {
+			exported, ok := rulesMap[rule.Type]
+			if !ok {
+				t.Fatalf("%s was not exported", rule.Type)
+			}
+			imported, _ := g.MapToParameters("target", ParseParameters(exported), rule.Type)
+			if !reflect.DeepEqual(imported, rule.Parameters) {
+				want, _ := json.Marshal(rule.Parameters)
+				got, _ := json.Marshal(imported)
+				t.Errorf("%s with %q:\nexported %s\nwant %s\ngot  %s", rule.Type, value, exported, want, got)
+			}
+		}
+	}
+}
+
+func TestBypassActorsCSVRoundTrip(t *testing.T) {
+	for _, value := range csvRoundTripValues {
+		var lookedUp string
+		mux := http.NewServeMux()
+		mux.HandleFunc("/organizations/1/team/42", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(data.TeamInfo{ID: 42, Name: value, Slug: value}) // nolint:errcheck
+		})
+		mux.HandleFunc("/orgs/target/teams/", func(w http.ResponseWriter, r *http.Request) {
+			lookedUp = strings.TrimPrefix(r.URL.Path, "/orgs/target/teams/")
+			json.NewEncoder(w).Encode(data.TeamInfo{ID: 99, Name: value}) // nolint:errcheck
+		})
+		g := newTestGetter(t, mux)
+
+		teamID, adminID := 42, 5
+		actors := []data.BypassActor{
+			{ActorID: &teamID, ActorType: "Team", BypassMode: "always"},
+			{ActorID: &adminID, ActorType: "RepositoryRole", BypassMode: "pull_request"},
+		}
+		exported := strings.Join(g.ProcessActorsForExport(actors, "source", 1, "1"), "|")
+
+		imported, substitutions := g.ParseBypassActorsForImport("target", exported)
+		if len(imported) != 2 {
+			t.Fatalf("%q: imported %d actors from %s, want 2", value, len(imported), exported)
+		}
+		if *imported[0].ActorID != 99 || imported[0].ActorType != "Team" || imported[0].BypassMode != "always" {
+			t.Errorf("%q: team actor = %+v", value, imported[0])
+		}
+		if *imported[1].ActorID != 5 || imported[1].ActorType != "RepositoryRole" || imported[1].BypassMode != "pull_request" {
+			t.Errorf("%q: role actor = %+v", value, imported[1])
+		}
+		if len(substitutions) != 1 || substitutions[0].Name != value || substitutions[0].Status != "substituted" {
+			t.Errorf("%q: substitutions = %+v", value, substitutions)
+		}
+		if lookedUp != value {
+			t.Errorf("%q: looked up team %q", value, lookedUp)
+		}
+	}
+}
+
+func TestPropertiesCSVRoundTrip(t *testing.T) {
+	for _, value := range csvRoundTripValues {
+		properties := []data.PropertyPattern{
+			{Name: value, Source: "custom", PropertyValues: []string{value, "other"}},
+			{Name: "team", Source: value, PropertyValues: []string{value}},
+		}
+		exported := strings.Join(ProcessProperties(properties), "|")
+		if got := parsePropertyPatterns(exported); !reflect.DeepEqual(got, properties) {
+			t.Errorf("%q: exported %s, imported %+v", value, exported, got)
+		}
+	}
+}
+
+func TestRulesetCSVColumnsRecordUpgrade(t *testing.T) {
+	header := []string{"RulesetName", "ConditionsRefNameInclude", "BypassActors", "SchemaVersion", "RulesetLevel"}
+	columns, err := NewRulesetCSVColumns([][]string{header})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		record  []string
+		include []string
+	}{
+		{
+			name:    "no schema version",
+			record:  []string{`name\x`, `refs/heads/a\b;main`, "5;RepositoryRole;Admin;always", ""},
+			include: []string{`refs/heads/a\b`, "main"},
+		},
+		{
+			name:    "before escaping",
+			record:  []string{`name\x`, `refs/heads/a\b;trailing\`, "5;RepositoryRole;Admin;always", "2"},
+			include: []string{`refs/heads/a\b`, `trailing\`},
+		},
+		{
+			name:    "escaped",
+			record:  []string{`name\x`, `refs/heads/a\\b;semi\;colon`, "5;RepositoryRole;Admin;always", "3"},
+			include: []string{`refs/heads/a\b`, "semi;colon"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := columns.Record(tt.record)
+			if got := columns.Value(record, "RulesetName"); got != `name\x` {
+				t.Errorf("RulesetName = %q, want it unchanged", got)
+			}
+			conditions := parseConditions(columns, record)
+			if !reflect.DeepEqual(conditions.RefName.Include, tt.include) {
+				t.Errorf("include = %q, want %q", conditions.RefName.Include, tt.include)
+			}
+			if tt.record[1] != columns.Value(tt.record, "ConditionsRefNameInclude") {
+				t.Error("Record modified the original record")
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"go.uber.org/zap"
+)
+
+// RulesetRepoName returns the repository a ruleset belongs to, or N/A for
+// organization level rulesets.
+func RulesetRepoName(ruleset data.RepoRuleset) string {
+	if ruleset.SourceType == "Repository" {
+		parts := strings.SplitN(ruleset.Source, "/", 2)
+		if len(parts) == 2 {
+			return parts[1]
+		}
+		return ruleset.Source
+	}
+	return "N/A"
+}
+
+// SortRulesets orders rulesets by level, repository and name so output is
+// stable between runs.
+func SortRulesets(rulesets []data.RepoRuleset) {
+	sort.SliceStable(rulesets, func(i, j int) bool {
+		if rulesets[i].SourceType != rulesets[j].SourceType {
+			return rulesets[i].SourceType < rulesets[j].SourceType
+		}
+		if RulesetRepoName(rulesets[i]) != RulesetRepoName(rulesets[j]) {
+			return RulesetRepoName(rulesets[i]) < RulesetRepoName(rulesets[j])
+		}
+		if rulesets[i].Name != rulesets[j].Name {
+			return rulesets[i].Name < rulesets[j].Name
+		}
+		return rulesets[i].ID < rulesets[j].ID
+	})
+}
+
+// RulesetKey identifies a ruleset across organizations by level, repository and name.
+func RulesetKey(level, repoName, name string) string {
+	return strings.Join([]string{level, repoName, name}, "/")
+}
+
+func (g *APIGetter) FlattenRulesets(rulesets []data.RepoRuleset, owner string, orgID int) map[string]data.FlatRuleset {
+	flatRulesets := make(map[string]data.FlatRuleset)
+	for _, ruleset := range rulesets {
+		repoName := RulesetRepoName(ruleset)
+		key := RulesetKey(ruleset.SourceType, repoName, ruleset.Name)
+		if _, exists := flatRulesets[key]; exists {
+			zap.S().Infof("Duplicate ruleset %s found in %s, only the first will be compared", ruleset.Name, owner)
+			continue
+		}
+		flatRulesets[key] = data.FlatRuleset{
+			RulesetLevel:   ruleset.SourceType,
+			RepositoryName: repoName,
+			RulesetName:    ruleset.Name,
+			Fields:         g.FlattenRuleset(ruleset, owner, orgID),
+		}
+	}
+	return flatRulesets
+}
+
+// FlattenRuleset converts a ruleset into comparable field/value pairs, with
+// bypass actors, repository_id condition repositories, workflow repositories
+// and status check apps referenced by name rather than ID. Parameters that are
+// not natively supported are compared as canonical JSON.
+func (g *APIGetter) FlattenRuleset(ruleset data.RepoRuleset, owner string, orgID int) map[string]string {
+	zap.S().Debugf("Flattening ruleset %s for comparison", ruleset.Name)
+	ruleset = g.AddStatusCheckSlugs(owner, ruleset)
+	fields := map[string]string{
+		"target":      ruleset.Target,
+		"enforcement": ruleset.Enforcement,
+	}
+
+	var actors []string
+	for _, actor := range g.ProcessActorsForExport(ruleset.BypassActors, owner, orgID, "") {
+		actorData := SplitCSVValue(actor, ';')
+		if len(actorData) < 4 {
+			continue
+		}
+		actorName := actorData[2]
+		if len(actorName) == 0 {
+			actorName = actorData[0]
+		}
+		actors = append(actors, fmt.Sprintf("%s:%s (%s)", actorData[1], actorName, actorData[3]))
+	}
+	sort.Strings(actors)
+	fields["bypass_actors"] = strings.Join(actors, ", ")
+
+	if ruleset.Conditions != nil {
+		if ruleset.Conditions.RefName != nil {
+			fields["conditions.ref_name.include"] = sortedJoin(ruleset.Conditions.RefName.Include)
+			fields["conditions.ref_name.exclude"] = sortedJoin(ruleset.Conditions.RefName.Exclude)
+		}
+		if ruleset.Conditions.RepositoryName != nil {
+			fields["conditions.repository_name.include"] = sortedJoin(ruleset.Conditions.RepositoryName.Include)
+			fields["conditions.repository_name.exclude"] = sortedJoin(ruleset.Conditions.RepositoryName.Exclude)
+			fields["conditions.repository_name.protected"] = fmt.Sprintf("%v", ruleset.Conditions.RepositoryName.Protected)
+		}
+		if ruleset.Conditions.RepositoryProperty != nil {
+			fields["conditions.repository_property.include"] = sortedJoin(ProcessProperties(ruleset.Conditions.RepositoryProperty.Include))
+			fields["conditions.repository_property.exclude"] = sortedJoin(ProcessProperties(ruleset.Conditions.RepositoryProperty.Exclude))
+		}
+		if ruleset.Conditions.RepositoryID != nil {
+			var repoNames []string
+			for _, repoID := range ruleset.Conditions.RepositoryID.RepositoryIDs {
+				repoName := fmt.Sprintf("%d", repoID)
+				repoInfo, err := g.GetRepoByID(repoID)
+				if err == nil && repoInfo.Name != "" {
+					repoName = repoInfo.Name
+				}
+				repoNames = append(repoNames, repoName)
+			}
+			fields["conditions.repository_id"] = sortedJoin(repoNames)
+		}
+		if ruleset.Conditions.OrganizationName != nil {
+			fields["conditions.organization_name.include"] = sortedJoin(ruleset.Conditions.OrganizationName.Include)
+			fields["conditions.organization_name.exclude"] = sortedJoin(ruleset.Conditions.OrganizationName.Exclude)
+		}
+		if ruleset.Conditions.OrganizationID != nil {
+			var orgIDs []string
+			for _, orgID := range ruleset.Conditions.OrganizationID.OrganizationIDs {
+				orgIDs = append(orgIDs, strconv.Itoa(orgID))
+			}
+			fields["conditions.organization_id"] = sortedJoin(orgIDs)
+		}
+	}
+
+	for _, rule := range ruleset.Rules {
+		ruleKey := fmt.Sprintf("rules.%s", rule.Type)
+		fields[ruleKey] = "enabled"
+		if rawParameters := unsupportedParametersJSON(rule); len(rawParameters) > 0 {
+			fields[fmt.Sprintf("%s.RawParameters", ruleKey)] = rawParameters
+		}
+		if rule.Parameters == nil {
+			continue
+		}
+		for paramName, paramValue := range g.ParametersToMap(*rule.Parameters, rule.Type) {
+			fields[fmt.Sprintf("%s.%s", ruleKey, paramName)] = paramValue
+		}
+		if len(rule.Parameters.Workflows) > 0 {
+			var workflows []string
+			for _, workflow := range rule.Parameters.Workflows {
+				repoName := fmt.Sprintf("%d", workflow.RepositoryID)
+				repoInfo, err := g.GetRepoByID(workflow.RepositoryID)
+				if err == nil && repoInfo.Name != "" {
+					repoName = repoInfo.Name
+				}
+				workflows = append(workflows, fmt.Sprintf("%s:%s@%s", repoName, workflow.Path, workflow.Ref))
+			}
+			fields[fmt.Sprintf("%s.Workflows", ruleKey)] = sortedJoin(workflows)
+		}
+		if len(rule.Parameters.RequiredStatusChecks) > 0 {
+			var statusChecks []string
+			for _, statusCheck := range rule.Parameters.RequiredStatusChecks {
+				if len(statusCheck.IntegrationSlug) > 0 {
+					statusChecks = append(statusChecks, fmt.Sprintf("%s (%s)", statusCheck.Context, statusCheck.IntegrationSlug))
+				} else if statusCheck.IntegrationID != nil {
+					statusChecks = append(statusChecks, fmt.Sprintf("%s (app %d)", statusCheck.Context, *statusCheck.IntegrationID))
+				} else {
+					statusChecks = append(statusChecks, statusCheck.Context)
+				}
+			}
+			fields[fmt.Sprintf("%s.RequiredStatusChecks", ruleKey)] = sortedJoin(statusChecks)
+		}
+	}
+	return fields
+}
+
+// unsupportedParametersJSON returns the parameters of a rule that are not
+// natively supported as JSON with sorted keys, so equal parameters compare
+// equal regardless of the order the API returned them in.
+func unsupportedParametersJSON(rule data.Rules) string {
+	if len(rule.RawParameters) == 0 {
+		return ""
+	}
+	var parameters interface{}
+	if KnownRuleType(rule.Type) {
+		unknown, err := unknownParameters(rule.RawParameters, ruleParameterJSONNames(rule.Type))
+		if err != nil || len(unknown) == 0 {
+			return ""
+		}
+		unknownJSON, err := json.Marshal(unknown)
+		if err != nil {
+			return ""
+		}
+		rule.RawParameters = unknownJSON
+	}
+	if err := json.Unmarshal(rule.RawParameters, &parameters); err != nil {
+		zap.S().Debugf("Failed to read parameters of rule %s: %v", rule.Type, err)
+		return string(rule.RawParameters)
+	}
+	canonical, err := json.Marshal(parameters)
+	if err != nil {
+		return string(rule.RawParameters)
+	}
+	return string(canonical)
+}
+
+// DiffRulesets compares rulesets matched by level, repository and name. Rulesets
+// only found in the target are reported as added, and rulesets only found in
+// the source as removed.
+func DiffRulesets(source, target map[string]data.FlatRuleset) []data.RulesetDiff {
+	var diffs []data.RulesetDiff
+
+	for _, key := range sortedKeys(source, target) {
+		sourceRuleset, inSource := source[key]
+		targetRuleset, inTarget := target[key]
+		switch {
+		case inSource && !inTarget:
+			diffs = append(diffs, data.RulesetDiff{
+				RulesetLevel:   sourceRuleset.RulesetLevel,
+				RepositoryName: sourceRuleset.RepositoryName,
+				RulesetName:    sourceRuleset.RulesetName,
+				Status:         "removed",
+			})
+		case !inSource && inTarget:
+			diffs = append(diffs, data.RulesetDiff{
+				RulesetLevel:   targetRuleset.RulesetLevel,
+				RepositoryName: targetRuleset.RepositoryName,
+				RulesetName:    targetRuleset.RulesetName,
+				Status:         "added",
+			})
+		default:
+			changes := diffFields(sourceRuleset.Fields, targetRuleset.Fields)
+			if len(changes) > 0 {
+				diffs = append(diffs, data.RulesetDiff{
+					RulesetLevel:   sourceRuleset.RulesetLevel,
+					RepositoryName: sourceRuleset.RepositoryName,
+					RulesetName:    sourceRuleset.RulesetName,
+					Status:         "changed",
+					Changes:        changes,
+				})
+			}
+		}
+	}
+	return diffs
+}
+
+func diffFields(source, target map[string]string) []data.FieldChange {
+	var changes []data.FieldChange
+	fieldNames := make(map[string]struct{})
+	for name := range source {
+		fieldNames[name] = struct{}{}
+	}
+	for name := range target {
+		fieldNames[name] = struct{}{}
+	}
+	names := make([]string, 0, len(fieldNames))
+	for name := range fieldNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if source[name] != target[name] {
+			changes = append(changes, data.FieldChange{Field: name, Source: source[name], Target: target[name]})
+		}
+	}
+	return changes
+}
+
+func sortedKeys(source, target map[string]data.FlatRuleset) []string {
+	keySet := make(map[string]struct{})
+	for key := range source {
+		keySet[key] = struct{}{}
+	}
+	for key := range target {
+		keySet[key] = struct{}{}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedJoin(values []string) string {
+	sorted := CleanSlice(values)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ";")
+}
@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func TestDiffRulesets(t *testing.T) {
+	flat := func(name string, fields map[string]string) data.FlatRuleset {
+		return data.FlatRuleset{RulesetLevel: "Organization", RepositoryName: "N/A", RulesetName: name, Fields: fields}
+	}
+	key := func(name string) string {
+		return RulesetKey("Organization", "N/A", name)
+	}
+	tests := []struct {
+		name   string
+		source map[string]data.FlatRuleset
+		target map[string]data.FlatRuleset
+		want   []data.RulesetDiff
+	}{
+		{
+			name:   "unchanged",
+			source: map[string]data.FlatRuleset{key("main"): flat("main", map[string]string{"enforcement": "active"})},
+			target: map[string]data.FlatRuleset{key("main"): flat("main", map[string]string{"enforcement": "active"})},
+		},
+		{
+			name:   "removed",
+			source: map[string]data.FlatRuleset{key("main"): flat("main", nil)},
+			target: map[string]data.FlatRuleset{},
+			want:   []data.RulesetDiff{{RulesetLevel: "Organization", RepositoryName: "N/A", RulesetName: "main", Status: "removed"}},
+		},
+		{
+			name:   "added",
+			source: map[string]data.FlatRuleset{},
+			target: map[string]data.FlatRuleset{key("main"): flat("main", nil)},
+			want:   []data.RulesetDiff{{RulesetLevel: "Organization", RepositoryName: "N/A", RulesetName: "main", Status: "added"}},
+		},
+		{
+			name:   "changed",
+			source: map[string]data.FlatRuleset{key("main"): flat("main", map[string]string{"enforcement": "active", "rules.deletion": "enabled"})},
+			target: map[string]data.FlatRuleset{key("main"): flat("main", map[string]string{"enforcement": "evaluate", "rules.creation": "enabled"})},
+			want: []data.RulesetDiff{{RulesetLevel: "Organization", RepositoryName: "N/A", RulesetName: "main", Status: "changed", Changes: []data.FieldChange{
+				{Field: "enforcement", Source: "active", Target: "evaluate"},
+				{Field: "rules.creation", Target: "enabled"},
+				{Field: "rules.deletion", Source: "enabled"},
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffRulesets(tt.source, tt.target); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffRulesets = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenRulesetsDiff(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/organizations/1/team/42", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(data.TeamInfo{ID: 42, Name: "Platform", Slug: "platform"}) // nolint:errcheck
+	})
+	g := newTestGetter(t, mux)
+	id := func(id int) *int {
+		return &id
+	}
+	ruleset := func(reviewCount int, contexts []string, actors []data.BypassActor) data.RepoRuleset {
+		var statusChecks []data.StatusChecks
+		for _, context := range contexts {
+			statusChecks = append(statusChecks, data.StatusChecks{Context: context})
+		}
+		return data.RepoRuleset{
+			Name:         "main",
+			Target:       "branch",
+			SourceType:   "Organization",
+			Source:       "org",
+			Enforcement:  "active",
+			BypassActors: actors,
+			Conditions:   &data.Conditions{RefName: &data.RefPatterns{Include: []string{"~DEFAULT_BRANCH"}}},
+			Rules: []data.Rules{
+				{Type: "pull_request", Parameters: &data.Parameters{RequiredApprovingReviewCount: reviewCount}},
+				{Type: "required_status_checks", Parameters: &data.Parameters{RequiredStatusChecks: statusChecks}},
+			},
+		}
+	}
+	team := data.BypassActor{ActorID: id(42), ActorType: "Team", BypassMode: "always"}
+	admin := data.BypassActor{ActorID: id(5), ActorType: "RepositoryRole", BypassMode: "always"}
+
+	tests := []struct {
+		name    string
+		source  data.RepoRuleset
+		target  data.RepoRuleset
+		changes []string
+	}{
+		{
+			name:   "bypass actor order",
+			source: ruleset(1, []string{"build"}, []data.BypassActor{team, admin}),
+			target: ruleset(1, []string{"build"}, []data.BypassActor{admin, team}),
+		},
+		{
+			name:    "bypass actor removed",
+			source:  ruleset(1, []string{"build"}, []data.BypassActor{team, admin}),
+			target:  ruleset(1, []string{"build"}, []data.BypassActor{admin}),
+			changes: []string{"bypass_actors"},
+		},
+		{
+			name:    "nested parameter",
+			source:  ruleset(1, []string{"build"}, nil),
+			target:  ruleset(2, []string{"build"}, nil),
+			changes: []string{"rules.pull_request.RequiredApprovingReviewCount"},
+		},
+		{
+			name:    "status check added",
+			source:  ruleset(1, []string{"build"}, nil),
+			target:  ruleset(1, []string{"build", "test"}, nil),
+			changes: []string{"rules.required_status_checks.RequiredStatusChecks"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := DiffRulesets(
+				g.FlattenRulesets([]data.RepoRuleset{tt.source}, "org", 1),
+				g.FlattenRulesets([]data.RepoRuleset{tt.target}, "org", 1),
+			)
+			var changes []string
+			for _, diff := range diffs {
+				if diff.Status != "changed" {
+					t.Fatalf("diff = %+v, want a changed ruleset", diff)
+				}
+				for _, change := range diff.Changes {
+					changes = append(changes, change.Field)
+				}
+			}
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("changed fields = %v, want %v", changes, tt.changes)
+			}
+		})
+	}
+}
+
+func TestGatherRepoInfoRulesetsDetailError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"organization":{"rulesets":{"nodes":[{"databaseId":5,"name":"main"},{"databaseId":6,"name":"release"}],"pageInfo":{"hasNextPage":false}}}}}`)) // nolint:errcheck
+	})
+	mux.HandleFunc("/orgs/org/rulesets/5", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":5,"name":"main","source_type":"Organization","source":"org"}`)) // nolint:errcheck
+	})
+	mux.HandleFunc("/orgs/org/rulesets/6", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	g := newTestGetter(t, mux)
+	g.SetConcurrency(2)
+
+	if rulesets, err := g.GatherRepoInfoRulesets("org", nil, "orgOnly"); err == nil {
+		t.Errorf("rulesets = %+v, want an error for the ruleset that could not be fetched", rulesets)
+	}
+}
+
+func TestFlattenRulesetsStatusCheckApps(t *testing.T) {
+	mux := http.NewServeMux()
+	installations := map[string][]data.AppInstallation{
+		"source": {{AppID: 15368, AppSlug: "ci-app"}, {AppID: 15369, AppSlug: "lint-app"}},
+		"target": {{AppID: 900, AppSlug: "ci-app"}},
+	}
+	for owner, apps := range installations {
+		apps := apps
+		mux.HandleFunc("/orgs/"+owner+"/installations", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(data.AppIntegrations{Installations: apps}) // nolint:errcheck
+		})
+	}
+	g := newTestGetter(t, mux)
+	ruleset := func(integrationID int) data.RepoRuleset {
+		return data.RepoRuleset{
+			Name:       "main",
+			SourceType: "Organization",
+			Rules: []data.Rules{{Type: "required_status_checks", Parameters: &data.Parameters{
+				RequiredStatusChecks: []data.StatusChecks{{Context: "build", IntegrationID: &integrationID}},
+			}}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		source  int
+		target  int
+		changes int
+	}{
+		{name: "same app with another ID", source: 15368, target: 900},
+		{name: "different app", source: 15369, target: 900, changes: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := DiffRulesets(
+				g.FlattenRulesets([]data.RepoRuleset{ruleset(tt.source)}, "source", 1),
+				g.FlattenRulesets([]data.RepoRuleset{ruleset(tt.target)}, "target", 2),
+			)
+			changes := 0
+			for _, diff := range diffs {
+				changes += len(diff.Changes)
+			}
+			if changes != tt.changes {
+				t.Errorf("diffs = %+v, want %d changes", diffs, tt.changes)
+			}
+		})
+	}
+}
+
+func TestFlattenRulesetUnknownIntegrationActor(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/org/installations", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(data.AppIntegrations{Installations: []data.AppInstallation{{AppID: 1001, AppSlug: "ci-app"}}}) // nolint:errcheck
+	})
+	g := newTestGetter(t, mux)
+	known, unknown := 1001, 1002
+	ruleset := data.RepoRuleset{
+		Name:       "main",
+		SourceType: "Organization",
+		BypassActors: []data.BypassActor{
+			{ActorID: &known, ActorType: "Integration", BypassMode: "always"},
+			{ActorID: &unknown, ActorType: "Integration", BypassMode: "always"},
+		},
+	}
+
+	fields := g.FlattenRuleset(ruleset, "org", 1)
+	if want := "Integration:1002 (always), Integration:ci-app (always)"; fields["bypass_actors"] != want {
+		t.Errorf("bypass_actors = %q, want %q", fields["bypass_actors"], want)
+	}
+}
+
+func TestFlattenRulesetRepositoryIDsAndRawParameters(t *testing.T) {
+	mux := http.NewServeMux()
+	for id, name := range map[string]string{"10": "api", "11": "web", "20": "web", "21": "api", "22": "docs"} {
+		name := name
+		mux.HandleFunc("/repositories/"+id, func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(data.RepoInfo{Name: name}) // nolint:errcheck
+		})
+	}
+	g := newTestGetter(t, mux)
+	ruleset := func(repoIDs []int, rawParameters string) data.RepoRuleset {
+		return data.RepoRuleset{
+			Name:       "main",
+			SourceType: "Organization",
+			Conditions: &data.Conditions{RepositoryID: &data.RepoIDPatterns{RepositoryIDs: repoIDs}},
+			Rules: []data.Rules{
+				{Type: "future_rule", RawParameters: json.RawMessage(rawParameters)},
+				{Type: "deletion", RawParameters: json.RawMessage(`{"new_option":true}`)},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		source  data.RepoRuleset
+		target  data.RepoRuleset
+		changes []string
+	}{
+		{
+			name:   "same repositories and parameters",
+			source: ruleset([]int{10, 11}, `{"b":1,"a":{"y":2,"x":1}}`),
+			target: ruleset([]int{20, 21}, `{"a":{"x":1,"y":2},"b":1}`),
+		},
+		{
+			name:    "different repository",
+			source:  ruleset([]int{10, 11}, `{"a":1}`),
+			target:  ruleset([]int{21, 22}, `{"a":1}`),
+			changes: []string{"conditions.repository_id"},
+		},
+		{
+			name:    "different raw parameter",
+			source:  ruleset([]int{10}, `{"a":1}`),
+			target:  ruleset([]int{21}, `{"a":2}`),
+			changes: []string{"rules.future_rule.RawParameters"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceFields := g.FlattenRuleset(tt.source, "source", 1)
+			if sourceFields["rules.deletion.RawParameters"] != `{"new_option":true}` {
+				t.Errorf("deletion raw parameters = %q", sourceFields["rules.deletion.RawParameters"])
+			}
+			var changes []string
+			for _, change := range diffFields(sourceFields, g.FlattenRuleset(tt.target, "target", 2)) {
+				changes = append(changes, change.Field)
+			}
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("changes = %v, want %v", changes, tt.changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"go.uber.org/zap"
+)
+
+func (g *APIGetter) GetRepoEnvironments(ownerRepo string) ([]data.Environment, error) {
+	var allEnvironments []data.Environment
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("repos/%s/environments?per_page=100&page=%d", ownerRepo, page)
+		resp, err := g.restClient.Request("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		responseData, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		var environments data.Environments
+		if err := json.Unmarshal(responseData, &environments); err != nil {
+			return nil, err
+		}
+		allEnvironments = append(allEnvironments, environments.Environments...)
+		if len(environments.Environments) < 100 {
+			break
+		}
+	}
+	return allEnvironments, nil
+}
+
+func (g *APIGetter) GetDeploymentBranchPoliciesPage(ownerRepo string, environment string, page int) ([]data.BranchPolicy, error) {
+	environment = url.PathEscape(environment)
+	url := fmt.Sprintf("repos/%s/environments/%s/deployment-branch-policies?per_page=100&page=%d", ownerRepo, environment, page)
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var policies data.DeploymentBranchPolicies
+	err = json.Unmarshal(responseData, &policies)
+	return policies.BranchPolicies, err
+}
+
+// GetDeploymentBranchPolicies returns every deployment branch policy of an
+// environment.
+func (g *APIGetter) GetDeploymentBranchPolicies(ownerRepo string, environment string) ([]data.BranchPolicy, error) {
+	var allPolicies []data.BranchPolicy
+	for page := 1; ; page++ {
+		zap.S().Debugf("Gathering page %d of deployment branch policies for environment %s in %s", page, environment, ownerRepo)
+		policies, err := g.GetDeploymentBranchPoliciesPage(ownerRepo, environment, page)
+		if err != nil {
+			return nil, err
+		}
+		allPolicies = append(allPolicies, policies...)
+		if len(policies) < 100 {
+			break
+		}
+	}
+	return allPolicies, nil
+}
+
+func (g *APIGetter) GetUserByLogin(login string) (*data.UserInfo, error) {
+	url := fmt.Sprintf("users/%s", login)
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var user data.UserInfo
+	err = json.Unmarshal(responseData, &user)
+	return &user, err
+}
+
+// RequiredDeploymentEnvironments returns the environment names referenced by
+// the required_deployments rules of a ruleset.
+func RequiredDeploymentEnvironments(ruleset data.RepoRuleset) []string {
+	var environments []string
+	for _, rule := range ruleset.Rules {
+		if rule.Type == "required_deployments" && rule.Parameters != nil {
+			environments = append(environments, rule.Parameters.RequiredDeploymentEnvironments...)
+		}
+	}
+	return environments
+}
+
+// CheckDeploymentEnvironments verifies that the environments required by a
+// repository ruleset exist in the target repository. Missing environments are
+// an error, unless createMissing is set, in which case requests to create them
+// are added ahead of the ruleset request. When s is set, the protection
+// settings of each environment are copied from sourceRepo. Environments found
+// in the target are cached per level and target in existingEnvironments, and the ones an
+// earlier entry plans to create in plannedEnvironments. As those are only
+// created when the plan is applied, a later entry relying on one confirms it
+// exists with a GET request ahead of its ruleset request.
+func (g *APIGetter) CheckDeploymentEnvironments(entry data.PlanEntry, ruleset data.RepoRuleset, existingEnvironments map[string]map[string]struct{}, plannedEnvironments map[string]map[string]struct{}, createMissing bool, s *APIGetter, sourceRepo string) data.PlanEntry {
+	required := RequiredDeploymentEnvironments(ruleset)
+	if entry.RulesetLevel != "Repository" || len(required) == 0 || len(entry.Error) > 0 || len(entry.Requests) == 0 {
+		return entry
+	}
+	key := targetKey(entry)
+	existing, ok := existingEnvironments[key]
+	if !ok {
+		environments, err := g.GetRepoEnvironments(entry.Target)
+		if err != nil {
+			zap.S().Errorf("Error gathering environments for %s: %v", entry.Target, err)
+			entry.Requests = nil
+			entry.Error = fmt.Sprintf("Failed to verify deployment environments: %s", ErrorMessage(err))
+			return entry
+		}
+		existing = make(map[string]struct{}, len(environments))
+		for _, environment := range environments {
+			existing[strings.ToLower(environment.Name)] = struct{}{}
+		}
+		existingEnvironments[key] = existing
+	}
+	planned, ok := plannedEnvironments[key]
+	if !ok {
+		planned = make(map[string]struct{})
+		plannedEnvironments[key] = planned
+	}
+
+	var missing []string
+	var confirmRequests []data.PlanRequest
+	seen := make(map[string]struct{})
+	for _, environment := range required {
+		key := strings.ToLower(environment)
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		if _, ok := planned[key]; ok {
+			zap.S().Debugf("Deployment environment %s is created by an earlier ruleset in %s", environment, entry.Target)
+			confirmRequests = append(confirmRequests, data.PlanRequest{
+				Method:   "GET",
+				Endpoint: fmt.Sprintf("repos/%s/environments/%s", entry.Target, url.PathEscape(environment)),
+			})
+			continue
+		}
+		missing = append(missing, environment)
+	}
+	if len(missing) == 0 {
+		entry.Requests = append(confirmRequests, entry.Requests...)
+		return entry
+	}
+	if !createMissing {
+		zap.S().Infof("Deployment environments %s required by ruleset %s do not exist in %s", strings.Join(missing, ", "), entry.RulesetName, entry.Target)
+		entry.Requests = nil
+		entry.Error = fmt.Sprintf("Deployment environments %s do not exist", strings.Join(missing, ", "))
+		return entry
+	}
+	for _, environment := range missing {
+		planned[strings.ToLower(environment)] = struct{}{}
+	}
+
+	sourceEnvironments := make(map[string]data.Environment)
+	if s != nil && len(sourceRepo) > 0 {
+		environments, err := s.GetRepoEnvironments(sourceRepo)
+		if err != nil {
+			zap.S().Errorf("Error gathering environments for %s: %v", sourceRepo, err)
+			entry.Requests = nil
+			entry.Error = fmt.Sprintf("Failed to gather deployment environments of %s: %s", sourceRepo, ErrorMessage(err))
+			return entry
+		}
+		for _, environment := range environments {
+			sourceEnvironments[strings.ToLower(environment.Name)] = environment
+		}
+	}
+
+	var requests []data.PlanRequest
+	for _, name := range missing {
+		zap.S().Debugf("Creating deployment environment %s in %s", name, entry.Target)
+		var sourceEnvironment *data.Environment
+		if environment, ok := sourceEnvironments[strings.ToLower(name)]; ok {
+			sourceEnvironment = &environment
+		}
+		environmentRequests, substitutions, err := g.environmentRequests(entry.Target, name, s, sourceRepo, sourceEnvironment)
+		entry.Substitutions = append(entry.Substitutions, substitutions...)
+		if err != nil {
+			entry.Requests = nil
+			entry.Error = fmt.Sprintf("Failed to gather deployment branch policies of environment %s: %s", name, ErrorMessage(err))
+			return entry
+		}
+		if unresolved := unresolvedSubstitutions(substitutions, "environments.reviewers"); len(unresolved) > 0 {
+			entry.Requests = nil
+			entry.Error = fmt.Sprintf("Reviewers of deployment environment %s could not be resolved: %s", name, strings.Join(unresolved, ", "))
+			return entry
+		}
+		requests = append(requests, environmentRequests...)
+	}
+	requests = append(requests, confirmRequests...)
+	entry.Requests = append(requests, entry.Requests...)
+	return entry
+}
+
+// environmentRequests returns the requests that create an environment in the
+// target repository, with the wait timer, reviewers and deployment branch
+// policies of the source environment when one is given. An error is returned
+// when the deployment branch policies of the source environment cannot be
+// gathered, rather than create the environment without them.
+func (g *APIGetter) environmentRequests(ownerRepo string, name string, s *APIGetter, sourceRepo string, source *data.Environment) ([]data.PlanRequest, []data.Substitution, error) {
+	endpoint := fmt.Sprintf("repos/%s/environments/%s", ownerRepo, url.PathEscape(name))
+	substitutions := []data.Substitution{{
+		Field:  "required_deployment_environments",
+		Type:   "Environment",
+		Name:   name,
+		Status: "created",
+	}}
+	var environment data.CreateEnvironment
+	var branchPolicies []data.BranchPolicy
+
+	if source != nil {
+		owner := strings.Split(ownerRepo, "/")[0]
+		for _, rule := range source.ProtectionRules {
+			switch rule.Type {
+			case "wait_timer":
+				environment.WaitTimer = rule.WaitTimer
+			case "required_reviewers":
+				environment.PreventSelfReview = rule.PreventSelfReview
+				for _, reviewer := range rule.Reviewers {
+					targetReviewer, substitution := g.environmentReviewer(owner, reviewer)
+					substitutions = append(substitutions, substitution)
+					if targetReviewer != nil {
+						environment.Reviewers = append(environment.Reviewers, *targetReviewer)
+					}
+				}
+			}
+		}
+		environment.DeploymentBranchPolicy = source.DeploymentBranchPolicy
+		if source.DeploymentBranchPolicy != nil && source.DeploymentBranchPolicy.CustomBranchPolicies {
+			policies, err := s.GetDeploymentBranchPolicies(sourceRepo, source.Name)
+			if err != nil {
+				zap.S().Errorf("Error gathering deployment branch policies for environment %s in %s: %v", source.Name, sourceRepo, err)
+				return nil, substitutions, err
+			}
+			branchPolicies = policies
+		}
+	}
+
+	environmentJSON, err := json.Marshal(environment)
+	if err != nil {
+		zap.S().Errorf("Error marshaling environment %s: %v", name, err)
+	}
+	requests := []data.PlanRequest{{
+		Method:   "PUT",
+		Endpoint: endpoint,
+		Payload:  environmentJSON,
+	}}
+	sort.Slice(branchPolicies, func(i, j int) bool { return branchPolicies[i].Name < branchPolicies[j].Name })
+	for _, policy := range branchPolicies {
+		policyJSON, err := json.Marshal(policy)
+		if err != nil {
+			zap.S().Errorf("Error marshaling deployment branch policy %s: %v", policy.Name, err)
+			continue
+		}
+		requests = append(requests, data.PlanRequest{
+			Method:   "POST",
+			Endpoint: endpoint + "/deployment-branch-policies",
+			Payload:  policyJSON,
+		})
+	}
+	return requests, substitutions, nil
+}
+
+// environmentReviewer maps a source environment reviewer to the target
+// organization, matching teams the same way as bypass actors and users by
+// login.
+func (g *APIGetter) environmentReviewer(owner string, reviewer data.EnvironmentReviewer) (*data.CreateEnvironmentReviewer, data.Substitution) {
+	substitution := data.Substitution{
+		Field:    "environments.reviewers",
+		Type:     reviewer.Type,
+		SourceID: reviewer.Reviewer.ID,
+		Status:   "failed",
+	}
+	var targetID int
+	var err error
+	switch reviewer.Type {
+	case "Team":
+		substitution.Name = reviewer.Reviewer.Slug
+		targetID, err = g.MappedActorID(owner, "Team", strconv.Itoa(reviewer.Reviewer.ID), reviewer.Reviewer.Slug)
+	case "User":
+		substitution.Name = reviewer.Reviewer.Login
+		var user *data.UserInfo
+		user, err = g.GetUserByLogin(reviewer.Reviewer.Login)
+		if err == nil {
+			targetID = user.ID
+		}
+	default:
+		err = fmt.Errorf("unsupported reviewer type %s", reviewer.Type)
+	}
+	if err != nil {
+		zap.S().Errorf("Failed to map environment reviewer %s %s: %v", reviewer.Type, substitution.Name, err)
+		return nil, substitution
+	}
+	substitution.TargetID = targetID
+	substitution.Status = "substituted"
+	return &data.CreateEnvironmentReviewer{Type: reviewer.Type, ID: targetID}, substitution
+}
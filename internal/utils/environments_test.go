@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func newTestDeploymentRuleset(t *testing.T, name string, environments ...string) (data.PlanEntry, data.RepoRuleset) {
+	t.Helper()
+	ruleset := data.RepoRuleset{
+		Name:       name,
+		SourceType: "Repository",
+		Source:     "target/app",
+		Rules: []data.Rules{{
+			Type:       "required_deployments",
+			Parameters: &data.Parameters{RequiredDeploymentEnvironments: environments},
+		}},
+	}
+	createRuleset, err := ProcessRulesets(ruleset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := NewPlanEntry(ruleset, createRuleset, "target/app", "target/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry, ruleset
+}
+
+func requestLines(requests []data.PlanRequest) []string {
+	lines := make([]string, len(requests))
+	for i, request := range requests {
+		lines[i] = request.Method + " " + request.Endpoint
+	}
+	return lines
+}
+
+func TestCheckDeploymentEnvironmentsConfirmsPlanned(t *testing.T) {
+	g := newTestGetter(t, http.NotFoundHandler())
+	existing := map[string]map[string]struct{}{"Repository|target/app": {"staging": {}}}
+	planned := make(map[string]map[string]struct{})
+
+	first, firstRuleset := newTestDeploymentRuleset(t, "first", "Production", "staging")
+	first = g.CheckDeploymentEnvironments(first, firstRuleset, existing, planned, true, nil, "")
+	want := []string{"PUT repos/target/app/environments/Production", "POST repos/target/app/rulesets"}
+	if got := requestLines(first.Requests); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("first requests = %v, want %v", got, want)
+	}
+	if _, ok := existing["Repository|target/app"]["production"]; ok {
+		t.Error("planned environment was cached as existing")
+	}
+
+	second, secondRuleset := newTestDeploymentRuleset(t, "second", "production", "staging")
+	second = g.CheckDeploymentEnvironments(second, secondRuleset, existing, planned, true, nil, "")
+	want = []string{"GET repos/target/app/environments/production", "POST repos/target/app/rulesets"}
+	if got := requestLines(second.Requests); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("second requests = %v, want %v", got, want)
+	}
+
+	missing, missingRuleset := newTestDeploymentRuleset(t, "missing", "qa")
+	missing = g.CheckDeploymentEnvironments(missing, missingRuleset, existing, planned, false, nil, "")
+	if len(missing.Error) == 0 || len(missing.Requests) != 0 {
+		t.Errorf("missing entry = %+v, want an error", missing)
+	}
+}
+
+func TestGetDeploymentBranchPoliciesPaginates(t *testing.T) {
+	var pages []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/target/app/environments/production/deployment-branch-policies", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pages = append(pages, r.URL.Query().Get("page"))
+		count := 100
+		if page == 2 {
+			count = 30
+		}
+		policies := data.DeploymentBranchPolicies{TotalCount: 130}
+		for i := 0; i < count; i++ {
+			policies.BranchPolicies = append(policies.BranchPolicies, data.BranchPolicy{Name: fmt.Sprintf("release/%d-%d", page, i)})
+		}
+		json.NewEncoder(w).Encode(policies) // nolint:errcheck
+	})
+	g := newTestGetter(t, mux)
+
+	policies, err := g.GetDeploymentBranchPolicies("target/app", "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 130 {
+		t.Errorf("got %d policies, want 130", len(policies))
+	}
+	if fmt.Sprint(pages) != "[1 2]" {
+		t.Errorf("requested pages %v, want [1 2]", pages)
+	}
+}
+
+func TestCheckDeploymentEnvironmentsSourceSettingsFail(t *testing.T) {
+	environment := func(login string, customPolicies bool) data.Environment {
+		reviewer := data.EnvironmentReviewer{Type: "User"}
+		reviewer.Reviewer.ID = 7
+		reviewer.Reviewer.Login = login
+		return data.Environment{
+			Name:                   "production",
+			ProtectionRules:        []data.EnvironmentProtectionRule{{Type: "required_reviewers", Reviewers: []data.EnvironmentReviewer{reviewer}}},
+			DeploymentBranchPolicy: &data.DeploymentBranchPolicy{CustomBranchPolicies: customPolicies},
+		}
+	}
+	tests := []struct {
+		name        string
+		environment data.Environment
+		wantError   string
+	}{
+		{name: "created", environment: environment("octocat", false)},
+		{name: "unmatched reviewer", environment: environment("ghost", false), wantError: "Reviewers of deployment environment production could not be resolved: User ghost"},
+		{name: "branch policies", environment: environment("octocat", true), wantError: "Failed to gather deployment branch policies of environment production"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repos/target/app/environments", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(data.Environments{}) // nolint:errcheck
+			})
+			mux.HandleFunc("/repos/source/app/environments", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(data.Environments{TotalCount: 1, Environments: []data.Environment{tt.environment}}) // nolint:errcheck
+			})
+			mux.HandleFunc("/repos/source/app/environments/production/deployment-branch-policies", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			})
+			mux.HandleFunc("/users/octocat", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"id":70,"login":"octocat"}`)) // nolint:errcheck
+			})
+			g := newTestGetter(t, mux)
+
+			entry, ruleset := newTestDeploymentRuleset(t, "main", "production")
+			entry = g.CheckDeploymentEnvironments(entry, ruleset, map[string]map[string]struct{}{}, map[string]map[string]struct{}{}, true, g, "source/app")
+			if len(tt.wantError) == 0 {
+				want := []string{"PUT repos/target/app/environments/production", "POST repos/target/app/rulesets"}
+				if got := requestLines(entry.Requests); fmt.Sprint(got) != fmt.Sprint(want) || len(entry.Error) > 0 {
+					t.Errorf("entry = %+v, want requests %v", entry, want)
+				}
+				return
+			}
+			if !strings.HasPrefix(entry.Error, tt.wantError) || len(entry.Requests) != 0 {
+				t.Errorf("entry = %+v, want error %q without requests", entry, tt.wantError)
+			}
+		})
+	}
+}
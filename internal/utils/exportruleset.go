@@ -1,19 +1,25 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/katiem0/gh-migrate-rulesets/internal/data" // Add this import
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 )
 
 func (g *APIGetter) ProcessActorsForExport(actors []data.BypassActor, owner string, orgID int, ruleID string) []string {
 	zap.S().Debugf("Processing bypass actors")
 	var actorStrings []string
-	var actorName string
 	for _, actor := range actors {
+		var actorName string
 		if actor.ActorID == nil {
 			defaultID := 0
 			actor.ActorID = &defaultID
@@ -48,10 +54,9 @@ func (g *APIGetter) ProcessActorsForExport(actors []data.BypassActor, owner stri
 					zap.S().Errorf("Failed to get team data for actor ID %d: %v", actor.ActorID, err)
 					continue
 				}
-				actorName = teamData.Name
+				actorName = teamData.Slug
 			} else {
 				zap.S().Infof("Invalid actor type: %s", actor.ActorType)
-				actorName = ""
 			}
 		}
 
@@ -61,26 +66,32 @@ func (g *APIGetter) ProcessActorsForExport(actors []data.BypassActor, owner stri
 			actorName,
 			actor.BypassMode,
 		}
-		actorStrings = append(actorStrings, strings.Join(actorList, ";"))
+		actorStrings = append(actorStrings, JoinCSVValues(actorList, ";"))
 	}
 	return actorStrings
 }
 
 func ProcessConditions(ruleset data.RepoRuleset) data.ProcessedConditions {
 	var PropertyInclude, PropertyExclude []string
-	var includeNames, excludeNames, boolNames, includeRefNames, excludeRefNames string
+	var includeNames, excludeNames, boolNames, includeRefNames, excludeRefNames, includeOrgNames, excludeOrgNames string
 	if ruleset.Conditions != nil {
 		if ruleset.Conditions.RepositoryName != nil {
-			includeNames = strings.Join(ruleset.Conditions.RepositoryName.Include, ";")
-			excludeNames = strings.Join(ruleset.Conditions.RepositoryName.Exclude, ";")
+			includeNames = JoinCSVValues(ruleset.Conditions.RepositoryName.Include, ";")
+			excludeNames = JoinCSVValues(ruleset.Conditions.RepositoryName.Exclude, ";")
 			boolNames = strconv.FormatBool(ruleset.Conditions.RepositoryName.Protected)
 		}
 		if ruleset.Conditions.RepositoryProperty != nil {
 			PropertyInclude = ProcessProperties(ruleset.Conditions.RepositoryProperty.Include)
 			PropertyExclude = ProcessProperties(ruleset.Conditions.RepositoryProperty.Exclude)
 		}
-		includeRefNames = strings.Join(ruleset.Conditions.RefName.Include, ";")
-		excludeRefNames = strings.Join(ruleset.Conditions.RefName.Exclude, ";")
+		if ruleset.Conditions.RefName != nil {
+			includeRefNames = JoinCSVValues(ruleset.Conditions.RefName.Include, ";")
+			excludeRefNames = JoinCSVValues(ruleset.Conditions.RefName.Exclude, ";")
+		}
+		if ruleset.Conditions.OrganizationName != nil {
+			includeOrgNames = JoinCSVValues(ruleset.Conditions.OrganizationName.Include, ";")
+			excludeOrgNames = JoinCSVValues(ruleset.Conditions.OrganizationName.Exclude, ";")
+		}
 	}
 	return data.ProcessedConditions{
 		IncludeNames:    includeNames,
@@ -90,16 +101,39 @@ func ProcessConditions(ruleset data.RepoRuleset) data.ProcessedConditions {
 		PropertyExclude: PropertyExclude,
 		IncludeRefNames: includeRefNames,
 		ExcludeRefNames: excludeRefNames,
+		IncludeOrgNames: includeOrgNames,
+		ExcludeOrgNames: excludeOrgNames,
+	}
+}
+
+// ProcessOrgIDsForExport formats the organization_id condition of an
+// enterprise ruleset as ID;Login pairs, so it can be matched by login when
+// recreated in another enterprise.
+func (g *APIGetter) ProcessOrgIDsForExport(conditions *data.Conditions) string {
+	if conditions == nil || conditions.OrganizationID == nil {
+		return ""
 	}
+	orgStrings := make([]string, 0, len(conditions.OrganizationID.OrganizationIDs))
+	for _, orgID := range conditions.OrganizationID.OrganizationIDs {
+		var login string
+		orgInfo, err := g.GetOrgByID(orgID)
+		if err != nil {
+			zap.S().Errorf("Failed to get organization data for ID %d: %v", orgID, err)
+		} else {
+			login = orgInfo.Login
+		}
+		orgStrings = append(orgStrings, fmt.Sprintf("%d;%s", orgID, EscapeCSVValue(login)))
+	}
+	return strings.Join(orgStrings, "|")
 }
 
 func ProcessProperties(properties []data.PropertyPattern) []string {
 	var propertyStrings []string
 	for _, property := range properties {
 		propertyList := []string{
-			property.Name,
-			property.Source,
-			fmt.Sprintf("{%s}", strings.Join(property.PropertyValues, "|")),
+			EscapeCSVValue(property.Name),
+			EscapeCSVValue(property.Source),
+			fmt.Sprintf("{%s}", JoinCSVValues(property.PropertyValues, "|")),
 		}
 		propertyStrings = append(propertyStrings, strings.Join(propertyList, ";"))
 	}
@@ -119,9 +153,282 @@ func (g *APIGetter) ProcessRules(rules []data.Rules) map[string]string {
 				formattedParams = append(formattedParams, fmt.Sprintf("%s:%v", key, value))
 			}
 			if len(formattedParams) > 0 {
+				sort.Strings(formattedParams)
 				rulesMap[rule.Type] = strings.Join(formattedParams, "|")
 			}
 		}
 	}
 	return rulesMap
 }
+
+// RulesetToCSVRow formats a ruleset as a row following data.RulesetCSVHeaders.
+func (g *APIGetter) RulesetToCSVRow(ruleset data.RepoRuleset, owner string, orgID int) []string {
+	actors := g.ProcessActorsForExport(ruleset.BypassActors, owner, orgID, strconv.Itoa(ruleset.ID))
+	conditions := ProcessConditions(ruleset)
+	rulesMap := g.ProcessRules(g.AddStatusCheckSlugs(owner, ruleset).Rules)
+	rawRules, err := RawRulesToCSV(ruleset.Rules)
+	if err != nil {
+		zap.S().Errorf("Failed to write raw rules of ruleset %s: %v", ruleset.Name, err)
+	}
+
+	values := map[string]string{
+		"RulesetLevel":                 ruleset.SourceType,
+		"RepositoryName":               RulesetRepoName(ruleset),
+		"RuleID":                       strconv.Itoa(ruleset.ID),
+		"RulesetName":                  ruleset.Name,
+		"Target":                       ruleset.Target,
+		"Enforcement":                  ruleset.Enforcement,
+		"BypassActors":                 strings.Join(actors, "|"),
+		"ConditionsRefNameInclude":     conditions.IncludeRefNames,
+		"ConditionsRefNameExclude":     conditions.ExcludeRefNames,
+		"ConditionsRepoNameInclude":    conditions.IncludeNames,
+		"ConditionsRepoNameExclude":    conditions.ExcludeNames,
+		"ConditionsRepoNameProtected":  conditions.BoolNames,
+		"ConditionRepoPropertyInclude": strings.Join(conditions.PropertyInclude, "|"),
+		"ConditionRepoPropertyExclude": strings.Join(conditions.PropertyExclude, "|"),
+		"ConditionsOrgNameInclude":     conditions.IncludeOrgNames,
+		"ConditionsOrgNameExclude":     conditions.ExcludeOrgNames,
+		"ConditionsOrgID":              g.ProcessOrgIDsForExport(ruleset.Conditions),
+		"RulesRawJSON":                 rawRules,
+		"CreatedAt":                    ruleset.CreatedAt,
+		"UpdatedAt":                    ruleset.UpdatedAt,
+		"SchemaVersion":                strconv.Itoa(data.RulesetCSVSchemaVersion),
+	}
+	for ruleHeader, ruleType := range data.HeaderMap {
+		values[ruleHeader] = rulesMap[ruleType]
+	}
+
+	row := make([]string, len(data.RulesetCSVHeaders))
+	for i, header := range data.RulesetCSVHeaders {
+		row[i] = values[header]
+	}
+	return row
+}
+
+// ExportRuleset converts a ruleset into the shape produced by the GitHub UI
+// when exporting a ruleset.
+func ExportRuleset(ruleset data.RepoRuleset) (data.ExportRuleset, error) {
+	createRuleset, err := ProcessRulesets(ruleset)
+	if err != nil {
+		return data.ExportRuleset{}, err
+	}
+	bypassActors := ruleset.BypassActors
+	if bypassActors == nil {
+		bypassActors = []data.BypassActor{}
+	}
+	return data.ExportRuleset{
+		ID:           ruleset.ID,
+		Name:         ruleset.Name,
+		Target:       ruleset.Target,
+		SourceType:   ruleset.SourceType,
+		Source:       ruleset.Source,
+		Enforcement:  ruleset.Enforcement,
+		Conditions:   ruleset.Conditions,
+		Rules:        createRuleset.Rules,
+		BypassActors: bypassActors,
+	}, nil
+}
+
+func MarshalRulesetJSON(ruleset data.RepoRuleset) ([]byte, error) {
+	exportRuleset, err := ExportRuleset(ruleset)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(exportRuleset, "", "  ")
+}
+
+func WriteRulesetsToJSON(rulesets []data.RepoRuleset, w io.Writer) error {
+	exportRulesets := make([]data.ExportRuleset, 0, len(rulesets))
+	for _, ruleset := range rulesets {
+		exportRuleset, err := ExportRuleset(ruleset)
+		if err != nil {
+			return err
+		}
+		exportRulesets = append(exportRulesets, exportRuleset)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(exportRulesets)
+}
+
+// WriteRulesetFilesToDir writes one file per ruleset under outputDir, using the
+// layout org/<name><ext> and repos/<repo>/<name><ext>.
+func WriteRulesetFilesToDir(rulesets []data.RepoRuleset, outputDir string, ext string, marshal func(data.RepoRuleset) ([]byte, error)) error {
+	for _, ruleset := range rulesets {
+		fileName := RulesetFilePath(outputDir, ruleset, ext)
+		content, err := marshal(ruleset)
+		if err != nil {
+			return fmt.Errorf("failed to marshal ruleset %s: %w", ruleset.Name, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(fileName, content, 0644); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+		zap.S().Debugf("Wrote ruleset %s to %s", ruleset.Name, fileName)
+	}
+	return nil
+}
+
+func RulesetFilePath(outputDir string, ruleset data.RepoRuleset, ext string) string {
+	if ruleset.SourceType == "Repository" {
+		return filepath.Join(outputDir, "repos", safeFileName(RulesetRepoName(ruleset)), safeFileName(ruleset.Name)+ext)
+	} else if ruleset.SourceType == "Enterprise" {
+		return filepath.Join(outputDir, "enterprise", safeFileName(ruleset.Name)+ext)
+	}
+	return filepath.Join(outputDir, "org", safeFileName(ruleset.Name)+ext)
+}
+
+func safeFileName(name string) string {
+	replacer := strings.NewReplacer("/", "-", "\\", "-", ":", "-", "*", "-", "?", "-", "\"", "-", "<", "-", ">", "-", "|", "-")
+	return replacer.Replace(name)
+}
+
+// RulesetToYAML converts a ruleset into its YAML representation, referencing
+// bypass actors, required workflow repositories and the repositories of a
+// repository_id condition by name rather than ID.
+func (g *APIGetter) RulesetToYAML(ruleset data.RepoRuleset, owner string, orgID int) (data.YAMLRuleset, error) {
+	yamlRuleset := data.YAMLRuleset{
+		Name:        ruleset.Name,
+		Level:       ruleset.SourceType,
+		Target:      ruleset.Target,
+		Enforcement: ruleset.Enforcement,
+		Conditions:  g.conditionRepoIDsToNames(ruleset.Conditions),
+	}
+	if ruleset.SourceType == "Repository" {
+		yamlRuleset.Repository = RulesetRepoName(ruleset)
+	}
+
+	for _, actor := range g.ProcessActorsForExport(ruleset.BypassActors, owner, orgID, strconv.Itoa(ruleset.ID)) {
+		actorData := SplitCSVValue(actor, ';')
+		if len(actorData) < 4 {
+			continue
+		}
+		yamlRuleset.BypassActors = append(yamlRuleset.BypassActors, data.YAMLBypassActor{
+			Type: actorData[1],
+			Name: actorData[2],
+			Mode: actorData[3],
+		})
+	}
+
+	createRuleset, err := ProcessRulesets(ruleset)
+	if err != nil {
+		return yamlRuleset, err
+	}
+	for _, rule := range createRuleset.Rules {
+		yamlRule := data.YAMLRule{Type: rule.Type}
+		if rule.Parameters != nil {
+			parametersJSON, err := json.Marshal(rule.Parameters)
+			if err != nil {
+				return yamlRuleset, err
+			}
+			if err := json.Unmarshal(parametersJSON, &yamlRule.Parameters); err != nil {
+				return yamlRuleset, err
+			}
+			g.workflowRepoIDsToNames(yamlRule.Parameters)
+			g.statusCheckIDsToSlugs(owner, yamlRule.Parameters)
+		}
+		yamlRuleset.Rules = append(yamlRuleset.Rules, yamlRule)
+	}
+	return yamlRuleset, nil
+}
+
+// conditionRepoIDsToNames returns a copy of conditions listing the repositories
+// of a repository_id condition by name, keeping the IDs of repositories that
+// cannot be found so the import reports them.
+func (g *APIGetter) conditionRepoIDsToNames(conditions *data.Conditions) *data.Conditions {
+	if conditions == nil || conditions.RepositoryID == nil {
+		return conditions
+	}
+	repoIDs := &data.RepoIDPatterns{}
+	for _, repoID := range conditions.RepositoryID.RepositoryIDs {
+		repoInfo, err := g.GetRepoByID(repoID)
+		if err != nil || repoInfo.Name == "" {
+			zap.S().Errorf("Failed to get repository data for repository %d of the repository_id condition", repoID)
+			repoIDs.RepositoryIDs = append(repoIDs.RepositoryIDs, repoID)
+			continue
+		}
+		repoIDs.RepositoryNames = append(repoIDs.RepositoryNames, repoInfo.Name)
+	}
+	yamlConditions := *conditions
+	yamlConditions.RepositoryID = repoIDs
+	return &yamlConditions
+}
+
+func (g *APIGetter) workflowRepoIDsToNames(parameters map[string]interface{}) {
+	workflows, ok := parameters["workflows"].([]interface{})
+	if !ok {
+		return
+	}
+	for _, workflow := range workflows {
+		workflowMap, ok := workflow.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		repoID, ok := workflowMap["repository_id"].(float64)
+		if !ok {
+			continue
+		}
+		repoInfo, err := g.GetRepoByID(int(repoID))
+		if err != nil || repoInfo.Name == "" {
+			zap.S().Errorf("Failed to get repository data for workflow repository %d", int(repoID))
+			continue
+		}
+		delete(workflowMap, "repository_id")
+		workflowMap["repository"] = repoInfo.Name
+	}
+}
+
+// statusCheckIDsToSlugs records the app slug of each required status check
+// integration, keeping the integration ID when the app is not found.
+func (g *APIGetter) statusCheckIDsToSlugs(owner string, parameters map[string]interface{}) {
+	statusChecks, ok := parameters["required_status_checks"].([]interface{})
+	if !ok {
+		return
+	}
+	for _, statusCheck := range statusChecks {
+		statusCheckMap, ok := statusCheck.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		integrationID, ok := statusCheckMap["integration_id"].(float64)
+		if !ok {
+			continue
+		}
+		slug, err := g.IntegrationSlug(owner, int(integrationID))
+		if err != nil {
+			zap.S().Infof("Failed to get the app for status check %v: %v", statusCheckMap["context"], err)
+			continue
+		}
+		delete(statusCheckMap, "integration_id")
+		statusCheckMap["integration"] = slug
+	}
+}
+
+// YAMLMarshaler returns a function marshaling rulesets of owner to YAML.
+func (g *APIGetter) YAMLMarshaler(owner string, orgID int) func(data.RepoRuleset) ([]byte, error) {
+	return func(ruleset data.RepoRuleset) ([]byte, error) {
+		yamlRuleset, err := g.RulesetToYAML(ruleset, owner, orgID)
+		if err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(yamlRuleset)
+	}
+}
+
+// WriteRulesetsToYAML writes the rulesets as a multi-document YAML stream.
+func (g *APIGetter) WriteRulesetsToYAML(rulesets []data.RepoRuleset, owner string, orgID int, w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	for _, ruleset := range rulesets {
+		yamlRuleset, err := g.RulesetToYAML(ruleset, owner, orgID)
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(yamlRuleset); err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func TestProcessConditionsWithoutRefName(t *testing.T) {
+	ruleset := data.RepoRuleset{
+		Name:   "push",
+		Target: "push",
+		Conditions: &data.Conditions{
+			RepositoryName: &data.NamePatterns{Include: []string{"app"}, Exclude: []string{}},
+		},
+	}
+	conditions := ProcessConditions(ruleset)
+	if conditions.IncludeRefNames != "" || conditions.ExcludeRefNames != "" {
+		t.Errorf("ref names = %q, %q, want empty", conditions.IncludeRefNames, conditions.ExcludeRefNames)
+	}
+	if conditions.IncludeNames != "app" {
+		t.Errorf("include names = %q, want app", conditions.IncludeNames)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/cli/go-gh"
 	"github.com/cli/go-gh/pkg/api"
@@ -16,12 +17,16 @@ import (
 )
 
 func InitializeClients(hostname, authToken string) (api.RESTClient, api.GQLClient, error) {
+	transport := NewRateLimitTransport(hostname, nil)
+	registerRateLimitTransport(transport)
+
 	restClient, err := gh.RESTClient(&api.ClientOptions{
 		Headers: map[string]string{
 			"Accept": "application/vnd.github+json",
 		},
 		Host:      hostname,
 		AuthToken: authToken,
+		Transport: transport,
 	})
 	if err != nil {
 		zap.S().Errorf("Error arose retrieving rest client")
@@ -34,6 +39,7 @@ func InitializeClients(hostname, authToken string) (api.RESTClient, api.GQLClien
 		},
 		Host:      hostname,
 		AuthToken: authToken,
+		Transport: transport,
 	})
 	if err != nil {
 		zap.S().Errorf("Error arose retrieving graphql client")
@@ -52,37 +58,76 @@ func GetAuthToken(token, hostname string) string {
 }
 
 type Getter interface {
-	GetAppInstallations(owner string) ([]byte, error)
-	GetCustomRoles(owner string, roleID int) ([]byte, error)
-	GetRepo(owner string, name string) ([]data.RepoSingleQuery, error)
+	GetAppInstallations(owner string) (*data.AppIntegrations, error)
+	GetCustomRoles(owner string, roleID int) (*data.CustomRole, error)
+	GetRepo(owner string, name string) (*data.RepoSingleQuery, error)
 	GetRepoByID(repoID int) (*data.RepoInfo, error)
-	GetReposList(owner string, endCursor *string) ([]data.ReposQuery, error)
+	GetReposList(owner string, endCursor *string) (*data.ReposQuery, error)
 	GetOrgRulesetsList(owner string, endCursor *string) (*data.OrgRulesetsQuery, error)
 	GetOrgLevelRuleset(owner string, rulesetId int) ([]byte, error)
-	GetRepoRulesetsList(owner string, endCursor *string) (*data.RepoRulesetsQuery, error)
+	GetOrgByID(orgID int) (*data.OrgInfo, error)
+	GetEnterpriseRulesetsList(enterprise string, page int) ([]data.Rulesets, error)
+	GetEnterpriseLevelRuleset(enterprise string, rulesetId int) ([]byte, error)
+	GetRepoRulesetsList(owner string, repo string, endCursor *string) (*data.RepoRulesetsQuery, error)
 	GetRepoLevelRuleset(owner string, repo string, rulesetId int) ([]byte, error)
 	GetTeamData(ownerID int, teamID int) (*data.TeamInfo, error)
 	GetTeamByName(owner string, teamSlug string) (*data.TeamInfo, error)
-	CreateOrgLevelRuleset(owner string, data io.Reader)
-	CreateRepoLevelRuleset(ownerRepo string, data io.Reader)
+	CreateOrgLevelRuleset(owner string, data io.Reader) error
+	CreateRepoLevelRuleset(ownerRepo string, data io.Reader) error
+	SendRulesetRequest(method string, url string, data io.Reader) ([]byte, error)
+	DeleteRuleset(level string, target string, rulesetID int) error
 	FetchOrgId(owner string) (*data.OrgIdQuery, error)
 	FetchOrgRulesets(owner string) ([]data.Rulesets, error)
-	GatherRepositories(owner string, repos []string) []data.RepoInfo
+	GatherRepositories(owner string, repos []string) ([]data.RepoInfo, error)
 	RepoExists(ownerRepo string) bool
-	ParseBypassActorsForImport(owner string, bypassActorsStr string) []data.BypassActor
+	ParseBypassActorsForImport(owner string, bypassActorsStr string) ([]data.BypassActor, []data.Substitution)
 	UpdateBypassActorID(owner string, sourceOrg string, sourceOrgID int, ruleset data.RepoRuleset, s *APIGetter) data.RepoRuleset
 }
 
+var _ Getter = (*APIGetter)(nil)
+
 type APIGetter struct {
-	gqlClient  api.GQLClient
-	restClient api.RESTClient
+	gqlClient   api.GQLClient
+	restClient  api.RESTClient
+	concurrency int
+	actorMap    *ActorMap
+	repoMap     *RepoMap
+
+	installationsMu sync.Mutex
+	installations   map[string]map[int]string
 }
 
 func NewAPIGetter(gqlClient api.GQLClient, restClient api.RESTClient) *APIGetter {
 	return &APIGetter{
-		gqlClient:  gqlClient,
-		restClient: restClient,
+		gqlClient:   gqlClient,
+		restClient:  restClient,
+		concurrency: 1,
+	}
+}
+
+// SetConcurrency sets the number of concurrent requests used when fetching
+// repositories and rulesets.
+func (g *APIGetter) SetConcurrency(concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
 	}
+	g.concurrency = concurrency
+}
+
+func (g *APIGetter) Concurrency() int {
+	return g.concurrency
+}
+
+// SetActorMap sets the bypass actor overrides used in place of matching
+// actors by name.
+func (g *APIGetter) SetActorMap(actorMap *ActorMap) {
+	g.actorMap = actorMap
+}
+
+// SetRepoMap sets the repository renames used when copying rulesets to
+// repositories with different names.
+func (g *APIGetter) SetRepoMap(repoMap *RepoMap) {
+	g.repoMap = repoMap
 }
 
 func (g *APIGetter) CreateOrgLevelRuleset(owner string, data io.Reader) error {
@@ -107,6 +152,20 @@ func (g *APIGetter) CreateRepoLevelRuleset(ownerRepo string, data io.Reader) err
 	return nil
 }
 
+func (g *APIGetter) SendRulesetRequest(method string, url string, data io.Reader) ([]byte, error) {
+	resp, err := g.restClient.Request(method, url, data)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return responseData, nil
+}
+
 func (g *APIGetter) FetchOrgId(owner string) (*data.OrgIdQuery, error) {
 	query := new(data.OrgIdQuery)
 	variables := map[string]interface{}{
@@ -141,39 +200,245 @@ func (g *APIGetter) FetchOrgRulesets(owner string) ([]data.Rulesets, error) {
 
 func (g *APIGetter) FetchRepoRulesets(owner string, repos []data.RepoInfo) ([]data.RepoNameRule, error) {
 	var allRepoRules []data.RepoNameRule
+	repoRules := make([][]data.RepoNameRule, len(repos))
+	repoErrors := make([]error, len(repos))
 
-	for _, repo := range repos {
+	ForEachConcurrently(len(repos), g.concurrency, func(i int) {
 		var repoRulesCursor *string
+		repo := repos[i]
 		zap.S().Debugf("Checking for rulesets in repo %s", repo.Name)
 		for {
 			repoRulesetsQuery, err := g.GetRepoRulesetsList(owner, repo.Name, repoRulesCursor)
 			if err != nil {
-				return nil, err
+				repoErrors[i] = err
+				return
 			}
 			for _, rule := range repoRulesetsQuery.Repository.Rulesets.Nodes {
-				allRepoRules = append(allRepoRules, data.RepoNameRule{RepoName: repo.Name, Rule: rule})
+				repoRules[i] = append(repoRules[i], data.RepoNameRule{RepoName: repo.Name, Rule: rule})
 			}
 			repoRulesCursor = &repoRulesetsQuery.Repository.Rulesets.PageInfo.EndCursor
 			if !repoRulesetsQuery.Repository.Rulesets.PageInfo.HasNextPage {
 				break
 			}
 		}
+	})
+
+	for i := range repos {
+		if repoErrors[i] != nil {
+			return nil, repoErrors[i]
+		}
+		allRepoRules = append(allRepoRules, repoRules[i]...)
 	}
 	return allRepoRules, nil
 }
 
+func (g *APIGetter) DeleteRuleset(level string, target string, rulesetID int) error {
+	url := fmt.Sprintf("%s/%d", RulesetsEndpoint(level, target), rulesetID)
+
+	_, err := g.SendRulesetRequest("DELETE", url, nil)
+	return err
+}
+
+// ExistingRulesetIDs returns the IDs of the rulesets already defined at the
+// target organization or repository, keyed by ruleset name.
+func (g *APIGetter) ExistingRulesetIDs(level string, target string) (map[string]int, error) {
+	existing := make(map[string]int)
+	var rulesets []data.Rulesets
+
+	if level == "Repository" {
+		parts := strings.SplitN(target, "/", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid repository %s", target)
+		}
+		repoRules, err := g.FetchRepoRulesets(parts[0], []data.RepoInfo{{Name: parts[1]}})
+		if err != nil {
+			return nil, err
+		}
+		for _, repoRule := range repoRules {
+			rulesets = append(rulesets, repoRule.Rule)
+		}
+	} else if level == "Enterprise" {
+		enterpriseRules, err := g.FetchEnterpriseRulesets(target)
+		if err != nil {
+			return nil, err
+		}
+		rulesets = enterpriseRules
+	} else {
+		orgRules, err := g.FetchOrgRulesets(target)
+		if err != nil {
+			return nil, err
+		}
+		rulesets = orgRules
+	}
+	for _, ruleset := range rulesets {
+		existing[ruleset.Name] = ruleset.DatabaseID
+	}
+	return existing, nil
+}
+
+// GatherRulesets fetches the full ruleset data of the organization and/or
+// repository rulesets of owner, sorted by level, repository and name.
+func (g *APIGetter) GatherRulesets(owner string, repos []string, ruleType string) ([]data.RepoRuleset, error) {
+	var allRepos []data.RepoInfo
+	if ruleType == "all" || ruleType == "repoOnly" {
+		zap.S().Infof("Gathering repositories specified in org %s to list rulesets for", owner)
+		var err error
+		allRepos, err = g.GatherRepositories(owner, repos)
+		if err != nil {
+			zap.S().Error("Error raised in gathering repos", zap.Error(err))
+			return nil, err
+		}
+	}
+	return g.GatherRepoInfoRulesets(owner, allRepos, ruleType)
+}
+
+// GatherRepoInfoRulesets fetches the full ruleset data of the organization
+// rulesets of owner and/or the repository rulesets of repositories already
+// gathered, sorted by level, repository and name. An error is returned when
+// the details of any ruleset cannot be fetched, rather than leaving it out.
+func (g *APIGetter) GatherRepoInfoRulesets(owner string, allRepos []data.RepoInfo, ruleType string) ([]data.RepoRuleset, error) {
+	var allRulesets []data.RepoRuleset
+
+	if ruleType == "all" || ruleType == "orgOnly" {
+		zap.S().Infof("Gathering organization %s level rulesets", owner)
+		allOrgRules, err := g.FetchOrgRulesets(owner)
+		if err != nil {
+			zap.S().Errorf("Error raised in fetching org ruleset data for %s", owner)
+			return nil, err
+		}
+		orgRulesets := make([]data.RepoRuleset, len(allOrgRules))
+		orgErrors := make([]error, len(allOrgRules))
+		ForEachConcurrently(len(allOrgRules), g.concurrency, func(i int) {
+			singleRule := allOrgRules[i]
+			zap.S().Debugf("Gathering specific ruleset data for org rule %s", singleRule.Name)
+			orgLevelRulesetResponse, err := g.GetOrgLevelRuleset(owner, singleRule.DatabaseID)
+			if err != nil {
+				orgErrors[i] = fmt.Errorf("failed to get organization ruleset %s: %w", singleRule.Name, err)
+				return
+			}
+			err = json.Unmarshal(orgLevelRulesetResponse, &orgRulesets[i])
+			if err != nil {
+				orgErrors[i] = fmt.Errorf("failed to parse organization ruleset %s: %w", singleRule.Name, err)
+			}
+		})
+		for i := range orgRulesets {
+			if orgErrors[i] != nil {
+				zap.S().Error("Error raised in getting org level ruleset data", zap.Error(orgErrors[i]))
+				return nil, orgErrors[i]
+			}
+			allRulesets = append(allRulesets, orgRulesets[i])
+		}
+	}
+
+	if ruleType == "all" || ruleType == "repoOnly" {
+		allRepoRules, err := g.FetchRepoRulesets(owner, allRepos)
+		if err != nil {
+			zap.S().Error("Error raised in fetching repo ruleset data", zap.Error(err))
+			return nil, err
+		}
+		repoRulesets := make([]data.RepoRuleset, len(allRepoRules))
+		repoErrors := make([]error, len(allRepoRules))
+		ForEachConcurrently(len(allRepoRules), g.concurrency, func(i int) {
+			singleRepoRule := allRepoRules[i]
+			zap.S().Debugf("Gathering specific ruleset data for repo %s rule %s", singleRepoRule.RepoName, singleRepoRule.Rule.Name)
+			repoLevelRulesetResponse, err := g.GetRepoLevelRuleset(owner, singleRepoRule.RepoName, singleRepoRule.Rule.DatabaseID)
+			if err != nil {
+				repoErrors[i] = fmt.Errorf("failed to get ruleset %s of repository %s: %w", singleRepoRule.Rule.Name, singleRepoRule.RepoName, err)
+				return
+			}
+			err = json.Unmarshal(repoLevelRulesetResponse, &repoRulesets[i])
+			if err != nil {
+				repoErrors[i] = fmt.Errorf("failed to parse ruleset %s of repository %s: %w", singleRepoRule.Rule.Name, singleRepoRule.RepoName, err)
+			}
+		})
+		for i := range repoRulesets {
+			if repoErrors[i] != nil {
+				zap.S().Error("Error raised in getting repo level ruleset data", zap.Error(repoErrors[i]))
+				return nil, repoErrors[i]
+			}
+			allRulesets = append(allRulesets, repoRulesets[i])
+		}
+	}
+
+	SortRulesets(allRulesets)
+	return allRulesets, nil
+}
+
+// GatherEnterpriseRulesets fetches the full ruleset data of the enterprise
+// level rulesets of enterprise, sorted by name. An error is returned when the
+// details of any ruleset cannot be fetched, rather than leaving it out.
+func (g *APIGetter) GatherEnterpriseRulesets(enterprise string) ([]data.RepoRuleset, error) {
+	var allRulesets []data.RepoRuleset
+
+	zap.S().Infof("Gathering enterprise %s level rulesets", enterprise)
+	allEnterpriseRules, err := g.FetchEnterpriseRulesets(enterprise)
+	if err != nil {
+		zap.S().Errorf("Error raised in fetching enterprise ruleset data for %s", enterprise)
+		return nil, err
+	}
+	enterpriseRulesets := make([]data.RepoRuleset, len(allEnterpriseRules))
+	enterpriseErrors := make([]error, len(allEnterpriseRules))
+	ForEachConcurrently(len(allEnterpriseRules), g.concurrency, func(i int) {
+		singleRule := allEnterpriseRules[i]
+		zap.S().Debugf("Gathering specific ruleset data for enterprise rule %s", singleRule.Name)
+		enterpriseLevelRulesetResponse, err := g.GetEnterpriseLevelRuleset(enterprise, singleRule.DatabaseID)
+		if err != nil {
+			enterpriseErrors[i] = fmt.Errorf("failed to get enterprise ruleset %s: %w", singleRule.Name, err)
+			return
+		}
+		err = json.Unmarshal(enterpriseLevelRulesetResponse, &enterpriseRulesets[i])
+		if err != nil {
+			enterpriseErrors[i] = fmt.Errorf("failed to parse enterprise ruleset %s: %w", singleRule.Name, err)
+			return
+		}
+		enterpriseRulesets[i].SourceType = "Enterprise"
+		enterpriseRulesets[i].Source = enterprise
+	})
+	for i := range enterpriseRulesets {
+		if enterpriseErrors[i] != nil {
+			zap.S().Error("Error raised in getting enterprise level ruleset data", zap.Error(enterpriseErrors[i]))
+			return nil, enterpriseErrors[i]
+		}
+		allRulesets = append(allRulesets, enterpriseRulesets[i])
+	}
+
+	SortRulesets(allRulesets)
+	return allRulesets, nil
+}
+
+func (g *APIGetter) FetchEnterpriseRulesets(enterprise string) ([]data.Rulesets, error) {
+	var allEnterpriseRules []data.Rulesets
+	for page := 1; ; page++ {
+		enterpriseRules, err := g.GetEnterpriseRulesetsList(enterprise, page)
+		if err != nil {
+			return nil, err
+		}
+		allEnterpriseRules = append(allEnterpriseRules, enterpriseRules...)
+		if len(enterpriseRules) < 100 {
+			break
+		}
+	}
+	return allEnterpriseRules, nil
+}
+
 func (g *APIGetter) GatherRepositories(owner string, repos []string) ([]data.RepoInfo, error) {
 	var allRepos []data.RepoInfo
 	var reposCursor *string
 
 	if len(repos) > 0 {
-		for _, repo := range repos {
-			repoQuery, err := g.GetRepo(owner, repo)
+		repoInfos := make([]*data.RepoInfo, len(repos))
+		ForEachConcurrently(len(repos), g.concurrency, func(i int) {
+			repoQuery, err := g.GetRepo(owner, repos[i])
 			if err != nil {
-				zap.S().Error("Error raised in getting repo", repo, zap.Error(err))
-				continue
+				zap.S().Error("Error raised in getting repo", repos[i], zap.Error(err))
+				return
+			}
+			repoInfos[i] = &repoQuery.Repository
+		})
+		for _, repoInfo := range repoInfos {
+			if repoInfo != nil {
+				allRepos = append(allRepos, *repoInfo)
 			}
-			allRepos = append(allRepos, repoQuery.Repository)
 		}
 	} else {
 		for {
@@ -335,6 +600,71 @@ func (g *APIGetter) GetOrgLevelRuleset(owner string, rulesetId int) ([]byte, err
 	return responseData, nil
 }
 
+func (g *APIGetter) GetEnterpriseRulesetsList(enterprise string, page int) ([]data.Rulesets, error) {
+	url := fmt.Sprintf("enterprises/%s/rulesets?per_page=100&page=%d", enterprise, page)
+
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var enterpriseRules []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	err = json.Unmarshal(responseData, &enterpriseRules)
+	if err != nil {
+		return nil, err
+	}
+	rulesets := make([]data.Rulesets, 0, len(enterpriseRules))
+	for _, enterpriseRule := range enterpriseRules {
+		rulesets = append(rulesets, data.Rulesets{DatabaseID: enterpriseRule.ID, Name: enterpriseRule.Name})
+	}
+	return rulesets, nil
+}
+
+func (g *APIGetter) GetEnterpriseLevelRuleset(enterprise string, rulesetId int) ([]byte, error) {
+	url := fmt.Sprintf("enterprises/%s/rulesets/%s", enterprise, strconv.Itoa(rulesetId))
+
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return responseData, nil
+}
+
+func (g *APIGetter) GetOrgByID(orgID int) (*data.OrgInfo, error) {
+	url := fmt.Sprintf("organizations/%s", strconv.Itoa(orgID))
+
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var orgInfo data.OrgInfo
+	err = json.Unmarshal(responseData, &orgInfo)
+	if err != nil {
+		return nil, err
+	}
+	return &orgInfo, nil
+}
+
 func (g *APIGetter) GetOrgRulesetsList(owner string, endCursor *string) (*data.OrgRulesetsQuery, error) {
 	query := new(data.OrgRulesetsQuery)
 	variables := map[string]interface{}{
@@ -361,17 +691,26 @@ func (g *APIGetter) GetRepo(owner string, name string) (*data.RepoSingleQuery, e
 func (g *APIGetter) GetRepoLevelRuleset(owner string, repo string, rulesetId int) ([]byte, error) {
 	url := fmt.Sprintf("repos/%s/%s/rulesets/%s", owner, repo, strconv.Itoa(rulesetId))
 
-	resp, _ := g.restClient.Request("GET", url, nil)
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
-	responseData, _ := io.ReadAll(resp.Body)
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
 	return responseData, nil
 }
 
 func (g *APIGetter) GetRepoByID(repoID int) (*data.RepoInfo, error) {
 	url := fmt.Sprintf("repositories/%s", strconv.Itoa(repoID))
 
-	resp, _ := g.restClient.Request("GET", url, nil)
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 	responseData, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -411,7 +750,10 @@ func (g *APIGetter) GetRepoRulesetsList(owner string, repo string, endCursor *st
 func (g *APIGetter) GetTeamData(ownerID int, teamID int) (*data.TeamInfo, error) {
 	url := fmt.Sprintf("organizations/%s/team/%s", strconv.Itoa(ownerID), strconv.Itoa(teamID))
 
-	resp, _ := g.restClient.Request("GET", url, nil)
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 	responseData, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+)
+
+// newTestGetter returns an APIGetter whose REST and GraphQL requests are
+// served by handler, without going over the network.
+func newTestGetter(t *testing.T, handler http.Handler) *APIGetter {
+	t.Helper()
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		resp := recorder.Result()
+		resp.Request = req
+		return resp, nil
+	})
+	options := &api.ClientOptions{
+		Host:      "github.com",
+		AuthToken: "token",
+		Transport: transport,
+	}
+	restClient, err := gh.RESTClient(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gqlClient, err := gh.GQLClient(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAPIGetter(gqlClient, restClient)
+}
+
+func TestGetRepoLevelRulesetError(t *testing.T) {
+	g := newTestGetter(t, http.NotFoundHandler())
+	if _, err := g.GetRepoLevelRuleset("owner", "repo", 1); err == nil {
+		t.Error("expected an error for a missing ruleset")
+	}
+}
+
+func TestGatherEnterpriseRulesetsDetailError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/enterprises/ent/rulesets", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":5,"name":"main"},{"id":6,"name":"release"}]`)) // nolint:errcheck
+	})
+	mux.HandleFunc("/enterprises/ent/rulesets/5", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":5,"name":"main"}`)) // nolint:errcheck
+	})
+	mux.HandleFunc("/enterprises/ent/rulesets/6", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	g := newTestGetter(t, mux)
+	g.SetConcurrency(2)
+
+	if rulesets, err := g.GatherEnterpriseRulesets("ent"); err == nil {
+		t.Errorf("rulesets = %+v, want an error for the ruleset that could not be fetched", rulesets)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/katiem0/gh-migrate-rulesets/internal/data"
 )
@@ -19,30 +20,28 @@ func Contains(slice []string, item string) bool {
 	return false
 }
 
-func SplitIgnoringBraces(s, delimiter string) []string {
-	var result []string
-	var currentSegment strings.Builder
-	inBraces := false
-
-	for i := 0; i < len(s); i++ {
-		char := s[i]
-
-		if char == '{' {
-			inBraces = true
-		} else if char == '}' {
-			inBraces = false
-		}
-
-		if !inBraces && strings.HasPrefix(s[i:], delimiter) {
-			result = append(result, currentSegment.String())
-			currentSegment.Reset()
-			i += len(delimiter) - 1
-		} else {
-			currentSegment.WriteByte(char)
-		}
+// ForEachConcurrently calls fn for every index in [0, count) using at most
+// concurrency goroutines, returning once all calls have completed.
+func ForEachConcurrently(count int, concurrency int, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < count; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indexes <- i
 	}
-	result = append(result, currentSegment.String())
-	return result
+	close(indexes)
+	wg.Wait()
 }
 
 func UpdateTag(field reflect.StructField, key, value string) reflect.StructField {
@@ -72,12 +71,12 @@ func WriteErrorRulesetsToCSV(errorRulesets []data.ErrorRulesets, fileName string
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	headers := []string{"Source", "RulesetName", "Error"}
+	headers := []string{"Source", "RulesetName", "Action", "Error"}
 	if err := writer.Write(headers); err != nil {
 		return fmt.Errorf("failed to write headers: %w", err)
 	}
 	for _, errorRuleset := range errorRulesets {
-		record := []string{errorRuleset.Source, errorRuleset.RulesetName, errorRuleset.Error}
+		record := []string{errorRuleset.Source, errorRuleset.RulesetName, errorRuleset.Action, errorRuleset.Error}
 		if err := writer.Write(record); err != nil {
 			return fmt.Errorf("failed to write record: %w", err)
 		}
@@ -1,74 +1,47 @@
 package utils
 
 import (
+	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/katiem0/gh-migrate-rulesets/internal/data"
 	"go.uber.org/zap"
 )
 
-func (g *APIGetter) ParseBypassActorsForImport(owner string, bypassActorsStr string) []data.BypassActor {
-	bypassActors := strings.Split(bypassActorsStr, "|")
+func (g *APIGetter) ParseBypassActorsForImport(owner string, bypassActorsStr string) ([]data.BypassActor, []data.Substitution) {
+	bypassActors := SplitEscaped(bypassActorsStr, '|')
 	actors := make([]data.BypassActor, 0, len(bypassActors))
-	var actorID *int
+	var substitutions []data.Substitution
 
 	for _, actor := range bypassActors {
-		actorData := strings.Split(actor, ";")
-		if len(actorData) < 2 {
+		actorData := SplitCSVValue(actor, ';')
+		if len(actorData) < 4 {
 			zap.S().Debug("No Bypass Actor data found")
 			continue
 		}
+		sourceID, _ := strconv.Atoi(actorData[0])
+		actorID := &sourceID
 		if _, ok := data.RolesMap[actorData[0]]; !ok {
 			zap.S().Debugf("Gathering appropriate IDs for Bypass Actor: %s", actorData[2])
-			if actorData[1] == "RepositoryRole" {
-				zap.S().Debugf("Processing bypass actor custom repository role")
-				roleData, err := g.GetRepoCustomRoles(owner)
-				if err != nil || len(roleData.CustomRoles) == 0 {
-					zap.S().Infof("Failed to get custom role data for Role Name %s", actorData[2])
-					id, _ := strconv.Atoi(actorData[0])
-					actorID = &id
-					continue
-				} else {
-					for _, CustomRole := range roleData.CustomRoles {
-						if CustomRole.Name == actorData[2] {
-							actorID = &CustomRole.ID
-						} else {
-							idData, _ := strconv.Atoi(actorData[0])
-							actorID = &idData
-						}
-					}
-				}
-			} else if actorData[1] == "Integration" {
-				zap.S().Debugf("Processing bypass actor integration")
-				appIntegrationData, err := g.GetAnApp(actorData[2])
-				if err != nil {
-					zap.S().Infof("Failed to get integration app data for actor ID %s", actorData[2])
-					id, _ := strconv.Atoi(actorData[0])
-					actorID = &id
-					continue
-				} else {
-					actorID = &appIntegrationData.AppID
-				}
-			} else if actorData[1] == "Team" {
-				zap.S().Debugf("Processing bypass actor team")
-				teamData, err := g.GetTeamByName(owner, actorData[2])
-				if err != nil {
-					zap.S().Infof("Failed to get team data for team name %s", actorData[2])
-					id, _ := strconv.Atoi(actorData[0])
-					actorID = &id
-					continue
-				} else {
-					actorID = &teamData.ID
-				}
+			substitution := data.Substitution{
+				Field:    "bypass_actors",
+				Type:     actorData[1],
+				Name:     actorData[2],
+				SourceID: sourceID,
 			}
-		} else {
-			if actorData[1] == "DeployKey" {
-				actorID = nil
-			} else {
-				id, _ := strconv.Atoi(actorData[0])
-				actorID = &id
+			targetID, err := g.MappedActorID(owner, actorData[1], actorData[0], actorData[2])
+			if err != nil {
+				zap.S().Infof("Failed to get %s data for %s: %v", actorData[1], actorData[2], err)
+				substitution.Status = "failed"
+				substitutions = append(substitutions, substitution)
+				continue
 			}
+			substitution.TargetID = targetID
+			substitution.Status = "substituted"
+			substitutions = append(substitutions, substitution)
+			actorID = &targetID
+		} else if actorData[1] == "DeployKey" {
+			actorID = nil
 		}
 		actors = append(actors, data.BypassActor{
 			ActorID:    actorID,
@@ -76,74 +49,153 @@ func (g *APIGetter) ParseBypassActorsForImport(owner string, bypassActorsStr str
 			BypassMode: actorData[3],
 		})
 	}
-	return actors
+	return actors, substitutions
 }
 
+// MappedActorID returns the target ID of a bypass actor, using an actor map
+// override for its source ID when one is set, and otherwise resolving it by
+// name with ResolveActorID.
+func (g *APIGetter) MappedActorID(owner string, actorType string, sourceID string, actorName string) (int, error) {
+	if target, ok := g.actorMap.Lookup(actorType, sourceID); ok {
+		zap.S().Debugf("Using actor map for %s %s: %s", actorType, sourceID, target)
+		return g.targetActorID(owner, actorType, target)
+	}
+	return g.ResolveActorID(owner, actorType, actorName)
+}
+
+// ResolveActorID looks up the ID of a custom repository role, integration or
+// team by name under owner, using an actor map override for the name when one
+// is set.
+func (g *APIGetter) ResolveActorID(owner string, actorType string, actorName string) (int, error) {
+	if target, ok := g.actorMap.Lookup(actorType, actorName); ok {
+		zap.S().Debugf("Using actor map for %s %s: %s", actorType, actorName, target)
+		return g.targetActorID(owner, actorType, target)
+	}
+	return g.LookupActorID(owner, actorType, actorName)
+}
+
+// targetActorID returns the ID of an actor map target, which is either an ID
+// or a name under owner.
+func (g *APIGetter) targetActorID(owner string, actorType string, target string) (int, error) {
+	if targetID, err := strconv.Atoi(target); err == nil {
+		return targetID, nil
+	}
+	return g.LookupActorID(owner, actorType, target)
+}
+
+// LookupActorID looks up the ID of a custom repository role, integration or
+// team by name under owner.
+func (g *APIGetter) LookupActorID(owner string, actorType string, actorName string) (int, error) {
+	switch actorType {
+	case "RepositoryRole":
+		zap.S().Debugf("Processing bypass actor custom repository role")
+		roleData, err := g.GetRepoCustomRoles(owner)
+		if err != nil {
+			return 0, err
+		}
+		for _, customRole := range roleData.CustomRoles {
+			if customRole.Name == actorName {
+				return customRole.ID, nil
+			}
+		}
+		return 0, fmt.Errorf("custom repository role %s not found in %s", actorName, owner)
+	case "Integration":
+		zap.S().Debugf("Processing bypass actor integration")
+		appIntegrationData, err := g.GetAnApp(actorName)
+		if err != nil {
+			return 0, err
+		}
+		return appIntegrationData.AppID, nil
+	case "Team":
+		zap.S().Debugf("Processing bypass actor team")
+		teamData, err := g.GetTeamByName(owner, actorName)
+		if err != nil {
+			return 0, err
+		}
+		return teamData.ID, nil
+	}
+	return 0, fmt.Errorf("unsupported actor type %s", actorType)
+}
+
+// UpdateBypassActorID replaces the ID of each bypass actor of a ruleset from
+// sourceOrg with the ID of the actor with the same name in owner. Actors that
+// cannot be resolved are left out and recorded as failed substitutions, as with
+// ParseBypassActorsForImport, so the ruleset is kept out of the plan.
 func (g *APIGetter) UpdateBypassActorID(owner string, sourceOrg string, sourceOrgID int, ruleset data.RepoRuleset, s *APIGetter) data.RepoRuleset {
 	zap.S().Debugf("Updating Bypass Actor ID for new org %s", owner)
 
-	for i, actor := range ruleset.BypassActors {
-		if actor.ActorType == "DeployKey" {
+	actors := make([]data.BypassActor, 0, len(ruleset.BypassActors))
+	for _, actor := range ruleset.BypassActors {
+		if actor.ActorType == "DeployKey" || actor.ActorID == nil {
 			zap.S().Debugf("Keeping for DeployKey in ruleset %s", ruleset.Name)
+			actors = append(actors, actor)
+			continue
+		}
+		if _, ok := data.RolesMap[strconv.Itoa(*actor.ActorID)]; ok {
+			actors = append(actors, actor)
 			continue
-		} else {
-			if _, ok := data.RolesMap[strconv.Itoa(*actor.ActorID)]; !ok {
-				if actor.ActorType == "RepositoryRole" {
-					zap.S().Debugf("Processing bypass actor custom repository role")
-					sourceRole, err := s.GetCustomRoles(sourceOrg, *actor.ActorID)
-					if err != nil {
-						zap.S().Errorf("Failed to get custom role data for actor ID %d: %v", actor.ActorID, err)
-						continue
-					}
-					roleData, err := g.GetRepoCustomRoles(owner)
-					if err != nil || len(roleData.CustomRoles) == 0 {
-						zap.S().Infof("Failed to get new custom role data for Role ID %d", actor.ActorID)
-						continue
-					} else {
-						for _, CustomRole := range roleData.CustomRoles {
-							if CustomRole.Name == sourceRole.Name {
-								ruleset.BypassActors[i].ActorID = &CustomRole.ID
-							}
-						}
-					}
-				} else if actor.ActorType == "Integration" {
-					zap.S().Debugf("Processing bypass actor integration from %s", sourceOrg)
-					sourceAppIntegration, err := s.GetAppInstallations(sourceOrg)
-					if err != nil {
-						zap.S().Errorf("Failed to get integration app data for actor ID %d: %v", actor.ActorID, err)
-						continue
-					} else {
-						for _, app := range sourceAppIntegration.Installations {
-							zap.S().Debugf("Processing bypass actor integration %s", app.AppSlug)
-							if *actor.ActorID == app.AppID {
-								appIntegrationInfo, err := g.GetAnApp(app.AppSlug)
-								if err != nil {
-									zap.S().Errorf("Failed to get new integration app data for actor ID %d: %v", actor.ActorID, err)
-									continue
-								} else {
-									ruleset.BypassActors[i].ActorID = &appIntegrationInfo.AppID
-								}
-							}
-						}
-					}
-				} else if actor.ActorType == "Team" {
-					zap.S().Debugf("Processing bypass actor team")
-					sourceTeamData, err := s.GetTeamData(sourceOrgID, *actor.ActorID)
-					if err != nil {
-						zap.S().Infof("Failed to get team data for team id %d", actor.ActorID)
-						continue
-					} else {
-						teamData, err := g.GetTeamByName(owner, sourceTeamData.Name)
-						if err != nil {
-							zap.S().Infof("Failed to get team data for team name %s", sourceTeamData.Name)
-							continue
-						} else {
-							ruleset.BypassActors[i].ActorID = &teamData.ID
-						}
-					}
-				}
+		}
+		substitution := data.Substitution{
+			Field:    "bypass_actors",
+			Type:     actor.ActorType,
+			SourceID: *actor.ActorID,
+			Status:   "failed",
+		}
+		sourceID := strconv.Itoa(*actor.ActorID)
+		sourceName, err := s.SourceActorName(sourceOrg, sourceOrgID, actor)
+		if err != nil {
+			if _, mapped := g.actorMap.Lookup(actor.ActorType, sourceID); !mapped {
+				zap.S().Errorf("Failed to get %s data for actor ID %d: %v", actor.ActorType, *actor.ActorID, err)
+				ruleset.Substitutions = append(ruleset.Substitutions, substitution)
+				continue
 			}
 		}
+		substitution.Name = sourceName
+		targetID, err := g.MappedActorID(owner, actor.ActorType, sourceID, sourceName)
+		if err != nil {
+			zap.S().Infof("Failed to get new %s data for %s: %v", actor.ActorType, sourceName, err)
+			ruleset.Substitutions = append(ruleset.Substitutions, substitution)
+			continue
+		}
+		substitution.TargetID = targetID
+		substitution.Status = "substituted"
+		ruleset.Substitutions = append(ruleset.Substitutions, substitution)
+		actors = append(actors, data.BypassActor{ActorID: &targetID, ActorType: actor.ActorType, BypassMode: actor.BypassMode})
 	}
+	ruleset.BypassActors = actors
 	return ruleset
 }
+
+// SourceActorName returns the name of a custom repository role, integration or
+// team bypass actor in the source organization.
+func (g *APIGetter) SourceActorName(sourceOrg string, sourceOrgID int, actor data.BypassActor) (string, error) {
+	switch actor.ActorType {
+	case "RepositoryRole":
+		zap.S().Debugf("Processing bypass actor custom repository role")
+		sourceRole, err := g.GetCustomRoles(sourceOrg, *actor.ActorID)
+		if err != nil {
+			return "", err
+		}
+		return sourceRole.Name, nil
+	case "Integration":
+		zap.S().Debugf("Processing bypass actor integration from %s", sourceOrg)
+		sourceAppIntegration, err := g.GetAppInstallations(sourceOrg)
+		if err != nil {
+			return "", err
+		}
+		for _, app := range sourceAppIntegration.Installations {
+			if *actor.ActorID == app.AppID {
+				return app.AppSlug, nil
+			}
+		}
+		return "", fmt.Errorf("integration %d is not installed in %s", *actor.ActorID, sourceOrg)
+	case "Team":
+		zap.S().Debugf("Processing bypass actor team")
+		sourceTeamData, err := g.GetTeamData(sourceOrgID, *actor.ActorID)
+		if err != nil {
+			return "", err
+		}
+		return sourceTeamData.Slug, nil
+	}
+	return "", fmt.Errorf("unsupported actor type %s", actor.ActorType)
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+// unresolvedTeamHandler serves team 42 of organization 1 as "missing", which
+// does not exist in the target organization.
+func unresolvedTeamHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/organizations/1/team/42", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(data.TeamInfo{ID: 42, Name: "Missing", Slug: "missing"}) // nolint:errcheck
+	})
+	return mux
+}
+
+func TestUnresolvedBypassActorKeptOutOfPlan(t *testing.T) {
+	g := newTestGetter(t, unresolvedTeamHandler())
+	teamID, adminID := 42, 5
+	sourceRuleset := data.RepoRuleset{
+		Name:       "main",
+		SourceType: "Organization",
+		BypassActors: []data.BypassActor{
+			{ActorID: &teamID, ActorType: "Team", BypassMode: "always"},
+			{ActorID: &adminID, ActorType: "RepositoryRole", BypassMode: "always"},
+		},
+	}
+
+	fromSource := g.UpdateBypassActorID("target", "source", 1, sourceRuleset, g)
+	fromFile := data.RepoRuleset{Name: "main", SourceType: "Organization"}
+	fromFile.BypassActors, fromFile.Substitutions = g.ParseBypassActorsForImport("target", "42;Team;missing;always|5;RepositoryRole;Admin;always")
+
+	for name, ruleset := range map[string]data.RepoRuleset{"source org": fromSource, "file": fromFile} {
+		t.Run(name, func(t *testing.T) {
+			if len(ruleset.BypassActors) != 1 || *ruleset.BypassActors[0].ActorID != adminID {
+				t.Errorf("bypass actors = %+v, want only the admin role", ruleset.BypassActors)
+			}
+			if len(ruleset.Substitutions) != 1 || ruleset.Substitutions[0].Status != "failed" || ruleset.Substitutions[0].Name != "missing" {
+				t.Errorf("substitutions = %+v, want one failed team substitution", ruleset.Substitutions)
+			}
+			createRuleset, err := ProcessRulesets(ruleset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry, err := NewPlanEntry(ruleset, createRuleset, "source", "target")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entry.Error) == 0 || len(entry.Requests) != 0 || len(entry.Substitutions) != 1 {
+				t.Errorf("entry = %+v, want an error without requests", entry)
+			}
+		})
+	}
+	if *sourceRuleset.BypassActors[0].ActorID != teamID {
+		t.Error("UpdateBypassActorID modified the source ruleset")
+	}
+}
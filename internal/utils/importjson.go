@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"go.uber.org/zap"
+)
+
+// ReadRulesetsFromJSON reads rulesets from a JSON file or a directory of JSON
+// files, each holding a single ruleset or an array of rulesets as exported by
+// `list --format json` or the GitHub UI. The IDs of bypass actors, workflow
+// repositories and status check apps are kept as they were exported.
+func ReadRulesetsFromJSON(owner string, path string) ([]data.RepoRuleset, error) {
+	rulesets, err := readRulesetJSONFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, ruleset := range rulesets {
+		rulesets[i].Source = determineSource(owner, ruleset.SourceType, RulesetRepoName(ruleset))
+	}
+	return rulesets, nil
+}
+
+// ImportRulesetsFromJSON reads rulesets from JSON files like
+// ReadRulesetsFromJSON, mapping the IDs of bypass actors, workflow repositories,
+// repository_id conditions and status check apps from the organization each
+// ruleset was exported from to the IDs of the same actors, repositories and
+// apps under owner. Lookups
+// in the source organization are made with s, and failures are recorded as
+// substitutions.
+func (g *APIGetter) ImportRulesetsFromJSON(owner string, path string, s *APIGetter) ([]data.RepoRuleset, error) {
+	rulesets, err := readRulesetJSONFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	sourceOrgIDs := make(map[string]int)
+	for i, ruleset := range rulesets {
+		if ruleset.SourceType == "Organization" || ruleset.SourceType == "Repository" {
+			sourceOrg := strings.SplitN(ruleset.Source, "/", 2)[0]
+			if len(sourceOrg) == 0 {
+				if hasSourceIDs(ruleset) {
+					return nil, fmt.Errorf("ruleset %s does not record the organization it was exported from in its source field, so its bypass actor, repository and status check app IDs cannot be mapped to %s", ruleset.Name, owner)
+				}
+			} else {
+				sourceOrgID, ok := sourceOrgIDs[sourceOrg]
+				if !ok {
+					sourceOrgData, err := s.FetchOrgId(sourceOrg)
+					if err != nil {
+						zap.S().Errorf("Failed to get source organization %s of JSON rulesets: %v", sourceOrg, err)
+					} else {
+						sourceOrgID = sourceOrgData.Organization.DatabaseID
+					}
+					sourceOrgIDs[sourceOrg] = sourceOrgID
+				}
+				zap.S().Debugf("Mapping IDs of ruleset %s from %s to %s", ruleset.Name, sourceOrg, owner)
+				ruleset = g.UpdateBypassActorID(owner, sourceOrg, sourceOrgID, ruleset, s)
+				ruleset = g.UpdateRequiredWorkflowRepoID(owner, ruleset, s)
+				ruleset = g.UpdateConditionRepoIDs(owner, ruleset, s)
+				ruleset = g.UpdateStatusCheckIntegrationID(owner, sourceOrg, ruleset, s)
+			}
+		}
+		ruleset.Source = determineSource(owner, ruleset.SourceType, RulesetRepoName(ruleset))
+		rulesets[i] = ruleset
+	}
+	return rulesets, nil
+}
+
+// UpdateConditionRepoIDs maps the repositories of a repository_id condition
+// from their IDs in the source organization, looked up with s, to the IDs of
+// the repositories with the same name under owner. Repositories that cannot be
+// mapped are recorded as failed substitutions.
+func (g *APIGetter) UpdateConditionRepoIDs(owner string, ruleset data.RepoRuleset, s *APIGetter) data.RepoRuleset {
+	if ruleset.Conditions == nil || ruleset.Conditions.RepositoryID == nil {
+		return ruleset
+	}
+	repoIDs := make([]int, 0, len(ruleset.Conditions.RepositoryID.RepositoryIDs))
+	for _, sourceID := range ruleset.Conditions.RepositoryID.RepositoryIDs {
+		substitution := data.Substitution{
+			Field:    "conditions.repository_id",
+			Type:     "Repository",
+			SourceID: sourceID,
+			Status:   "failed",
+		}
+		sourceRepo, err := s.GetRepoByID(sourceID)
+		if err != nil {
+			zap.S().Errorf("Failed to get repository %d of the repository_id condition of ruleset %s: %v", sourceID, ruleset.Name, err)
+			ruleset.Substitutions = append(ruleset.Substitutions, substitution)
+			continue
+		}
+		substitution.Name = sourceRepo.Name
+		targetRepo, err := g.GetRepo(owner, g.MappedRepo(sourceRepo.Name))
+		if err != nil {
+			zap.S().Errorf("Failed to get repository %s of the repository_id condition of ruleset %s: %v", sourceRepo.Name, ruleset.Name, err)
+			ruleset.Substitutions = append(ruleset.Substitutions, substitution)
+			continue
+		}
+		repoIDs = append(repoIDs, targetRepo.Repository.DatabaseId)
+		substitution.TargetID = targetRepo.Repository.DatabaseId
+		substitution.Status = "substituted"
+		ruleset.Substitutions = append(ruleset.Substitutions, substitution)
+	}
+	conditions := *ruleset.Conditions
+	conditions.RepositoryID = &data.RepoIDPatterns{RepositoryIDs: repoIDs}
+	ruleset.Conditions = &conditions
+	return ruleset
+}
+
+// hasSourceIDs reports whether a ruleset refers to bypass actors, workflow
+// repositories, repositories of a repository_id condition or status check apps
+// by IDs that only hold in the organization it was exported from.
+func hasSourceIDs(ruleset data.RepoRuleset) bool {
+	if ruleset.Conditions != nil && ruleset.Conditions.RepositoryID != nil && len(ruleset.Conditions.RepositoryID.RepositoryIDs) > 0 {
+		return true
+	}
+	for _, actor := range ruleset.BypassActors {
+		if actor.ActorID == nil || actor.ActorType == "DeployKey" {
+			continue
+		}
+		if _, ok := data.RolesMap[strconv.Itoa(*actor.ActorID)]; !ok {
+			return true
+		}
+	}
+	for _, rule := range ruleset.Rules {
+		if rule.Parameters == nil {
+			continue
+		}
+		if len(rule.Parameters.Workflows) > 0 {
+			return true
+		}
+		for _, statusCheck := range rule.Parameters.RequiredStatusChecks {
+			if statusCheck.IntegrationID != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func readRulesetJSONFiles(path string) ([]data.RepoRuleset, error) {
+	files, err := rulesetFiles(path, ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	var importRulesets []data.RepoRuleset
+	for _, file := range files {
+		zap.S().Debugf("Reading in rulesets from %s", file)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		var rulesets []data.RepoRuleset
+		content = bytes.TrimSpace(content)
+		if bytes.HasPrefix(content, []byte("[")) {
+			err = json.Unmarshal(content, &rulesets)
+		} else {
+			var ruleset data.RepoRuleset
+			err = json.Unmarshal(content, &ruleset)
+			rulesets = append(rulesets, ruleset)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		importRulesets = append(importRulesets, rulesets...)
+	}
+	return importRulesets, nil
+}
+
+// IsRulesetFileFormat reports whether path is a directory or a file with the
+// given extension.
+func IsRulesetFileFormat(path string, ext string) bool {
+	info, err := os.Stat(path)
+	if err == nil && info.IsDir() {
+		return true
+	}
+	return strings.EqualFold(filepath.Ext(path), ext)
+}
+
+func rulesetFiles(path string, ext string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(filePath), ext) {
+			files = append(files, filePath)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportRulesetsFromJSONMapsSourceIDs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"organization":{"databaseId":1}}}`)) // nolint:errcheck
+	})
+	mux.HandleFunc("/organizations/1/team/42", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(data.TeamInfo{ID: 42, Name: "Platform", Slug: "platform"}) // nolint:errcheck
+	})
+	mux.HandleFunc("/orgs/target/teams/platform", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(data.TeamInfo{ID: 99, Name: "platform"}) // nolint:errcheck
+	})
+	mux.HandleFunc("/orgs/target/teams/mapped", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(data.TeamInfo{ID: 77, Name: "mapped"}) // nolint:errcheck
+	})
+	g := newTestGetter(t, mux)
+	actorMap, err := ReadActorMap(writeTestFile(t, "actors.csv", "Type,Source,Target\nTeam,43,mapped\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetActorMap(actorMap)
+
+	path := writeTestFile(t, "rulesets.json", `[
+  {
+    "name": "main",
+    "target": "branch",
+    "source_type": "Organization",
+    "source": "source-org",
+    "enforcement": "active",
+    "bypass_actors": [
+      {"actor_id": 42, "actor_type": "Team", "bypass_mode": "always"},
+      {"actor_id": 43, "actor_type": "Team", "bypass_mode": "always"},
+      {"actor_id": 5, "actor_type": "RepositoryRole", "bypass_mode": "always"}
+    ],
+    "rules": [{"type": "deletion"}]
+  },
+  {
+    "name": "missing",
+    "target": "branch",
+    "source_type": "Repository",
+    "source": "source-org/app",
+    "enforcement": "active",
+    "bypass_actors": [{"actor_id": 44, "actor_type": "Team", "bypass_mode": "always"}],
+    "rules": [{"type": "deletion"}]
+  }
+]`)
+
+	rulesets, err := g.ImportRulesetsFromJSON("target", path, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rulesets) != 2 {
+		t.Fatalf("read %d rulesets, want 2", len(rulesets))
+	}
+
+	mapped := rulesets[0]
+	if mapped.Source != "target" {
+		t.Errorf("source = %s, want target", mapped.Source)
+	}
+	var actorIDs []int
+	for _, actor := range mapped.BypassActors {
+		actorIDs = append(actorIDs, *actor.ActorID)
+	}
+	if len(actorIDs) != 3 || actorIDs[0] != 99 || actorIDs[1] != 77 || actorIDs[2] != 5 {
+		t.Errorf("bypass actor IDs = %v, want [99 77 5]", actorIDs)
+	}
+	if len(mapped.Substitutions) != 2 || mapped.Substitutions[0].Status != "substituted" || mapped.Substitutions[1].Status != "substituted" {
+		t.Errorf("substitutions = %+v, want two substituted actors", mapped.Substitutions)
+	}
+
+	unresolved := rulesets[1]
+	if unresolved.Source != "target/app" {
+		t.Errorf("source = %s, want target/app", unresolved.Source)
+	}
+	if len(unresolved.BypassActors) != 0 || len(unresolved.Substitutions) != 1 || unresolved.Substitutions[0].Status != "failed" {
+		t.Errorf("ruleset = %+v, want the unresolved actor recorded as failed", unresolved)
+	}
+}
+
+func TestImportRulesetsFromJSONWithoutSource(t *testing.T) {
+	g := newTestGetter(t, http.NotFoundHandler())
+	path := writeTestFile(t, "ruleset.json", `{
+  "name": "main",
+  "target": "branch",
+  "source_type": "Organization",
+  "enforcement": "active",
+  "bypass_actors": [{"actor_id": 42, "actor_type": "Team", "bypass_mode": "always"}],
+  "rules": [{"type": "deletion"}]
+}`)
+	if _, err := g.ImportRulesetsFromJSON("target", path, g); err == nil || !strings.Contains(err.Error(), "source") {
+		t.Errorf("err = %v, want an error about the missing source", err)
+	}
+
+	path = writeTestFile(t, "ruleset.json", `{
+  "name": "main",
+  "target": "branch",
+  "source_type": "Organization",
+  "enforcement": "active",
+  "bypass_actors": [{"actor_id": 5, "actor_type": "RepositoryRole", "bypass_mode": "always"}],
+  "rules": [{"type": "deletion"}]
+}`)
+	rulesets, err := g.ImportRulesetsFromJSON("target", path, g)
+	if err != nil || len(rulesets) != 1 || len(rulesets[0].BypassActors) != 1 {
+		t.Errorf("rulesets = %+v, err = %v, want the ruleset with built-in roles only", rulesets, err)
+	}
+}
+
+func TestImportRulesetsFromJSONMapsRepositoryIDs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		var query struct {
+			Query     string
+			Variables map[string]interface{}
+		}
+		json.NewDecoder(r.Body).Decode(&query) // nolint:errcheck
+		if strings.Contains(query.Query, "repository(") && query.Variables["name"] == "api" {
+			w.Write([]byte(`{"data":{"repository":{"databaseId":100,"name":"api"}}}`)) // nolint:errcheck
+			return
+		}
+		w.Write([]byte(`{"data":{"organization":{"databaseId":1}}}`)) // nolint:errcheck
+	})
+	mux.HandleFunc("/repositories/10", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"databaseId":10,"name":"api"}`)) // nolint:errcheck
+	})
+	g := newTestGetter(t, mux)
+
+	path := writeTestFile(t, "ruleset.json", `{
+  "name": "main",
+  "target": "branch",
+  "source_type": "Organization",
+  "source": "source-org",
+  "enforcement": "active",
+  "conditions": {
+    "ref_name": {"include": ["~DEFAULT_BRANCH"], "exclude": []},
+    "repository_id": {"repository_ids": [10, 11]}
+  },
+  "rules": [{"type": "deletion"}]
+}`)
+	rulesets, err := g.ImportRulesetsFromJSON("target", path, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rulesets) != 1 {
+		t.Fatalf("read %d rulesets, want 1", len(rulesets))
+	}
+	ruleset := rulesets[0]
+	repoIDs := ruleset.Conditions.RepositoryID.RepositoryIDs
+	if len(repoIDs) != 1 || repoIDs[0] != 100 {
+		t.Errorf("repository IDs = %v, want [100]", repoIDs)
+	}
+	if len(ruleset.Substitutions) != 2 || ruleset.Substitutions[0].Status != "substituted" || ruleset.Substitutions[1].Status != "failed" {
+		t.Errorf("substitutions = %+v, want repository 10 substituted and 11 failed", ruleset.Substitutions)
+	}
+
+	entry, err := NewPlanEntry(ruleset, data.CreateRuleset{Name: ruleset.Name}, "target", "target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entry.Requests) != 0 || !strings.Contains(entry.Error, "repository_id") {
+		t.Errorf("entry = %+v, want an error for the unresolved repository", entry)
+	}
+}
@@ -1,23 +1,35 @@
 package utils
 
 import (
+	"strconv"
+
 	"github.com/katiem0/gh-migrate-rulesets/internal/data"
 	"go.uber.org/zap"
 )
 
-func (g *APIGetter) ParseRequiredWorkflowsForImport(owner string, value interface{}) []data.Workflows {
+func (g *APIGetter) ParseRequiredWorkflowsForImport(owner string, value interface{}) ([]data.Workflows, []data.Substitution) {
 	var workflows []data.Workflows
+	var substitutions []data.Substitution
 	v, ok := value.([]map[string]string)
 	if !ok {
 		zap.S().Error("Invalid type for value")
-		return workflows
+		return workflows, substitutions
 	}
 	for _, workflowMap := range v {
 		zap.S().Debugf("Gathering target repository %s ID for each workflow", workflowMap["RepositoryName"])
+		sourceID, _ := strconv.Atoi(workflowMap["RepositoryID"])
+		substitution := data.Substitution{
+			Field:    "workflows.repository_id",
+			Type:     "Repository",
+			Name:     workflowMap["RepositoryName"],
+			SourceID: sourceID,
+			Status:   "failed",
+		}
 
-		workflowRepoQuery, err := g.GetRepo(owner, workflowMap["RepositoryName"])
+		workflowRepoQuery, err := g.GetRepo(owner, g.MappedRepo(workflowMap["RepositoryName"]))
 		if err != nil {
 			zap.S().Error("Failed to get repository data for workflow")
+			substitutions = append(substitutions, substitution)
 			continue
 		} else {
 			workflow := data.Workflows{
@@ -27,27 +39,42 @@ func (g *APIGetter) ParseRequiredWorkflowsForImport(owner string, value interfac
 				SHA:          workflowMap["SHA"],
 			}
 			workflows = append(workflows, workflow)
+			substitution.TargetID = workflowRepoQuery.Repository.DatabaseId
+			substitution.Status = "substituted"
+			substitutions = append(substitutions, substitution)
 		}
 	}
-	return workflows
+	return workflows, substitutions
 }
 
 func (g *APIGetter) UpdateRequiredWorkflowRepoID(owner string, ruleset data.RepoRuleset, s *APIGetter) data.RepoRuleset {
 	for i, rule := range ruleset.Rules {
-		if rule.Type == "workflows" {
+		if rule.Type == "workflows" && rule.Parameters != nil {
 			for j, workflow := range rule.Parameters.Workflows {
 				zap.S().Debugf("Gathering target repository %d ID for each workflow", workflow.RepositoryID)
+				substitution := data.Substitution{
+					Field:    "workflows.repository_id",
+					Type:     "Repository",
+					SourceID: workflow.RepositoryID,
+					Status:   "failed",
+				}
 				sourceWorkflowRepoQuery, err := s.GetRepoByID(workflow.RepositoryID)
 				if err != nil {
 					zap.S().Error("Failed to get repository data for workflow")
+					ruleset.Substitutions = append(ruleset.Substitutions, substitution)
 					continue
 				} else {
-					workflowRepo, err := g.GetRepo(owner, sourceWorkflowRepoQuery.Name)
+					substitution.Name = sourceWorkflowRepoQuery.Name
+					workflowRepo, err := g.GetRepo(owner, g.MappedRepo(sourceWorkflowRepoQuery.Name))
 					if err != nil {
 						zap.S().Error("Failed to get repository data for workflow")
+						ruleset.Substitutions = append(ruleset.Substitutions, substitution)
 						continue
 					}
 					ruleset.Rules[i].Parameters.Workflows[j].RepositoryID = workflowRepo.Repository.DatabaseId
+					substitution.TargetID = workflowRepo.Repository.DatabaseId
+					substitution.Status = "substituted"
+					ruleset.Substitutions = append(ruleset.Substitutions, substitution)
 				}
 			}
 		}
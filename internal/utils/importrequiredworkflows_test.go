@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func TestUnresolvedWorkflowRepoKeptOutOfPlan(t *testing.T) {
+	g := newTestGetter(t, http.NotFoundHandler())
+	sourceRuleset := data.RepoRuleset{
+		Name:       "main",
+		SourceType: "Organization",
+		Rules: []data.Rules{{Type: "workflows", Parameters: &data.Parameters{
+			Workflows: []data.Workflows{{Path: ".github/workflows/ci.yml", Ref: "main", RepositoryID: 42}},
+		}}},
+	}
+
+	fromSource := g.UpdateRequiredWorkflowRepoID("target", sourceRuleset, g)
+	fromFile := data.RepoRuleset{Name: "main", SourceType: "Organization"}
+	workflows, substitutions := g.ParseRequiredWorkflowsForImport("target", []map[string]string{
+		{"Path": ".github/workflows/ci.yml", "Ref": "main", "RepositoryID": "42", "RepositoryName": "workflows"},
+	})
+	fromFile.Rules = []data.Rules{{Type: "workflows", Parameters: &data.Parameters{Workflows: workflows}}}
+	fromFile.Substitutions = substitutions
+
+	for name, ruleset := range map[string]data.RepoRuleset{"source org": fromSource, "file": fromFile} {
+		t.Run(name, func(t *testing.T) {
+			if len(ruleset.Substitutions) != 1 || ruleset.Substitutions[0].Status != "failed" {
+				t.Errorf("substitutions = %+v, want one failed repository substitution", ruleset.Substitutions)
+			}
+			createRuleset, err := ProcessRulesets(ruleset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry, err := NewPlanEntry(ruleset, createRuleset, "source", "target")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entry.Error) == 0 || len(entry.Requests) != 0 {
+				t.Errorf("entry = %+v, want an error without requests", entry)
+			}
+		})
+	}
+}
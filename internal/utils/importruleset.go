@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -10,32 +11,165 @@ import (
 	"go.uber.org/zap"
 )
 
-func (g *APIGetter) CreateRepoRulesetsData(owner string, fileData [][]string) []data.RepoRuleset {
+// RulesetCSVColumns locates the columns of a ruleset csv file by header name,
+// so columns can be in any order and missing columns read as empty values.
+type RulesetCSVColumns map[string]int
+
+// requiredRulesetCSVColumns are the columns a ruleset csv file must have.
+var requiredRulesetCSVColumns = []string{"RulesetName", "RulesetLevel"}
+
+// NewRulesetCSVColumns indexes the header row of ruleset csv file data,
+// warning about columns that are not part of the ruleset csv schema. An error
+// is returned when the file is empty or a required column is missing.
+func NewRulesetCSVColumns(fileData [][]string) (RulesetCSVColumns, error) {
+	if len(fileData) == 0 {
+		return nil, errors.New("file is empty")
+	}
+	known := make(map[string]struct{}, len(data.RulesetCSVHeaders))
+	for _, column := range data.RulesetCSVHeaders {
+		known[column] = struct{}{}
+	}
+	columns := make(RulesetCSVColumns)
+	for i, column := range fileData[0] {
+		if _, ok := known[column]; !ok {
+			zap.S().Warnf("Ignoring unknown column %s", column)
+			continue
+		}
+		if _, ok := columns[column]; ok {
+			zap.S().Warnf("Ignoring duplicate column %s", column)
+			continue
+		}
+		columns[column] = i
+	}
+	for _, column := range requiredRulesetCSVColumns {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("missing required column %s", column)
+		}
+	}
+	return columns, nil
+}
+
+// Value returns the value of a column in a record, or an empty string when the
+// file does not have the column.
+func (c RulesetCSVColumns) Value(record []string, column string) string {
+	if i, ok := c[column]; ok && i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+// checkSchemaVersion warns when a record was written with a newer version of
+// the ruleset csv schema, whose new columns will be ignored.
+func (c RulesetCSVColumns) checkSchemaVersion(record []string, warned *bool) {
+	version := c.Value(record, "SchemaVersion")
+	if len(version) == 0 || *warned {
+		return
+	}
+	if v, err := strconv.Atoi(version); err == nil && v > data.RulesetCSVSchemaVersion {
+		zap.S().Warnf("File was written with schema version %d, newer than the supported version %d, so new columns will be ignored", v, data.RulesetCSVSchemaVersion)
+		*warned = true
+	}
+}
+
+// Record returns a record with its composite columns in the escaped format of
+// the current schema version. Files written before escaping was introduced
+// have any backslashes in those columns escaped, so they read as before.
+func (c RulesetCSVColumns) Record(record []string) []string {
+	version, _ := strconv.Atoi(c.Value(record, "SchemaVersion"))
+	if version >= data.RulesetCSVEscapingSchemaVersion {
+		return record
+	}
+	escaped := make([]string, len(record))
+	copy(escaped, record)
+	for column, i := range c {
+		if i < len(escaped) && isCompositeCSVColumn(column) {
+			escaped[i] = strings.ReplaceAll(escaped[i], `\`, `\\`)
+		}
+	}
+	return escaped
+}
+
+// isCompositeCSVColumn reports whether a column holds values structured with
+// separators, which are escaped when written.
+func isCompositeCSVColumn(column string) bool {
+	if _, ok := data.HeaderMap[column]; ok {
+		return true
+	}
+	return column == "BypassActors" || strings.HasPrefix(column, "Condition")
+}
+
+func (g *APIGetter) CreateRepoRulesetsData(owner string, fileData [][]string) ([]data.RepoRuleset, error) {
 	var importRepoRuleset []data.RepoRuleset
-	var repoRuleset data.RepoRuleset
-	headerMap := make(map[string]int)
-	for i, header := range fileData[0] {
-		headerMap[header] = i
+	columns, err := NewRulesetCSVColumns(fileData)
+	if err != nil {
+		return nil, err
 	}
+	warned := false
 
 	for _, each := range fileData[1:] {
 		zap.S().Debugf("Gathering info for each ruleset")
-		repoRuleset.ID, _ = strconv.Atoi(each[headerMap["RuleID"]])
-		repoRuleset.Name = each[headerMap["RulesetName"]]
-		repoRuleset.Target = each[headerMap["Target"]]
-		repoRuleset.SourceType = each[headerMap["RulesetLevel"]]
-		repoRuleset.Source = determineSource(owner, each[headerMap["RulesetLevel"]], each[headerMap["RepositoryName"]])
-		repoRuleset.Enforcement = each[headerMap["Enforcement"]]
-		repoRuleset.BypassActors = g.ParseBypassActorsForImport(owner, each[headerMap["BypassActors"]])
-		repoRuleset.Conditions = parseConditions(each[headerMap["ConditionsRefNameInclude"] : headerMap["ConditionRepoPropertyExclude"]+1])
-		ruleHeaders := fileData[0][14:35]
-		ruleValues := each[14:35]
-		repoRuleset.Rules = g.parseRules(owner, ruleHeaders, ruleValues)
-		repoRuleset.CreatedAt = each[headerMap["CreatedAt"]]
-		repoRuleset.UpdatedAt = each[headerMap["UpdatedAt"]]
+		columns.checkSchemaVersion(each, &warned)
+		each = columns.Record(each)
+		var repoRuleset data.RepoRuleset
+		repoRuleset.ID, _ = strconv.Atoi(columns.Value(each, "RuleID"))
+		repoRuleset.Name = columns.Value(each, "RulesetName")
+		repoRuleset.Target = columns.Value(each, "Target")
+		repoRuleset.SourceType = columns.Value(each, "RulesetLevel")
+		repoRuleset.Source = determineSource(owner, repoRuleset.SourceType, columns.Value(each, "RepositoryName"))
+		repoRuleset.Enforcement = columns.Value(each, "Enforcement")
+		repoRuleset.BypassActors, repoRuleset.Substitutions = g.ParseBypassActorsForImport(owner, columns.Value(each, "BypassActors"))
+		repoRuleset.Conditions = parseConditions(columns, each)
+		if orgIDsStr := columns.Value(each, "ConditionsOrgID"); len(orgIDsStr) > 0 {
+			orgIDs, orgSubstitutions := g.ParseOrgIDsForImport(orgIDsStr)
+			repoRuleset.Conditions.OrganizationID = &data.OrgIDPatterns{OrganizationIDs: orgIDs}
+			repoRuleset.Substitutions = append(repoRuleset.Substitutions, orgSubstitutions...)
+		}
+		var ruleHeaders, ruleValues []string
+		for _, header := range data.RulesetCSVHeaders {
+			if _, ok := data.HeaderMap[header]; ok {
+				ruleHeaders = append(ruleHeaders, header)
+				ruleValues = append(ruleValues, columns.Value(each, header))
+			}
+		}
+		rules, workflowSubstitutions := g.parseRules(owner, ruleHeaders, ruleValues)
+		rules, err := ApplyRawRules(rules, columns.Value(each, "RulesRawJSON"))
+		if err != nil {
+			zap.S().Errorf("Error reading RulesRawJSON of ruleset %s: %v", repoRuleset.Name, err)
+		}
+		repoRuleset.Rules = rules
+		repoRuleset.Substitutions = append(repoRuleset.Substitutions, workflowSubstitutions...)
+		repoRuleset.CreatedAt = columns.Value(each, "CreatedAt")
+		repoRuleset.UpdatedAt = columns.Value(each, "UpdatedAt")
 		importRepoRuleset = append(importRepoRuleset, repoRuleset)
 	}
-	return importRepoRuleset
+	return importRepoRuleset, nil
+}
+
+// ReadRulesetConditionsFromCSV reads the name, level, target, enforcement and
+// conditions of each ruleset in csv file data, without looking up bypass
+// actors, organizations or workflow repositories, so it can be used offline.
+func ReadRulesetConditionsFromCSV(owner string, fileData [][]string) ([]data.RepoRuleset, error) {
+	var rulesets []data.RepoRuleset
+	columns, err := NewRulesetCSVColumns(fileData)
+	if err != nil {
+		return nil, err
+	}
+	warned := false
+
+	for _, each := range fileData[1:] {
+		columns.checkSchemaVersion(each, &warned)
+		each = columns.Record(each)
+		level := columns.Value(each, "RulesetLevel")
+		rulesets = append(rulesets, data.RepoRuleset{
+			Name:        columns.Value(each, "RulesetName"),
+			Target:      columns.Value(each, "Target"),
+			SourceType:  level,
+			Source:      determineSource(owner, level, columns.Value(each, "RepositoryName")),
+			Enforcement: columns.Value(each, "Enforcement"),
+			Conditions:  parseConditions(columns, each),
+		})
+	}
+	return rulesets, nil
 }
 
 func determineSource(owner, sourceType, repoName string) string {
@@ -47,45 +181,89 @@ func determineSource(owner, sourceType, repoName string) string {
 	return ""
 }
 
-func parseConditions(conditions []string) *data.Conditions {
+// ParseOrgIDsForImport parses the ID;Login pairs of an organization_id
+// condition, looking up each organization by login so the condition refers to
+// the same organizations when recreated in another enterprise. Organizations
+// that cannot be found are left out and recorded as failed substitutions.
+func (g *APIGetter) ParseOrgIDsForImport(orgIDsStr string) ([]int, []data.Substitution) {
+	var orgIDs []int
+	var substitutions []data.Substitution
+	for _, org := range SplitEscaped(orgIDsStr, '|') {
+		orgData := SplitCSVValue(org, ';')
+		sourceID, err := strconv.Atoi(orgData[0])
+		if err != nil {
+			zap.S().Debugf("Invalid organization ID %s", orgData[0])
+			continue
+		}
+		if len(orgData) < 2 || len(orgData[1]) == 0 {
+			orgIDs = append(orgIDs, sourceID)
+			continue
+		}
+		substitution := data.Substitution{
+			Field:    "conditions.organization_id",
+			Type:     "Organization",
+			Name:     orgData[1],
+			SourceID: sourceID,
+		}
+		orgIDData, err := g.FetchOrgId(orgData[1])
+		if err != nil {
+			zap.S().Infof("Failed to get organization data for %s: %v", orgData[1], err)
+			substitution.Status = "failed"
+			substitutions = append(substitutions, substitution)
+			continue
+		}
+		substitution.TargetID = orgIDData.Organization.DatabaseID
+		substitution.Status = "substituted"
+		substitutions = append(substitutions, substitution)
+		orgIDs = append(orgIDs, substitution.TargetID)
+	}
+	return orgIDs, substitutions
+}
+
+func parseConditions(columns RulesetCSVColumns, record []string) *data.Conditions {
 	zap.S().Debugln("Validating ruleset conditions")
 	return &data.Conditions{
 		RefName: &data.RefPatterns{
-			Include: strings.Split(conditions[0], ";"),
-			Exclude: strings.Split(conditions[1], ";"),
+			Include: SplitCSVValue(columns.Value(record, "ConditionsRefNameInclude"), ';'),
+			Exclude: SplitCSVValue(columns.Value(record, "ConditionsRefNameExclude"), ';'),
 		},
 		RepositoryName: &data.NamePatterns{
-			Include:   strings.Split(conditions[2], ";"),
-			Exclude:   strings.Split(conditions[3], ";"),
-			Protected: conditions[4] == "true",
+			Include:   SplitCSVValue(columns.Value(record, "ConditionsRepoNameInclude"), ';'),
+			Exclude:   SplitCSVValue(columns.Value(record, "ConditionsRepoNameExclude"), ';'),
+			Protected: columns.Value(record, "ConditionsRepoNameProtected") == "true",
 		},
 		RepositoryProperty: &data.PropertyPatterns{
-			Include: parsePropertyPatterns(conditions[5]),
-			Exclude: parsePropertyPatterns(conditions[6]),
+			Include: parsePropertyPatterns(columns.Value(record, "ConditionRepoPropertyInclude")),
+			Exclude: parsePropertyPatterns(columns.Value(record, "ConditionRepoPropertyExclude")),
+		},
+		OrganizationName: &data.OrgNamePatterns{
+			Include: SplitCSVValue(columns.Value(record, "ConditionsOrgNameInclude"), ';'),
+			Exclude: SplitCSVValue(columns.Value(record, "ConditionsOrgNameExclude"), ';'),
 		},
 	}
 }
 
 func parsePropertyPatterns(patternsStr string) []data.PropertyPattern {
-	patterns := SplitIgnoringBraces(patternsStr, "|")
+	patterns := SplitEscaped(patternsStr, '|')
 	propertyPatterns := make([]data.PropertyPattern, 0, len(patterns))
 	for _, pattern := range patterns {
-		patternData := strings.Split(pattern, ";")
-		if len(patternData) < 2 {
+		patternData := SplitEscaped(pattern, ';')
+		if len(patternData) < 3 {
 			continue
 		}
-		valueTrimmed := strings.Trim(patternData[2], "{}")
+		valueTrimmed, _ := trimEscapedDelimiters(patternData[2], '{', '}')
 		propertyPatterns = append(propertyPatterns, data.PropertyPattern{
-			Name:           patternData[0],
-			Source:         patternData[1],
-			PropertyValues: strings.Split(valueTrimmed, "|"),
+			Name:           UnescapeCSVValue(patternData[0]),
+			Source:         UnescapeCSVValue(patternData[1]),
+			PropertyValues: SplitCSVValue(valueTrimmed, '|'),
 		})
 	}
 	return propertyPatterns
 }
 
-func (g *APIGetter) parseRules(owner string, headerMap []string, ruleValues []string) []data.Rules {
+func (g *APIGetter) parseRules(owner string, headerMap []string, ruleValues []string) ([]data.Rules, []data.Substitution) {
 	rules := make([]data.Rules, 0, len(headerMap))
+	var substitutions []data.Substitution
 
 	for i := 0; i < len(headerMap) && i < len(ruleValues); i++ {
 		if ruleValues[i] == "" {
@@ -97,20 +275,29 @@ func (g *APIGetter) parseRules(owner string, headerMap []string, ruleValues []st
 		}
 		if ruleValues[i] != "" {
 			parameters := ParseParameters(ruleValues[i])
-			rule.Parameters = g.MapToParameters(owner, parameters, header)
+			var ruleSubstitutions []data.Substitution
+			rule.Parameters, ruleSubstitutions = g.MapToParameters(owner, parameters, header)
+			substitutions = append(substitutions, ruleSubstitutions...)
 		} else {
 			zap.S().Debugf("%s does not contain Parameters", header)
 		}
 		rules = append(rules, rule)
 	}
-	return rules
+	return rules, substitutions
 }
 
 func CleanConditions(conditions *data.Conditions) *data.Conditions {
-	conditions.RefName.Include = CleanSlice(conditions.RefName.Include)
-	conditions.RefName.Exclude = CleanSlice(conditions.RefName.Exclude)
-	conditions.RepositoryName.Include = CleanSlice(conditions.RepositoryName.Include)
-	conditions.RepositoryName.Exclude = CleanSlice(conditions.RepositoryName.Exclude)
+	if conditions == nil {
+		return nil
+	}
+	if conditions.RefName != nil {
+		conditions.RefName.Include = CleanSlice(conditions.RefName.Include)
+		conditions.RefName.Exclude = CleanSlice(conditions.RefName.Exclude)
+	}
+	if conditions.RepositoryName != nil {
+		conditions.RepositoryName.Include = CleanSlice(conditions.RepositoryName.Include)
+		conditions.RepositoryName.Exclude = CleanSlice(conditions.RepositoryName.Exclude)
+	}
 
 	if ShouldRemoveRepositoryName(conditions.RepositoryName) {
 		conditions.RepositoryName = nil
@@ -121,6 +308,26 @@ func CleanConditions(conditions *data.Conditions) *data.Conditions {
 	if ShouldRemoveProperty(conditions.RepositoryProperty) {
 		conditions.RepositoryProperty = nil
 	}
+	if conditions.OrganizationName != nil {
+		conditions.OrganizationName.Include = CleanSlice(conditions.OrganizationName.Include)
+		conditions.OrganizationName.Exclude = CleanSlice(conditions.OrganizationName.Exclude)
+		if len(conditions.OrganizationName.Include) == 0 && len(conditions.OrganizationName.Exclude) == 0 {
+			conditions.OrganizationName = nil
+		} else {
+			if conditions.OrganizationName.Include == nil {
+				conditions.OrganizationName.Include = []string{}
+			}
+			if conditions.OrganizationName.Exclude == nil {
+				conditions.OrganizationName.Exclude = []string{}
+			}
+		}
+	}
+	if conditions.OrganizationID != nil && len(conditions.OrganizationID.OrganizationIDs) == 0 {
+		conditions.OrganizationID = nil
+	}
+	if conditions.RepositoryID != nil && len(conditions.RepositoryID.RepositoryIDs) == 0 {
+		conditions.RepositoryID = nil
+	}
 	if conditions.RefName != nil {
 		if conditions.RefName.Include == nil {
 			conditions.RefName.Include = []string{}
@@ -178,6 +385,10 @@ func ProcessRulesets(ruleset data.RepoRuleset) (data.CreateRuleset, error) {
 	}
 	zap.S().Debugf("Removing omitempty from fields if needed from ruleset: %s", ruleset.Name)
 	for i, rule := range ruleset.Rules {
+		if len(rule.RawParameters) > 0 && !KnownRuleType(rule.Type) {
+			createRuleset.Rules[i] = data.CreateRules{Type: rule.Type, Parameters: rule.RawParameters}
+			continue
+		}
 		if rule.Parameters == nil {
 			createRuleset.Rules[i].Type = rule.Type
 			continue
@@ -211,6 +422,13 @@ func ProcessRulesets(ruleset data.RepoRuleset) (data.CreateRuleset, error) {
 			}
 			createRuleset.Rules[i].Type = rule.Type
 			createRuleset.Rules[i].Parameters = newStruct.Interface()
+			if len(rule.RawParameters) > 0 {
+				parameters, err := mergeRawParameters(newStruct.Interface(), rule.RawParameters)
+				if err != nil {
+					return createRuleset, fmt.Errorf("failed to read raw parameters of rule %s: %w", rule.Type, err)
+				}
+				createRuleset.Rules[i].Parameters = parameters
+			}
 		}
 	}
 	return createRuleset, nil
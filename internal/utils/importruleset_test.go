@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+// The update rule requires update_allows_fetch_and_merge, so it is sent even
+// when false. Listing the JSON name rather than the field name in
+// NonOmitEmptyFields left it out, sending "parameters":{} instead.
+func TestProcessRulesetsUpdatePayload(t *testing.T) {
+	tests := []struct {
+		name string
+		rule data.Rules
+		want string
+	}{
+		{
+			name: "update disallowing fetch and merge",
+			rule: data.Rules{Type: "update", Parameters: &data.Parameters{}},
+			want: `{"type":"update","parameters":{"update_allows_fetch_and_merge":false}}`,
+		},
+		{
+			name: "update allowing fetch and merge",
+			rule: data.Rules{Type: "update", Parameters: &data.Parameters{UpdateAllowsFetchAndMerge: true}},
+			want: `{"type":"update","parameters":{"update_allows_fetch_and_merge":true}}`,
+		},
+		{
+			name: "other rules leave the parameter out",
+			rule: data.Rules{Type: "non_fast_forward", Parameters: &data.Parameters{}},
+			want: `{"type":"non_fast_forward","parameters":{}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			createRuleset, err := ProcessRulesets(data.RepoRuleset{Name: "main", Rules: []data.Rules{tt.rule}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload, err := json.Marshal(createRuleset.Rules[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(payload) != tt.want {
+				t.Errorf("payload = %s, want %s", payload, tt.want)
+			}
+		})
+	}
+}
+
+// A locked branch converts into an update rule, which has to survive being
+// written to and read back from the csv format used by default.
+func TestConvertedLockBranchCSVRoundTrip(t *testing.T) {
+	g := newTestGetter(t, http.NotFoundHandler())
+	for _, allowsFetchAndSync := range []bool{false, true} {
+		rule := data.BranchProtectionRule{
+			Pattern:                "main",
+			AllowsDeletions:        true,
+			AllowsForcePushes:      true,
+			IsAdminEnforced:        true,
+			LockBranch:             true,
+			LockAllowsFetchAndSync: allowsFetchAndSync,
+		}
+		ruleset, _ := ConvertBranchProtection("source", "repo", rule, "active")
+		row := g.RulesetToCSVRow(ruleset, "source", 1)
+
+		imported, err := g.CreateRepoRulesetsData("target", [][]string{data.RulesetCSVHeaders, row})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(imported) != 1 {
+			t.Fatalf("imported %d rulesets, want 1", len(imported))
+		}
+		createRuleset, err := ProcessRulesets(imported[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := json.Marshal(createRuleset.Rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf(`[{"type":"update","parameters":{"update_allows_fetch_and_merge":%v}}]`, allowsFetchAndSync)
+		if string(payload) != want {
+			t.Errorf("LockAllowsFetchAndSync %v: rules = %s, want %s", allowsFetchAndSync, payload, want)
+		}
+	}
+}
+
+func TestCreateRepoRulesetsDataColumnsByHeader(t *testing.T) {
+	g := newTestGetter(t, http.NotFoundHandler())
+	fileData := [][]string{
+		{"Notes", "RulesPullRequest", "RulesetName", "RulesDeletion", "RepositoryName", "ConditionsRefNameInclude", "RulesetLevel", "RulesetName", "Enforcement", "SchemaVersion"},
+		{"ignored", "RequiredApprovingReviewCount:2", "main", "true", "app", "refs/heads/main;refs/heads/release", "Repository", "duplicate", "evaluate", "4"},
+	}
+
+	rulesets, err := g.CreateRepoRulesetsData("org", fileData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rulesets) != 1 {
+		t.Fatalf("read %d rulesets, want 1", len(rulesets))
+	}
+	ruleset := rulesets[0]
+	if ruleset.Name != "main" || ruleset.SourceType != "Repository" || ruleset.Source != "org/app" || ruleset.Enforcement != "evaluate" {
+		t.Errorf("ruleset = %+v, want main in org/app with evaluate enforcement", ruleset)
+	}
+	if ruleset.Target != "" || ruleset.ID != 0 || len(ruleset.BypassActors) != 0 {
+		t.Errorf("missing columns read as %q, %d, %+v, want empty values", ruleset.Target, ruleset.ID, ruleset.BypassActors)
+	}
+	wantRefNames := []string{"refs/heads/main", "refs/heads/release"}
+	if !reflect.DeepEqual(ruleset.Conditions.RefName.Include, wantRefNames) {
+		t.Errorf("ref name include = %v, want %v", ruleset.Conditions.RefName.Include, wantRefNames)
+	}
+	var ruleTypes []string
+	for _, rule := range ruleset.Rules {
+		ruleTypes = append(ruleTypes, rule.Type)
+		if rule.Type == "pull_request" && (rule.Parameters == nil || rule.Parameters.RequiredApprovingReviewCount != 2) {
+			t.Errorf("pull_request parameters = %+v, want 2 required approvals", rule.Parameters)
+		}
+	}
+	if !reflect.DeepEqual(ruleTypes, []string{"deletion", "pull_request"}) {
+		t.Errorf("rules = %v, want deletion and pull_request", ruleTypes)
+	}
+}
+
+func TestNewRulesetCSVColumnsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileData [][]string
+		want     string
+	}{
+		{name: "empty file", want: "file is empty"},
+		{name: "missing name", fileData: [][]string{{"RulesetLevel", "Target"}}, want: "missing required column RulesetName"},
+		{name: "missing level", fileData: [][]string{{"RulesetName", "Target"}}, want: "missing required column RulesetLevel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRulesetCSVColumns(tt.fileData); err == nil || err.Error() != tt.want {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+			if _, err := ReadRulesetConditionsFromCSV("org", tt.fileData); err == nil {
+				t.Error("ReadRulesetConditionsFromCSV did not return an error")
+			}
+		})
+	}
+}
+
+func TestParseOrgIDsForImportUnresolvedOrg(t *testing.T) {
+	g := newTestGetter(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body) // nolint:errcheck
+		if body.Variables["owner"] == "found" {
+			w.Write([]byte(`{"data":{"organization":{"databaseId":200}}}`)) // nolint:errcheck
+			return
+		}
+		w.Write([]byte(`{"errors":[{"message":"Could not resolve to an Organization"}]}`)) // nolint:errcheck
+	}))
+
+	orgIDs, substitutions := g.ParseOrgIDsForImport("1;found|2;gone")
+	if !reflect.DeepEqual(orgIDs, []int{200}) {
+		t.Errorf("orgIDs = %v, want only the resolved organization", orgIDs)
+	}
+	if len(substitutions) != 2 || substitutions[1].Status != "failed" || substitutions[1].SourceID != 2 {
+		t.Fatalf("substitutions = %+v, want organization gone failed", substitutions)
+	}
+
+	ruleset := data.RepoRuleset{
+		Name:          "main",
+		SourceType:    "Enterprise",
+		Conditions:    &data.Conditions{OrganizationID: &data.OrgIDPatterns{OrganizationIDs: orgIDs}},
+		Substitutions: substitutions,
+	}
+	createRuleset, err := ProcessRulesets(ruleset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := NewPlanEntry(ruleset, createRuleset, "source", "enterprise")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entry.Error) == 0 || len(entry.Requests) != 0 {
+		t.Errorf("entry = %+v, want an error without requests", entry)
+	}
+}
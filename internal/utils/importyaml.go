@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ReadRulesetsFromDir reads rulesets from a directory of YAML and JSON files
+// laid out as org/<name>.yaml and repos/<repo>/<name>.yaml. The IDs in JSON
+// files are mapped from their source organization with ImportRulesetsFromJSON.
+func (g *APIGetter) ReadRulesetsFromDir(owner string, dir string, s *APIGetter) ([]data.RepoRuleset, error) {
+	var importRulesets []data.RepoRuleset
+	for _, ext := range []string{".yaml", ".yml"} {
+		yamlRulesets, err := g.ReadRulesetsFromYAML(owner, dir, ext)
+		if err != nil {
+			return nil, err
+		}
+		importRulesets = append(importRulesets, yamlRulesets...)
+	}
+	jsonRulesets, err := g.ImportRulesetsFromJSON(owner, dir, s)
+	if err != nil {
+		return nil, err
+	}
+	return append(importRulesets, jsonRulesets...), nil
+}
+
+// ReadRulesetsFromYAML reads rulesets from a YAML file, which may contain
+// multiple documents, or from a directory of files with the given extension.
+func (g *APIGetter) ReadRulesetsFromYAML(owner string, path string, ext string) ([]data.RepoRuleset, error) {
+	files, err := rulesetFiles(path, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	var importRulesets []data.RepoRuleset
+	for _, file := range files {
+		zap.S().Debugf("Reading in rulesets from %s", file)
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		decoder := yaml.NewDecoder(f)
+		for {
+			var yamlRuleset data.YAMLRuleset
+			err = decoder.Decode(&yamlRuleset)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+			inferRulesetLevel(&yamlRuleset, path, file)
+			importRulesets = append(importRulesets, g.YAMLToRuleset(owner, yamlRuleset))
+		}
+		f.Close()
+	}
+	return importRulesets, nil
+}
+
+// inferRulesetLevel fills in the level and repository of a YAML ruleset from
+// its location in the directory layout when they are not set in the file.
+func inferRulesetLevel(yamlRuleset *data.YAMLRuleset, root string, file string) {
+	if len(yamlRuleset.Level) > 0 {
+		return
+	}
+	relPath, err := filepath.Rel(root, file)
+	if err != nil {
+		return
+	}
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	if len(parts) == 2 && parts[0] == "org" {
+		yamlRuleset.Level = "Organization"
+	} else if len(parts) == 2 && parts[0] == "enterprise" {
+		yamlRuleset.Level = "Enterprise"
+	} else if len(parts) == 3 && parts[0] == "repos" {
+		yamlRuleset.Level = "Repository"
+		if len(yamlRuleset.Repository) == 0 {
+			yamlRuleset.Repository = parts[1]
+		}
+	}
+}
+
+// YAMLToRuleset converts a YAML ruleset into a ruleset for owner, resolving
+// bypass actors, required workflow repositories and the repositories of a
+// repository_id condition by name.
+func (g *APIGetter) YAMLToRuleset(owner string, yamlRuleset data.YAMLRuleset) data.RepoRuleset {
+	ruleset := data.RepoRuleset{
+		Name:        yamlRuleset.Name,
+		Target:      yamlRuleset.Target,
+		SourceType:  yamlRuleset.Level,
+		Source:      determineSource(owner, yamlRuleset.Level, yamlRuleset.Repository),
+		Enforcement: yamlRuleset.Enforcement,
+		Conditions:  yamlRuleset.Conditions,
+	}
+
+	for _, actor := range yamlRuleset.BypassActors {
+		if roleID, ok := builtInRoleID(actor.Name); ok && actor.Type != "Team" && actor.Type != "Integration" {
+			var actorID *int
+			if actor.Type != "DeployKey" {
+				id, _ := strconv.Atoi(roleID)
+				actorID = &id
+			}
+			ruleset.BypassActors = append(ruleset.BypassActors, data.BypassActor{ActorID: actorID, ActorType: actor.Type, BypassMode: actor.Mode})
+			continue
+		}
+		substitution := data.Substitution{
+			Field: "bypass_actors",
+			Type:  actor.Type,
+			Name:  actor.Name,
+		}
+		targetID, err := g.ResolveActorID(owner, actor.Type, actor.Name)
+		if err != nil {
+			zap.S().Infof("Failed to get %s data for %s: %v", actor.Type, actor.Name, err)
+			substitution.Status = "failed"
+			ruleset.Substitutions = append(ruleset.Substitutions, substitution)
+			continue
+		}
+		substitution.TargetID = targetID
+		substitution.Status = "substituted"
+		ruleset.Substitutions = append(ruleset.Substitutions, substitution)
+		ruleset.BypassActors = append(ruleset.BypassActors, data.BypassActor{ActorID: &targetID, ActorType: actor.Type, BypassMode: actor.Mode})
+	}
+
+	if yamlRuleset.Conditions != nil && yamlRuleset.Conditions.RepositoryID != nil {
+		var substitutions []data.Substitution
+		ruleset.Conditions, substitutions = g.conditionRepoNamesToIDs(owner, yamlRuleset.Name, yamlRuleset.Conditions)
+		ruleset.Substitutions = append(ruleset.Substitutions, substitutions...)
+	}
+
+	for _, yamlRule := range yamlRuleset.Rules {
+		rule := data.Rules{Type: yamlRule.Type}
+		if yamlRule.Parameters != nil {
+			ruleset.Substitutions = append(ruleset.Substitutions, g.workflowRepoNamesToIDs(owner, yamlRule.Parameters)...)
+			ruleset.Substitutions = append(ruleset.Substitutions, g.statusCheckSlugsToIDs(owner, yamlRule.Parameters)...)
+			parametersJSON, err := json.Marshal(yamlRule.Parameters)
+			if err != nil {
+				zap.S().Errorf("Error marshaling parameters of rule %s: %v", yamlRule.Type, err)
+				continue
+			}
+			rule.RawParameters = parametersJSON
+			if KnownRuleType(yamlRule.Type) {
+				var parameters data.Parameters
+				if err := json.Unmarshal(parametersJSON, &parameters); err != nil {
+					zap.S().Errorf("Error parsing parameters of rule %s: %v", yamlRule.Type, err)
+					continue
+				}
+				rule.Parameters = &parameters
+			}
+		}
+		ruleset.Rules = append(ruleset.Rules, rule)
+	}
+	return ruleset
+}
+
+// conditionRepoNamesToIDs returns a copy of conditions with the repositories
+// of a repository_id condition resolved by name under owner. Repositories that
+// cannot be found, and repositories only listed by ID, are recorded as failed
+// substitutions.
+func (g *APIGetter) conditionRepoNamesToIDs(owner string, rulesetName string, conditions *data.Conditions) (*data.Conditions, []data.Substitution) {
+	var substitutions []data.Substitution
+	for _, repoID := range conditions.RepositoryID.RepositoryIDs {
+		zap.S().Infof("Repository %d of the repository_id condition of ruleset %s cannot be mapped, as YAML files do not record the organization it belongs to", repoID, rulesetName)
+		substitutions = append(substitutions, data.Substitution{
+			Field:    "conditions.repository_id",
+			Type:     "Repository",
+			SourceID: repoID,
+			Status:   "failed",
+		})
+	}
+	repoIDs := make([]int, 0, len(conditions.RepositoryID.RepositoryNames))
+	for _, repoName := range conditions.RepositoryID.RepositoryNames {
+		substitution := data.Substitution{
+			Field:  "conditions.repository_id",
+			Type:   "Repository",
+			Name:   repoName,
+			Status: "failed",
+		}
+		repo, err := g.GetRepo(owner, g.MappedRepo(repoName))
+		if err != nil {
+			zap.S().Errorf("Failed to get repository %s of the repository_id condition of ruleset %s: %v", repoName, rulesetName, err)
+			substitutions = append(substitutions, substitution)
+			continue
+		}
+		repoIDs = append(repoIDs, repo.Repository.DatabaseId)
+		substitution.TargetID = repo.Repository.DatabaseId
+		substitution.Status = "substituted"
+		substitutions = append(substitutions, substitution)
+	}
+	resolved := *conditions
+	resolved.RepositoryID = &data.RepoIDPatterns{RepositoryIDs: repoIDs}
+	return &resolved, substitutions
+}
+
+func (g *APIGetter) workflowRepoNamesToIDs(owner string, parameters map[string]interface{}) []data.Substitution {
+	var substitutions []data.Substitution
+	workflows, ok := parameters["workflows"].([]interface{})
+	if !ok {
+		return substitutions
+	}
+	for _, workflow := range workflows {
+		workflowMap, ok := workflow.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		repoName, ok := workflowMap["repository"].(string)
+		if !ok {
+			continue
+		}
+		delete(workflowMap, "repository")
+		substitution := data.Substitution{
+			Field:  "workflows.repository_id",
+			Type:   "Repository",
+			Name:   repoName,
+			Status: "failed",
+		}
+		workflowRepo, err := g.GetRepo(owner, g.MappedRepo(repoName))
+		if err != nil {
+			zap.S().Errorf("Failed to get repository data for workflow repository %s", repoName)
+			substitutions = append(substitutions, substitution)
+			continue
+		}
+		workflowMap["repository_id"] = workflowRepo.Repository.DatabaseId
+		substitution.TargetID = workflowRepo.Repository.DatabaseId
+		substitution.Status = "substituted"
+		substitutions = append(substitutions, substitution)
+	}
+	return substitutions
+}
+
+// statusCheckSlugsToIDs replaces the app slug of each required status check
+// with the ID of that app under owner.
+func (g *APIGetter) statusCheckSlugsToIDs(owner string, parameters map[string]interface{}) []data.Substitution {
+	var substitutions []data.Substitution
+	statusChecks, ok := parameters["required_status_checks"].([]interface{})
+	if !ok {
+		return substitutions
+	}
+	for _, statusCheck := range statusChecks {
+		statusCheckMap, ok := statusCheck.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		slug, ok := statusCheckMap["integration"].(string)
+		if !ok {
+			continue
+		}
+		delete(statusCheckMap, "integration")
+		integrationID, substitution := g.ResolveIntegration(owner, slug, 0)
+		if integrationID != nil {
+			statusCheckMap["integration_id"] = *integrationID
+		}
+		substitutions = append(substitutions, substitution)
+	}
+	return substitutions
+}
+
+func builtInRoleID(roleName string) (string, bool) {
+	for roleID, name := range data.RolesMap {
+		if name == roleName {
+			return roleID, true
+		}
+	}
+	return "", false
+}
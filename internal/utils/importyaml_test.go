@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func TestYAMLExportImportRoundTrip(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/organizations/1/team/42", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(data.TeamInfo{ID: 42, Name: "Platform Team", Slug: "platform-team"}) // nolint:errcheck
+	})
+	mux.HandleFunc("/orgs/target/teams/platform-team", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(data.TeamInfo{ID: 99, Name: "Platform Team", Slug: "platform-team"}) // nolint:errcheck
+	})
+	mux.HandleFunc("/repositories/10", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"api"}`)) // nolint:errcheck
+	})
+	mux.HandleFunc("/repositories/11", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"web"}`)) // nolint:errcheck
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body) // nolint:errcheck
+		if body.Variables["name"] == "api" {
+			w.Write([]byte(`{"data":{"repository":{"databaseId":110,"name":"api"}}}`)) // nolint:errcheck
+			return
+		}
+		w.Write([]byte(`{"errors":[{"message":"Could not resolve to a Repository"}]}`)) // nolint:errcheck
+	})
+	g := newTestGetter(t, mux)
+
+	teamID := 42
+	ruleset := data.RepoRuleset{
+		ID:           7,
+		Name:         "main",
+		Target:       "branch",
+		SourceType:   "Organization",
+		Source:       "source",
+		Enforcement:  "active",
+		BypassActors: []data.BypassActor{{ActorID: &teamID, ActorType: "Team", BypassMode: "always"}},
+		Conditions: &data.Conditions{
+			RefName:      &data.RefPatterns{Include: []string{"~DEFAULT_BRANCH"}, Exclude: []string{}},
+			RepositoryID: &data.RepoIDPatterns{RepositoryIDs: []int{10, 11}},
+		},
+		Rules: []data.Rules{{Type: "deletion"}},
+	}
+
+	var out bytes.Buffer
+	if err := g.WriteRulesetsToYAML([]data.RepoRuleset{ruleset}, "source", 1, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"name: platform-team", "- api", "- web"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("export does not contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "repository_ids") {
+		t.Errorf("export lists repository IDs:\n%s", out.String())
+	}
+	if !reflect.DeepEqual(ruleset.Conditions.RepositoryID.RepositoryIDs, []int{10, 11}) {
+		t.Error("export modified the conditions of the ruleset")
+	}
+
+	path := filepath.Join(t.TempDir(), "rulesets.yaml")
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := g.ReadRulesetsFromYAML("target", path, ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 1 {
+		t.Fatalf("imported %d rulesets, want 1", len(imported))
+	}
+	got := imported[0]
+	if len(got.BypassActors) != 1 || *got.BypassActors[0].ActorID != 99 {
+		t.Errorf("bypass actors = %+v, want team 99", got.BypassActors)
+	}
+	if !reflect.DeepEqual(got.Conditions.RepositoryID, &data.RepoIDPatterns{RepositoryIDs: []int{110}}) {
+		t.Errorf("repository_id condition = %+v, want [110]", got.Conditions.RepositoryID)
+	}
+	var failed []string
+	for _, substitution := range got.Substitutions {
+		if substitution.Status == "failed" {
+			failed = append(failed, substitution.Field+" "+substitution.Name)
+		}
+	}
+	if !reflect.DeepEqual(failed, []string{"conditions.repository_id web"}) {
+		t.Errorf("failed substitutions = %v, want only repository web", failed)
+	}
+}
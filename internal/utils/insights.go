@@ -0,0 +1,331 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"go.uber.org/zap"
+)
+
+// GetRuleSuitesPage returns a page of the rule suites evaluated for pushes to
+// an organization or repository, where base is orgs/<org> or repos/<owner>/<repo>.
+func (g *APIGetter) GetRuleSuitesPage(base string, timePeriod string, result string, page int) ([]data.RuleSuite, error) {
+	url := fmt.Sprintf("%s/rulesets/rule-suites?time_period=%s&rule_suite_result=%s&per_page=100&page=%d", base, timePeriod, result, page)
+
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var suites []data.RuleSuite
+	err = json.Unmarshal(responseData, &suites)
+	return suites, err
+}
+
+// GetRuleSuites returns every rule suite evaluated for pushes to an
+// organization or repository in the time period.
+func (g *APIGetter) GetRuleSuites(base string, timePeriod string, result string) ([]data.RuleSuite, error) {
+	var allSuites []data.RuleSuite
+	for page := 1; ; page++ {
+		zap.S().Debugf("Gathering page %d of rule suites for %s", page, base)
+		suites, err := g.GetRuleSuitesPage(base, timePeriod, result, page)
+		if err != nil {
+			return nil, err
+		}
+		allSuites = append(allSuites, suites...)
+		if len(suites) < 100 {
+			break
+		}
+	}
+	return allSuites, nil
+}
+
+// GetRuleSuite returns a rule suite with the evaluation of each rule.
+func (g *APIGetter) GetRuleSuite(base string, ruleSuiteID int) (*data.RuleSuite, error) {
+	url := fmt.Sprintf("%s/rulesets/rule-suites/%d", base, ruleSuiteID)
+
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var suite data.RuleSuite
+	err = json.Unmarshal(responseData, &suite)
+	return &suite, err
+}
+
+// GatherRuleSuites returns the rule suites of an organization, or of the given
+// repositories, in the time period along with the evaluation of each rule.
+func (g *APIGetter) GatherRuleSuites(owner string, repos []string, timePeriod string, result string) ([]data.RuleSuite, error) {
+	bases := []string{fmt.Sprintf("orgs/%s", owner)}
+	if len(repos) > 0 {
+		bases = make([]string, len(repos))
+		for i, repo := range repos {
+			bases[i] = fmt.Sprintf("repos/%s/%s", owner, repo)
+		}
+	}
+
+	var suites []data.RuleSuite
+	var suiteBases []string
+	for _, base := range bases {
+		zap.S().Infof("Gathering rule suites for %s", base)
+		baseSuites, err := g.GetRuleSuites(base, timePeriod, result)
+		if err != nil {
+			return nil, err
+		}
+		for range baseSuites {
+			suiteBases = append(suiteBases, base)
+		}
+		suites = append(suites, baseSuites...)
+	}
+
+	suiteErrors := make([]error, len(suites))
+	ForEachConcurrently(len(suites), g.concurrency, func(i int) {
+		zap.S().Debugf("Gathering rule evaluations of rule suite %d", suites[i].ID)
+		suite, err := g.GetRuleSuite(suiteBases[i], suites[i].ID)
+		if err != nil {
+			suiteErrors[i] = err
+			return
+		}
+		suites[i].RuleEvaluations = suite.RuleEvaluations
+	})
+	for i := range suites {
+		if suiteErrors[i] != nil {
+			return nil, fmt.Errorf("failed to get rule suite %d: %w", suites[i].ID, suiteErrors[i])
+		}
+	}
+	return suites, nil
+}
+
+// GatherRulesetRefs returns the organization and repository rulesets of owner
+// selected by ruleType, keyed by ruleset ID.
+func (g *APIGetter) GatherRulesetRefs(owner string, repos []string, ruleType string) (map[int]data.RulesetRef, error) {
+	refs := make(map[int]data.RulesetRef)
+	if ruleType != "repoOnly" {
+		orgRulesets, err := g.FetchOrgRulesets(owner)
+		if err != nil {
+			return nil, err
+		}
+		for _, ruleset := range orgRulesets {
+			refs[ruleset.DatabaseID] = data.RulesetRef{Level: "Organization", Name: ruleset.Name}
+		}
+	}
+	if ruleType != "orgOnly" {
+		repoInfos, err := g.GatherRepositories(owner, repos)
+		if err != nil {
+			return nil, err
+		}
+		repoRulesets, err := g.FetchRepoRulesets(owner, repoInfos)
+		if err != nil {
+			return nil, err
+		}
+		for _, ruleset := range repoRulesets {
+			refs[ruleset.Rule.DatabaseID] = data.RulesetRef{Level: "Repository", RepositoryName: ruleset.RepoName, Name: ruleset.Rule.Name}
+		}
+	}
+	return refs, nil
+}
+
+// BuildRuleInsights joins the rule evaluations of rule suites with rulesets,
+// counting the evaluations, passes, failures and bypasses of each ruleset and
+// of each rule in it. Rulesets are counted once per rule suite, failing when
+// any of their rules failed. A failure in a rule suite that was bypassed is
+// counted as a bypass. Evaluations of rulesets not in refs are skipped.
+func BuildRuleInsights(suites []data.RuleSuite, refs map[int]data.RulesetRef) ([]data.RuleInsight, []data.RuleEvaluationRecord) {
+	type insightKey struct {
+		rulesetID int
+		ruleType  string
+	}
+	insights := make(map[insightKey]*data.RuleInsight)
+	var records []data.RuleEvaluationRecord
+	skipped := 0
+
+	count := func(key insightKey, ref data.RulesetRef, evaluation data.RuleEvaluation, result string, suiteResult string) {
+		insight, ok := insights[key]
+		if !ok {
+			insight = &data.RuleInsight{
+				RulesetLevel:   ref.Level,
+				RepositoryName: ref.RepositoryName,
+				RulesetID:      key.rulesetID,
+				RulesetName:    ref.Name,
+				RuleType:       key.ruleType,
+			}
+			insights[key] = insight
+		}
+		insight.Enforcement = evaluation.Enforcement
+		insight.Evaluations++
+		switch {
+		case result != "fail":
+			insight.Passes++
+		case suiteResult == "bypass":
+			insight.Bypasses++
+		default:
+			insight.Failures++
+		}
+	}
+
+	for _, suite := range suites {
+		rulesetResults := make(map[int]string)
+		rulesetEvaluations := make(map[int]data.RuleEvaluation)
+		for _, evaluation := range suite.RuleEvaluations {
+			ref, ok := refs[evaluation.RuleSource.ID]
+			if evaluation.RuleSource.Type != "ruleset" || !ok {
+				skipped++
+				continue
+			}
+			rulesetID := evaluation.RuleSource.ID
+			records = append(records, data.RuleEvaluationRecord{
+				RuleSuiteID:       suite.ID,
+				PushedAt:          suite.PushedAt,
+				RepositoryName:    suite.RepositoryName,
+				Ref:               suite.Ref,
+				ActorName:         suite.ActorName,
+				AfterSHA:          suite.AfterSHA,
+				SuiteResult:       suite.Result,
+				RulesetLevel:      ref.Level,
+				RulesetRepository: ref.RepositoryName,
+				RulesetID:         rulesetID,
+				RulesetName:       ref.Name,
+				Enforcement:       evaluation.Enforcement,
+				RuleType:          evaluation.RuleType,
+				Result:            evaluation.Result,
+				Details:           evaluation.Details,
+			})
+			count(insightKey{rulesetID, evaluation.RuleType}, ref, evaluation, evaluation.Result, suite.Result)
+			if rulesetResults[rulesetID] != "fail" {
+				rulesetResults[rulesetID] = evaluation.Result
+			}
+			rulesetEvaluations[rulesetID] = evaluation
+		}
+		for rulesetID, result := range rulesetResults {
+			count(insightKey{rulesetID, ""}, refs[rulesetID], rulesetEvaluations[rulesetID], result, suite.Result)
+		}
+	}
+	if skipped > 0 {
+		zap.S().Infof("Skipped %d rule evaluations of rulesets that were not gathered, such as enterprise, deleted or filtered out rulesets", skipped)
+	}
+
+	summary := make([]data.RuleInsight, 0, len(insights))
+	for _, insight := range insights {
+		summary = append(summary, *insight)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		a, b := summary[i], summary[j]
+		if a.RulesetLevel != b.RulesetLevel {
+			return a.RulesetLevel < b.RulesetLevel
+		}
+		if a.RepositoryName != b.RepositoryName {
+			return a.RepositoryName < b.RepositoryName
+		}
+		if a.RulesetName != b.RulesetName {
+			return a.RulesetName < b.RulesetName
+		}
+		if a.RulesetID != b.RulesetID {
+			return a.RulesetID < b.RulesetID
+		}
+		return a.RuleType < b.RuleType
+	})
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].PushedAt < records[j].PushedAt
+	})
+	return summary, records
+}
+
+func WriteRuleInsightsToCSV(summary []data.RuleInsight, fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{"RulesetLevel", "RepositoryName", "RulesetID", "RulesetName", "Enforcement", "RuleType", "Evaluations", "Passes", "Failures", "Bypasses"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("failed to write headers: %w", err)
+	}
+	for _, insight := range summary {
+		record := []string{
+			insight.RulesetLevel,
+			insight.RepositoryName,
+			strconv.Itoa(insight.RulesetID),
+			insight.RulesetName,
+			insight.Enforcement,
+			insight.RuleType,
+			strconv.Itoa(insight.Evaluations),
+			strconv.Itoa(insight.Passes),
+			strconv.Itoa(insight.Failures),
+			strconv.Itoa(insight.Bypasses),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+	return nil
+}
+
+func WriteRuleEvaluationsToCSV(records []data.RuleEvaluationRecord, fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{"RuleSuiteID", "PushedAt", "RepositoryName", "Ref", "ActorName", "AfterSHA", "SuiteResult", "RulesetLevel", "RulesetRepository", "RulesetID", "RulesetName", "Enforcement", "RuleType", "Result", "Details"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("failed to write headers: %w", err)
+	}
+	for _, evaluation := range records {
+		record := []string{
+			strconv.Itoa(evaluation.RuleSuiteID),
+			evaluation.PushedAt,
+			evaluation.RepositoryName,
+			evaluation.Ref,
+			evaluation.ActorName,
+			evaluation.AfterSHA,
+			evaluation.SuiteResult,
+			evaluation.RulesetLevel,
+			evaluation.RulesetRepository,
+			strconv.Itoa(evaluation.RulesetID),
+			evaluation.RulesetName,
+			evaluation.Enforcement,
+			evaluation.RuleType,
+			evaluation.Result,
+			evaluation.Details,
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteRuleInsightsToJSON writes the summary and evaluations of a report to a
+// single JSON file.
+func WriteRuleInsightsToJSON(report data.RuleInsightsReport, fileName string) error {
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, reportJSON, 0644)
+}
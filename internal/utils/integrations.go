@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"go.uber.org/zap"
+)
+
+// installedApps returns the slugs of the apps installed in an organization,
+// keyed by app ID. Installations are fetched once per organization.
+func (g *APIGetter) installedApps(owner string) (map[int]string, error) {
+	g.installationsMu.Lock()
+	defer g.installationsMu.Unlock()
+	if apps, ok := g.installations[owner]; ok {
+		return apps, nil
+	}
+	installations, err := g.GetAppInstallations(owner)
+	if err != nil {
+		return nil, err
+	}
+	apps := make(map[int]string, len(installations.Installations))
+	for _, installation := range installations.Installations {
+		apps[installation.AppID] = installation.AppSlug
+	}
+	if g.installations == nil {
+		g.installations = make(map[string]map[int]string)
+	}
+	g.installations[owner] = apps
+	return apps, nil
+}
+
+// IntegrationSlug returns the slug of the app with the given ID, looking it up
+// in the apps installed in owner and then in data.KnownIntegrations.
+func (g *APIGetter) IntegrationSlug(owner string, appID int) (string, error) {
+	apps, err := g.installedApps(owner)
+	if err != nil {
+		zap.S().Debugf("Failed to get app installations for %s: %v", owner, err)
+	}
+	if slug, ok := apps[appID]; ok {
+		return slug, nil
+	}
+	if slug, ok := g.knownIntegrationSlug(appID); ok {
+		return slug, nil
+	}
+	return "", fmt.Errorf("app %d is not installed in %s", appID, owner)
+}
+
+// knownIntegrationSlug returns the slug of an app in data.KnownIntegrations
+// with the given ID. As the IDs are those of GitHub.com, the app is looked up
+// by slug on the configured host, and only returned when its ID there matches.
+func (g *APIGetter) knownIntegrationSlug(appID int) (string, bool) {
+	slug, ok := data.KnownIntegrations[appID]
+	if !ok {
+		return "", false
+	}
+	app, err := g.GetAnApp(slug)
+	if err != nil {
+		zap.S().Debugf("Failed to get app %s: %v", slug, err)
+		return "", false
+	}
+	if app.AppID != appID {
+		zap.S().Debugf("App %s has ID %d rather than %d on this host", slug, app.AppID, appID)
+		return "", false
+	}
+	return slug, true
+}
+
+// isKnownIntegration reports whether an app slug is one of the apps in
+// data.KnownIntegrations, which report status checks without being installed.
+func isKnownIntegration(slug string) bool {
+	for _, knownSlug := range data.KnownIntegrations {
+		if knownSlug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveIntegration returns the ID of the app with the given slug under
+// owner, along with a substitution recording the outcome. The status is
+// "not installed" when the app exists but is not installed in owner, as its
+// checks would never be reported.
+func (g *APIGetter) ResolveIntegration(owner string, slug string, sourceID int) (*int, data.Substitution) {
+	substitution := data.Substitution{
+		Field:    "required_status_checks.integration_id",
+		Type:     "Integration",
+		Name:     slug,
+		SourceID: sourceID,
+		Status:   "failed",
+	}
+	app, err := g.GetAnApp(slug)
+	if err != nil {
+		zap.S().Warnf("App %s used by a required status check was not found: %v", slug, err)
+		return nil, substitution
+	}
+	substitution.TargetID = app.AppID
+	substitution.Status = "substituted"
+	if !isKnownIntegration(slug) {
+		apps, err := g.installedApps(owner)
+		if err != nil {
+			zap.S().Errorf("Failed to get app installations for %s: %v", owner, err)
+		} else if _, installed := apps[app.AppID]; !installed {
+			zap.S().Warnf("App %s used by a required status check is not installed in %s", slug, owner)
+			substitution.Status = "not installed"
+		}
+	}
+	return &app.AppID, substitution
+}
+
+// ResolveStatusCheckIntegrations sets the integration ID of each status check
+// that records an app slug to the ID of that app under owner. Checks without a
+// slug keep their integration ID.
+func (g *APIGetter) ResolveStatusCheckIntegrations(owner string, statusChecks []data.StatusChecks) []data.Substitution {
+	var substitutions []data.Substitution
+	for i, statusCheck := range statusChecks {
+		if len(statusCheck.IntegrationSlug) == 0 {
+			continue
+		}
+		sourceID := 0
+		if statusCheck.IntegrationID != nil {
+			sourceID = *statusCheck.IntegrationID
+		}
+		integrationID, substitution := g.ResolveIntegration(owner, statusCheck.IntegrationSlug, sourceID)
+		statusChecks[i].IntegrationID = integrationID
+		substitutions = append(substitutions, substitution)
+	}
+	return substitutions
+}
+
+// AddStatusCheckSlugs records the app slug of each status check integration
+// ID, so the app can be found by name in another organization or host.
+func (g *APIGetter) AddStatusCheckSlugs(owner string, ruleset data.RepoRuleset) data.RepoRuleset {
+	rules := make([]data.Rules, len(ruleset.Rules))
+	copy(rules, ruleset.Rules)
+	for i, rule := range rules {
+		if rule.Type != "required_status_checks" || rule.Parameters == nil || len(rule.Parameters.RequiredStatusChecks) == 0 {
+			continue
+		}
+		parameters := *rule.Parameters
+		parameters.RequiredStatusChecks = make([]data.StatusChecks, len(rule.Parameters.RequiredStatusChecks))
+		copy(parameters.RequiredStatusChecks, rule.Parameters.RequiredStatusChecks)
+		for j, statusCheck := range parameters.RequiredStatusChecks {
+			if statusCheck.IntegrationID == nil || len(statusCheck.IntegrationSlug) > 0 {
+				continue
+			}
+			slug, err := g.IntegrationSlug(owner, *statusCheck.IntegrationID)
+			if err != nil {
+				zap.S().Infof("Failed to get the app for status check %s: %v", statusCheck.Context, err)
+				continue
+			}
+			parameters.RequiredStatusChecks[j].IntegrationSlug = slug
+		}
+		rules[i].Parameters = &parameters
+	}
+	ruleset.Rules = rules
+	return ruleset
+}
+
+// UpdateStatusCheckIntegrationID maps the integration IDs of status checks
+// copied from a source organization to the IDs of the same apps under owner,
+// matching apps by slug. The integration ID of a check whose app is not found
+// in the source is cleared rather than sent as the ID of an unrelated app.
+func (g *APIGetter) UpdateStatusCheckIntegrationID(owner string, sourceOrg string, ruleset data.RepoRuleset, s *APIGetter) data.RepoRuleset {
+	ruleset = s.AddStatusCheckSlugs(sourceOrg, ruleset)
+	for _, rule := range ruleset.Rules {
+		if rule.Type == "required_status_checks" && rule.Parameters != nil {
+			for i, statusCheck := range rule.Parameters.RequiredStatusChecks {
+				if statusCheck.IntegrationID != nil && len(statusCheck.IntegrationSlug) == 0 {
+					ruleset.Substitutions = append(ruleset.Substitutions, data.Substitution{
+						Field:    "required_status_checks.integration_id",
+						Type:     "Integration",
+						SourceID: *statusCheck.IntegrationID,
+						Status:   "failed",
+					})
+					rule.Parameters.RequiredStatusChecks[i].IntegrationID = nil
+				}
+			}
+			ruleset.Substitutions = append(ruleset.Substitutions, g.ResolveStatusCheckIntegrations(owner, rule.Parameters.RequiredStatusChecks)...)
+		}
+	}
+	return ruleset
+}
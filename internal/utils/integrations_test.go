@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func TestUnresolvedStatusCheckAppKeptOutOfPlan(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/source/installations", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(data.AppIntegrations{ // nolint:errcheck
+			Installations: []data.AppInstallation{{AppID: 7001, AppSlug: "missing-app"}},
+		})
+	})
+	g := newTestGetter(t, mux)
+
+	tests := []struct {
+		name    string
+		checkID int
+	}{
+		{name: "app not found in source", checkID: 7002},
+		{name: "app not found in target", checkID: 7001},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkID := tt.checkID
+			ruleset := data.RepoRuleset{
+				Name:       "main",
+				SourceType: "Organization",
+				Rules: []data.Rules{{Type: "required_status_checks", Parameters: &data.Parameters{
+					RequiredStatusChecks: []data.StatusChecks{{Context: "build", IntegrationID: &checkID}},
+				}}},
+			}
+
+			updated := g.UpdateStatusCheckIntegrationID("target", "source", ruleset, g)
+			if integrationID := updated.Rules[0].Parameters.RequiredStatusChecks[0].IntegrationID; integrationID != nil {
+				t.Errorf("integration ID = %d, want none", *integrationID)
+			}
+			createRuleset, err := ProcessRulesets(updated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry, err := NewPlanEntry(updated, createRuleset, "source", "target")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entry.Error) == 0 || len(entry.Requests) != 0 {
+				t.Errorf("entry = %+v, want an error without requests", entry)
+			}
+			if *ruleset.Rules[0].Parameters.RequiredStatusChecks[0].IntegrationID != tt.checkID {
+				t.Error("UpdateStatusCheckIntegrationID modified the source ruleset")
+			}
+		})
+	}
+}
+
+func TestKnownIntegrationsOnOtherHosts(t *testing.T) {
+	tests := []struct {
+		name     string
+		actionID int
+		wantSlug string
+	}{
+		{name: "github.com", actionID: 15368, wantSlug: "github-actions"},
+		{name: "enterprise server", actionID: 12},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/orgs/org/installations", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(data.AppIntegrations{}) // nolint:errcheck
+			})
+			mux.HandleFunc("/apps/github-actions", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(data.AppInfo{AppID: tt.actionID, AppSlug: "github-actions"}) // nolint:errcheck
+			})
+			g := newTestGetter(t, mux)
+
+			slug, err := g.IntegrationSlug("org", 15368)
+			if slug != tt.wantSlug || (err == nil) != (len(tt.wantSlug) > 0) {
+				t.Errorf("IntegrationSlug = %q, %v, want %q", slug, err, tt.wantSlug)
+			}
+
+			integrationID, substitution := g.ResolveIntegration("org", "github-actions", 15368)
+			if integrationID == nil || *integrationID != tt.actionID || substitution.Status != "substituted" {
+				t.Errorf("ResolveIntegration = %v, %+v, want app %d substituted", integrationID, substitution, tt.actionID)
+			}
+		})
+	}
+}
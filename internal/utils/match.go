@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/evaluate"
+)
+
+// QualifyRef returns a ref name qualified for the target of a ruleset, so that
+// main is evaluated as refs/heads/main for branch rulesets and refs/tags/main
+// for tag rulesets.
+func QualifyRef(ref string, target string) string {
+	if strings.HasPrefix(ref, "refs/") {
+		return ref
+	}
+	if target == "tag" {
+		return "refs/tags/" + ref
+	}
+	return "refs/heads/" + ref
+}
+
+// MatchRulesetConditions evaluates the conditions of a ruleset against a list
+// of ref names and repositories, without making any API calls.
+func MatchRulesetConditions(ruleset data.RepoRuleset, refs []string, repos []evaluate.Repository, defaultBranch string) []data.MatchResult {
+	var results []data.MatchResult
+	ruleset.Conditions = CleanConditions(ruleset.Conditions)
+	var refPatterns *data.RefPatterns
+	if ruleset.Conditions != nil {
+		refPatterns = ruleset.Conditions.RefName
+	}
+	for _, ref := range refs {
+		qualifiedRef := QualifyRef(ref, ruleset.Target)
+		result := evaluate.EvaluateRef(refPatterns, qualifiedRef, defaultBranch)
+		results = append(results, data.MatchResult{
+			RulesetLevel: ruleset.SourceType,
+			RulesetName:  ruleset.Name,
+			Kind:         "ref",
+			Value:        qualifiedRef,
+			Matched:      result.Matched,
+			Reason:       result.Reason,
+		})
+	}
+	for _, repo := range repos {
+		result := evaluate.EvaluateRulesetRepository(ruleset, repo)
+		results = append(results, data.MatchResult{
+			RulesetLevel: ruleset.SourceType,
+			RulesetName:  ruleset.Name,
+			Kind:         "repository",
+			Value:        repo.Name,
+			Matched:      result.Matched,
+			Unknown:      result.Unknown,
+			Reason:       result.Reason,
+		})
+	}
+	return results
+}
+
+// WriteMatchResults writes the results of evaluating ruleset conditions,
+// grouped by ruleset, to w.
+func WriteMatchResults(results []data.MatchResult, w io.Writer) error {
+	var current string
+	for _, result := range results {
+		key := result.RulesetLevel + "/" + result.RulesetName
+		if key != current {
+			if _, err := fmt.Fprintf(w, "\n[%s] %q\n", result.RulesetLevel, result.RulesetName); err != nil {
+				return err
+			}
+			current = key
+		}
+		status := "no match"
+		if result.Matched {
+			status = "match"
+		} else if result.Unknown {
+			status = "unknown"
+		}
+		if _, err := fmt.Fprintf(w, "  %s %s: %s (%s)\n", result.Kind, result.Value, status, result.Reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
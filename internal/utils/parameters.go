@@ -35,7 +35,7 @@ func (g *APIGetter) ParametersToMap(params data.Parameters, ruleType string) map
 			for j := 0; j < field.Len(); j++ {
 				workflow := field.Index(j).Interface().(data.Workflows)
 				repoName, _ := g.GetRepoByID(workflow.RepositoryID)
-				workflowString := fmt.Sprintf("{Path=%s|Ref=%s|RepositoryID=%d|RepositoryName=%s|SHA=%s}", workflow.Path, workflow.Ref, workflow.RepositoryID, repoName.Name, workflow.SHA)
+				workflowString := fmt.Sprintf("{Path=%s|Ref=%s|RepositoryID=%d|RepositoryName=%s|SHA=%s}", EscapeCSVValue(workflow.Path), EscapeCSVValue(workflow.Ref), workflow.RepositoryID, EscapeCSVValue(repoName.Name), EscapeCSVValue(workflow.SHA))
 				workflowStrings = append(workflowStrings, workflowString)
 			}
 			result[fieldName] = strings.Join(workflowStrings, ";")
@@ -43,7 +43,7 @@ func (g *APIGetter) ParametersToMap(params data.Parameters, ruleType string) map
 			var codeScanningStrings []string
 			for j := 0; j < field.Len(); j++ {
 				codeScanning := field.Index(j).Interface().(data.CodeScanning)
-				codeScanningString := fmt.Sprintf("{Tool=%s|SecurityAlertsThreshold=%s|AlertsThreshold=%s}", codeScanning.Tool, codeScanning.SecurityAlertsThreshold, codeScanning.AlertsThreshold)
+				codeScanningString := fmt.Sprintf("{Tool=%s|SecurityAlertsThreshold=%s|AlertsThreshold=%s}", EscapeCSVValue(codeScanning.Tool), EscapeCSVValue(codeScanning.SecurityAlertsThreshold), EscapeCSVValue(codeScanning.AlertsThreshold))
 				codeScanningStrings = append(codeScanningStrings, codeScanningString)
 			}
 			result[fieldName] = strings.Join(codeScanningStrings, ";")
@@ -51,12 +51,28 @@ func (g *APIGetter) ParametersToMap(params data.Parameters, ruleType string) map
 			var statusCheckStrings []string
 			for j := 0; j < field.Len(); j++ {
 				statusCheck := field.Index(j).Interface().(data.StatusChecks)
-				statusCheckString := fmt.Sprintf("{Context=%s|IntegrationID=%d}", statusCheck.Context, statusCheck.IntegrationID)
+				integrationID := 0
+				if statusCheck.IntegrationID != nil {
+					integrationID = *statusCheck.IntegrationID
+				}
+				statusCheckString := fmt.Sprintf("Context=%s|IntegrationID=%d", EscapeCSVValue(statusCheck.Context), integrationID)
+				if len(statusCheck.IntegrationSlug) > 0 {
+					statusCheckString += fmt.Sprintf("|IntegrationSlug=%s", EscapeCSVValue(statusCheck.IntegrationSlug))
+				}
+				statusCheckString = fmt.Sprintf("{%s}", statusCheckString)
 				statusCheckStrings = append(statusCheckStrings, statusCheckString)
 			}
 			result[fieldName] = strings.Join(statusCheckStrings, ";")
 		case reflect.TypeOf(true): // Check for boolean type
 			result[fieldName] = fmt.Sprintf("%v", field.Bool())
+		case reflect.TypeOf([]string{}):
+			items := make([]string, field.Len())
+			for j := 0; j < field.Len(); j++ {
+				items[j] = escapeCSVListItem(field.Index(j).String())
+			}
+			result[fieldName] = fmt.Sprintf("[%s]", strings.Join(items, " "))
+		case reflect.TypeOf(""):
+			result[fieldName] = EscapeCSVValue(field.String())
 		default:
 			result[fieldName] = fmt.Sprintf("%v", field.Interface())
 		}
@@ -72,7 +88,7 @@ func GetValidFields(ruleType string) map[string]map[string]struct{} {
 			"GroupingStrategy":             {},
 			"MaxEntriesToBuild":            {},
 			"MaxEntriesToMerge":            {},
-			"MergeType":                    {},
+			"MergeMethod":                  {},
 			"MinEntriesToMerge":            {},
 			"MinEntriesToMergeWaitMinutes": {},
 		},
@@ -89,8 +105,9 @@ func GetValidFields(ruleType string) map[string]map[string]struct{} {
 		"required_status_checks": {
 			"DoNotEnforceOnCreate": {},
 			"RequiredStatusChecks": {
-				"Context":       {},
-				"IntegrationID": {},
+				"Context":         {},
+				"IntegrationID":   {},
+				"IntegrationSlug": {},
 			},
 			"StrictRequiredStatusChecksPolicy": {},
 		},
@@ -136,6 +153,9 @@ func GetValidFields(ruleType string) map[string]map[string]struct{} {
 		"max_file_size": {
 			"MaxFileSize": {},
 		},
+		"update": {
+			"UpdateAllowsFetchAndMerge": {},
+		},
 		"workflows": {
 			"DoNotEnforceOnCreate": {},
 			"Workflows": {
@@ -152,15 +172,24 @@ func GetValidFields(ruleType string) map[string]map[string]struct{} {
 				"AlertsThreshold":         {},
 			},
 		},
+		"repository_name": {
+			"Negate":  {},
+			"Pattern": {},
+		},
+		"repository_visibility": {
+			"Internal": {},
+			"Private":  {},
+		},
 	}
 	return validFields[ruleType]
 }
 
-func (g *APIGetter) MapToParameters(owner string, paramsMap map[string]interface{}, ruleType string) *data.Parameters {
+func (g *APIGetter) MapToParameters(owner string, paramsMap map[string]interface{}, ruleType string) (*data.Parameters, []data.Substitution) {
 	var params data.Parameters
+	var substitutions []data.Substitution
 	validFields := GetValidFields(ruleType)
 	if validFields == nil {
-		return nil
+		return nil, nil
 	}
 
 	workflowsType := reflect.TypeOf([]data.Workflows{})
@@ -183,7 +212,8 @@ func (g *APIGetter) MapToParameters(owner string, paramsMap map[string]interface
 		switch field.Kind() {
 		case reflect.Slice:
 			if field.Type() == workflowsType {
-				parsedValue := g.ParseRequiredWorkflowsForImport(owner, value)
+				parsedValue, workflowSubstitutions := g.ParseRequiredWorkflowsForImport(owner, value)
+				substitutions = append(substitutions, workflowSubstitutions...)
 				if len(parsedValue) > 0 {
 					field.Set(reflect.ValueOf(parsedValue))
 				}
@@ -194,6 +224,7 @@ func (g *APIGetter) MapToParameters(owner string, paramsMap map[string]interface
 				}
 			} else if field.Type() == statusChecksType {
 				parsedValue := parseStatusChecks(value)
+				substitutions = append(substitutions, g.ResolveStatusCheckIntegrations(owner, parsedValue)...)
 				if len(parsedValue) > 0 {
 					field.Set(reflect.ValueOf(parsedValue))
 				}
@@ -215,7 +246,7 @@ func (g *APIGetter) MapToParameters(owner string, paramsMap map[string]interface
 		}
 
 	}
-	return &params
+	return &params, substitutions
 }
 
 func parseStatusChecks(value interface{}) []data.StatusChecks {
@@ -237,8 +268,9 @@ func parseStatusChecks(value interface{}) []data.StatusChecks {
 			integrationIDPtr = &integrationID
 		}
 		statusCheck := data.StatusChecks{
-			Context:       statusMap["Context"],
-			IntegrationID: integrationIDPtr,
+			Context:         statusMap["Context"],
+			IntegrationID:   integrationIDPtr,
+			IntegrationSlug: statusMap["IntegrationSlug"],
 		}
 		statusChecks = append(statusChecks, statusCheck)
 	}
@@ -269,37 +301,37 @@ func ParseParameters(paramStr string) map[string]interface{} {
 	if paramStr == "" {
 		return nil
 	} else {
-		paramPairs := SplitIgnoringBraces(paramStr, "|")
-		for _, pair := range paramPairs {
-			kv := strings.Split(pair, ":")
-			if len(kv) != 2 {
+		for _, pair := range SplitEscaped(paramStr, '|') {
+			key, value, found := CutEscaped(pair, ':')
+			if !found {
 				continue
 			}
-			key := kv[0]
-			value := kv[1]
-
-			if strings.Contains(value, "{") {
-				subGroups := strings.Split(value, ";")
+			key = UnescapeCSVValue(key)
 
+			if _, isGroup := trimEscapedDelimiters(value, '{', '}'); isGroup {
 				var subMap []map[string]string
-				for _, subGroup := range subGroups {
-					valueTrimmed := strings.Trim(subGroup, "{}")
-					pairGroups := strings.Split(valueTrimmed, "|")
+				for _, subGroup := range SplitEscaped(value, ';') {
+					valueTrimmed, _ := trimEscapedDelimiters(subGroup, '{', '}')
 					subGroupMap := make(map[string]string)
-					for _, pairGroup := range pairGroups {
-						subKv := strings.Split(pairGroup, "=")
-						if len(subKv) == 2 {
-							subGroupMap[subKv[0]] = subKv[1]
+					for _, pairGroup := range SplitEscaped(valueTrimmed, '|') {
+						subKey, subValue, found := CutEscaped(pairGroup, '=')
+						if found {
+							subGroupMap[UnescapeCSVValue(subKey)] = UnescapeCSVValue(subValue)
 						}
 					}
 					subMap = append(subMap, subGroupMap)
 				}
 				params[key] = subMap
-			} else if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
-				value = strings.Trim(value, "[]")
-				params[key] = strings.Split(value, " ")
+			} else if items, isList := trimEscapedDelimiters(value, '[', ']'); isList {
+				var list []string
+				for _, item := range SplitEscaped(items, ' ') {
+					if len(item) > 0 {
+						list = append(list, UnescapeCSVValue(item))
+					}
+				}
+				params[key] = list
 			} else {
-				params[key] = value
+				params[key] = UnescapeCSVValue(value)
 			}
 		}
 		return params
@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"go.uber.org/zap"
+)
+
+// NewPlanEntry builds the API request needed to create a ruleset under target,
+// along with any ID substitutions made while preparing it. Rulesets with a
+// bypass actor, a repository_id or organization_id condition entry, a status
+// check app or a required workflow repository that could not be resolved are
+// kept out of the plan with an error, rather than created with a different
+// bypass list, set of repositories or organizations, checks or workflows.
+func NewPlanEntry(ruleset data.RepoRuleset, createRuleset data.CreateRuleset, source string, target string) (data.PlanEntry, error) {
+	entry := data.PlanEntry{
+		RulesetLevel:  ruleset.SourceType,
+		Source:        source,
+		Target:        target,
+		RulesetName:   createRuleset.Name,
+		SourceID:      ruleset.ID,
+		Action:        "create",
+		Substitutions: ruleset.Substitutions,
+	}
+	if unresolved := unresolvedSubstitutions(ruleset.Substitutions, "bypass_actors"); len(unresolved) > 0 {
+		entry.Error = fmt.Sprintf("Bypass actors could not be resolved: %s", strings.Join(unresolved, ", "))
+		return entry, nil
+	}
+	if unresolved := unresolvedSubstitutions(ruleset.Substitutions, "conditions.repository_id"); len(unresolved) > 0 {
+		entry.Error = fmt.Sprintf("Repositories of the repository_id condition could not be resolved: %s", strings.Join(unresolved, ", "))
+		return entry, nil
+	}
+	if unresolved := unresolvedSubstitutions(ruleset.Substitutions, "conditions.organization_id"); len(unresolved) > 0 {
+		entry.Error = fmt.Sprintf("Organizations of the organization_id condition could not be resolved: %s", strings.Join(unresolved, ", "))
+		return entry, nil
+	}
+	if unresolved := unresolvedSubstitutions(ruleset.Substitutions, "required_status_checks.integration_id"); len(unresolved) > 0 {
+		entry.Error = fmt.Sprintf("Apps of required status checks could not be resolved: %s", strings.Join(unresolved, ", "))
+		return entry, nil
+	}
+	if unresolved := unresolvedSubstitutions(ruleset.Substitutions, "workflows.repository_id"); len(unresolved) > 0 {
+		entry.Error = fmt.Sprintf("Repositories of required workflows could not be resolved: %s", strings.Join(unresolved, ", "))
+		return entry, nil
+	}
+	createRulesetJSON, err := json.Marshal(createRuleset)
+	if err != nil {
+		return entry, err
+	}
+	entry.Requests = []data.PlanRequest{{
+		Method:   "POST",
+		Endpoint: RulesetsEndpoint(ruleset.SourceType, target),
+		Payload:  createRulesetJSON,
+	}}
+	return entry, nil
+}
+
+// unresolvedSubstitutions returns the actors or repositories of a field whose
+// substitution failed.
+func unresolvedSubstitutions(substitutions []data.Substitution, field string) []string {
+	var unresolved []string
+	for _, substitution := range substitutions {
+		if substitution.Field != field || substitution.Status != "failed" {
+			continue
+		}
+		name := substitution.Name
+		if len(name) == 0 {
+			name = strconv.Itoa(substitution.SourceID)
+		}
+		unresolved = append(unresolved, fmt.Sprintf("%s %s", substitution.Type, name))
+	}
+	return unresolved
+}
+
+// RulesetsEndpoint returns the REST endpoint for rulesets of the given level.
+func RulesetsEndpoint(level string, target string) string {
+	if level == "Repository" {
+		return fmt.Sprintf("repos/%s/rulesets", target)
+	} else if level == "Enterprise" {
+		return fmt.Sprintf("enterprises/%s/rulesets", target)
+	}
+	return fmt.Sprintf("orgs/%s/rulesets", target)
+}
+
+// plannedRulesetID marks a ruleset name that an earlier entry of the plan
+// creates, whose ID is not known until the plan is applied.
+const plannedRulesetID = 0
+
+// ApplyConflictAction rewrites the requests of a plan entry whose ruleset name
+// already exists at the target, based on the on-conflict mode: skip leaves the
+// ruleset untouched, update issues a PUT, replace issues a DELETE of the
+// existing ruleset followed by the POST creating it again, and fail records an
+// error.
+func ApplyConflictAction(entry data.PlanEntry, existingID int, onConflict string) data.PlanEntry {
+	if len(entry.Requests) == 0 {
+		return entry
+	}
+	createRequest := entry.Requests[0]
+	rulesetEndpoint := fmt.Sprintf("%s/%d", createRequest.Endpoint, existingID)
+	entry.Action = onConflict
+	entry.ExistingID = existingID
+
+	switch onConflict {
+	case "skip":
+		entry.Requests = nil
+	case "update":
+		entry.Requests = []data.PlanRequest{{
+			Method:   "PUT",
+			Endpoint: rulesetEndpoint,
+			Payload:  createRequest.Payload,
+		}}
+	case "replace":
+		entry.Requests = []data.PlanRequest{{
+			Method:   "DELETE",
+			Endpoint: rulesetEndpoint,
+		}, createRequest}
+	default:
+		entry.Action = "fail"
+		entry.Requests = nil
+		entry.Error = "Ruleset already exists"
+	}
+	return entry
+}
+
+// CheckExistingRuleset looks up rulesets already defined at the entry's target,
+// caching them per level and target, and applies the on-conflict mode when the ruleset
+// name is already in use. Rulesets planned for creation are added to the cache,
+// so a later entry with the same name and target is handled as a conflict.
+// When the existing rulesets of a target cannot be looked up, a nil map is
+// cached and every entry for that target fails rather than risk a duplicate.
+func (g *APIGetter) CheckExistingRuleset(entry data.PlanEntry, existingRulesets map[string]map[string]int, onConflict string) data.PlanEntry {
+	key := targetKey(entry)
+	existing, ok := existingRulesets[key]
+	if !ok {
+		var err error
+		existing, err = g.ExistingRulesetIDs(entry.RulesetLevel, entry.Target)
+		if err != nil {
+			zap.S().Errorf("Error gathering existing rulesets for %s: %v", entry.Target, err)
+			existing = nil
+		}
+		existingRulesets[key] = existing
+	}
+	if existing == nil {
+		entry.Action = "fail"
+		entry.Requests = nil
+		if len(entry.Error) == 0 {
+			entry.Error = fmt.Sprintf("Existing rulesets of %s could not be looked up", entry.Target)
+		}
+		return entry
+	}
+	if existingID, exists := existing[entry.RulesetName]; exists {
+		zap.S().Debugf("Ruleset %s already exists in %s, applying on-conflict %s", entry.RulesetName, entry.Target, onConflict)
+		if existingID == plannedRulesetID && (onConflict == "update" || onConflict == "replace") {
+			entry.Action = onConflict
+			entry.Requests = nil
+			entry.Error = "Ruleset is created by an earlier entry of the plan"
+			return entry
+		}
+		return ApplyConflictAction(entry, existingID, onConflict)
+	}
+	if entry.Action == "create" && len(entry.Error) == 0 {
+		existing[entry.RulesetName] = plannedRulesetID
+	}
+	return entry
+}
+
+// targetKey returns the key under which lookups for the target of an entry are
+// cached. It includes the level, as an enterprise and an organization may share
+// the same name.
+func targetKey(entry data.PlanEntry) string {
+	return entry.RulesetLevel + "|" + entry.Target
+}
+
+// ErrorMessage returns the validation detail of an API error when present.
+func ErrorMessage(err error) string {
+	if strings.Contains(err.Error(), "\n") {
+		return strings.Split(err.Error(), "\n")[1]
+	}
+	return err.Error()
+}
+
+// ApplyPlan sends the requests of each plan entry, recording the outcome of
+// each entry in state when one is provided. When a replaced ruleset was deleted
+// but could not be created again, the error says so, as the target is then
+// left without the ruleset.
+func (g *APIGetter) ApplyPlan(plan data.RulesetPlan, state *StateFile) []data.ErrorRulesets {
+	var errorRulesets []data.ErrorRulesets
+
+	for _, entry := range plan.Entries {
+		if len(entry.Error) > 0 {
+			errorRulesets = append(errorRulesets, data.ErrorRulesets{Source: entry.Source, RulesetName: entry.RulesetName, Action: entry.Action, Error: entry.Error})
+			zap.S().Infof("Error creating ruleset %s for %s: %s", entry.RulesetName, entry.Target, entry.Error)
+			recordState(state, entry, "failed", 0, entry.Error)
+			continue
+		}
+		if entry.Action == "skip" {
+			errorRulesets = append(errorRulesets, data.ErrorRulesets{Source: entry.Source, RulesetName: entry.RulesetName, Action: entry.Action, Error: "Ruleset already exists"})
+			zap.S().Infof("Skipping ruleset %s for %s as it already exists", entry.RulesetName, entry.Target)
+			recordState(state, entry, "skipped", entry.ExistingID, "")
+			continue
+		}
+		var requestError error
+		var response []byte
+		deleted := false
+		for _, request := range entry.Requests {
+			zap.S().Debugf("Sending %s request to %s for ruleset %s", request.Method, request.Endpoint, entry.RulesetName)
+			var body io.Reader
+			if len(request.Payload) > 0 {
+				body = bytes.NewReader(request.Payload)
+			}
+			response, requestError = g.SendRulesetRequest(request.Method, request.Endpoint, body)
+			if requestError != nil {
+				break
+			}
+			deleted = deleted || request.Method == "DELETE"
+		}
+		if requestError != nil {
+			errorValidation := ErrorMessage(requestError)
+			if deleted {
+				errorValidation = fmt.Sprintf("Existing ruleset %d was deleted but the ruleset could not be created again: %s", entry.ExistingID, errorValidation)
+			}
+			errorRulesets = append(errorRulesets, data.ErrorRulesets{Source: entry.Source, RulesetName: entry.RulesetName, Action: entry.Action, Error: errorValidation})
+			zap.S().Infof("Error applying %s of ruleset %s for %s: %s", entry.Action, entry.RulesetName, entry.Target, errorValidation)
+			recordState(state, entry, "failed", 0, errorValidation)
+			continue
+		}
+		zap.S().Infof("Successfully applied %s of %s ruleset %s for %s", entry.Action, strings.ToLower(entry.RulesetLevel), entry.RulesetName, entry.Target)
+		recordState(state, entry, "completed", RulesetIDFromResponse(response), "")
+	}
+	return errorRulesets
+}
+
+func recordState(state *StateFile, entry data.PlanEntry, status string, targetID int, errorMessage string) {
+	if state == nil {
+		return
+	}
+	if err := state.Record(entry, status, targetID, errorMessage); err != nil {
+		zap.S().Errorf("Error updating state file %s: %v", state.Path(), err)
+	}
+}
+
+func WritePlanToFile(plan data.RulesetPlan, fileName string) error {
+	planJSON, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	if err := os.WriteFile(fileName, planJSON, 0644); err != nil {
+		return fmt.Errorf("failed to write plan file: %w", err)
+	}
+	return nil
+}
+
+func ReadPlanFromFile(fileName string) (*data.RulesetPlan, error) {
+	planJSON, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+	var plan data.RulesetPlan
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file: %w", err)
+	}
+	return &plan, nil
+}
+
+// WritePlanSummary writes a human-readable version of the plan, including each
+// request payload and ID substitution.
+func WritePlanSummary(plan data.RulesetPlan, w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "Plan for %s: %d rulesets\n", plan.Owner, len(plan.Entries)); err != nil {
+		return err
+	}
+	for _, entry := range plan.Entries {
+		if _, err := fmt.Fprintf(w, "\n[%s] %q from %s to %s (%s)\n", entry.RulesetLevel, entry.RulesetName, entry.Source, entry.Target, entry.Action); err != nil {
+			return err
+		}
+		for _, substitution := range entry.Substitutions {
+			if _, err := fmt.Fprintf(w, "  substitution: %s %s %q %d -> %d (%s)\n", substitution.Field, substitution.Type, substitution.Name, substitution.SourceID, substitution.TargetID, substitution.Status); err != nil {
+				return err
+			}
+		}
+		if len(entry.Error) > 0 {
+			if _, err := fmt.Fprintf(w, "  error: %s\n", entry.Error); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, request := range entry.Requests {
+			if _, err := fmt.Fprintf(w, "  %s %s\n", request.Method, request.Endpoint); err != nil {
+				return err
+			}
+			if len(request.Payload) == 0 {
+				continue
+			}
+			var payload bytes.Buffer
+			if err := json.Indent(&payload, request.Payload, "    ", "  "); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "    %s\n", payload.String()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
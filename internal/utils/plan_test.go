@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func newTestPlanEntry(t *testing.T, name string) data.PlanEntry {
+	t.Helper()
+	ruleset := data.RepoRuleset{Name: name, SourceType: "Organization"}
+	createRuleset := data.CreateRuleset{Name: name, Target: "branch", Enforcement: "active", Rules: []data.CreateRules{}}
+	entry, err := NewPlanEntry(ruleset, createRuleset, "source", "target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestApplyConflictActionReplace(t *testing.T) {
+	create := newTestPlanEntry(t, "main")
+	entry := ApplyConflictAction(create, 7, "replace")
+	if len(entry.Requests) != 2 {
+		t.Fatalf("requests = %+v, want a DELETE and a POST", entry.Requests)
+	}
+	if request := entry.Requests[0]; request.Method != "DELETE" || request.Endpoint != "orgs/target/rulesets/7" || len(request.Payload) > 0 {
+		t.Errorf("first request = %+v, want DELETE orgs/target/rulesets/7", request)
+	}
+	if request := entry.Requests[1]; request.Method != "POST" || request.Endpoint != "orgs/target/rulesets" || string(request.Payload) != string(create.Requests[0].Payload) {
+		t.Errorf("second request = %+v, want the create request", request)
+	}
+}
+
+func TestApplyPlanReplaceRecreateFails(t *testing.T) {
+	var methods []string
+	g := newTestGetter(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Method == "DELETE" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"message":"Validation Failed"}`)) // nolint:errcheck
+	}))
+
+	entry := ApplyConflictAction(newTestPlanEntry(t, "main"), 7, "replace")
+	errorRulesets := g.ApplyPlan(data.RulesetPlan{Entries: []data.PlanEntry{entry}}, nil)
+	if strings.Join(methods, ",") != "DELETE,POST" {
+		t.Fatalf("methods = %v, want DELETE then POST", methods)
+	}
+	if len(errorRulesets) != 1 || errorRulesets[0].Action != "replace" {
+		t.Fatalf("errorRulesets = %+v, want one failed replace", errorRulesets)
+	}
+	if !strings.Contains(errorRulesets[0].Error, "Existing ruleset 7 was deleted") {
+		t.Errorf("error = %q, want it to say the ruleset was deleted", errorRulesets[0].Error)
+	}
+}
+
+func TestCheckExistingRulesetDuplicateEntries(t *testing.T) {
+	g := newTestGetter(t, http.NotFoundHandler())
+	tests := []struct {
+		onConflict string
+		action     string
+		requests   int
+		failed     bool
+	}{
+		{onConflict: "fail", action: "fail", failed: true},
+		{onConflict: "skip", action: "skip"},
+		{onConflict: "update", action: "update", failed: true},
+		{onConflict: "replace", action: "replace", failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.onConflict, func(t *testing.T) {
+			existingRulesets := map[string]map[string]int{"Organization|target": {"existing": 3}}
+
+			first := g.CheckExistingRuleset(newTestPlanEntry(t, "main"), existingRulesets, tt.onConflict)
+			if first.Action != "create" || len(first.Requests) != 1 {
+				t.Fatalf("first entry = %+v, want create", first)
+			}
+			second := g.CheckExistingRuleset(newTestPlanEntry(t, "main"), existingRulesets, tt.onConflict)
+			if second.Action != tt.action || len(second.Requests) != tt.requests || (len(second.Error) > 0) != tt.failed {
+				t.Errorf("second entry = %+v, want action %s", second, tt.action)
+			}
+
+			existing := g.CheckExistingRuleset(newTestPlanEntry(t, "existing"), existingRulesets, tt.onConflict)
+			if existing.Action != tt.action || existing.ExistingID != 3 {
+				t.Errorf("existing entry = %+v, want action %s on ruleset 3", existing, tt.action)
+			}
+		})
+	}
+}
+
+func TestCheckExistingRulesetLookupFails(t *testing.T) {
+	var calls int
+	g := newTestGetter(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	existingRulesets := map[string]map[string]int{}
+
+	for _, name := range []string{"main", "release"} {
+		entry := g.CheckExistingRuleset(newTestPlanEntry(t, name), existingRulesets, "skip")
+		if entry.Action != "fail" || len(entry.Requests) != 0 || len(entry.Error) == 0 {
+			t.Errorf("entry %s = %+v, want a failed entry without requests", name, entry)
+		}
+	}
+	if calls == 0 {
+		t.Fatal("existing rulesets were not looked up")
+	}
+	callsAfterLookup := calls
+	g.CheckExistingRuleset(newTestPlanEntry(t, "other"), existingRulesets, "skip")
+	if calls != callsAfterLookup {
+		t.Errorf("calls = %d, want the failed lookup to be cached", calls)
+	}
+}
+
+func TestCheckExistingRulesetCachedPerLevel(t *testing.T) {
+	g := newTestGetter(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"organization":{"rulesets":{"nodes":[],"pageInfo":{"hasNextPage":false}}}}}`)) // nolint:errcheck
+	}))
+	existingRulesets := map[string]map[string]int{"Enterprise|target": {"main": 3}}
+
+	entry := g.CheckExistingRuleset(newTestPlanEntry(t, "main"), existingRulesets, "fail")
+	if entry.Action != "create" || len(entry.Error) > 0 {
+		t.Errorf("entry = %+v, want create, as the enterprise ruleset is not in the organization", entry)
+	}
+	if _, ok := existingRulesets["Organization|target"]["main"]; !ok {
+		t.Errorf("existingRulesets = %v, want the planned ruleset cached for the organization", existingRulesets)
+	}
+}
@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"go.uber.org/zap"
+)
+
+func (g *APIGetter) GetOrgPropertySchema(owner string) ([]data.CustomProperty, error) {
+	url := fmt.Sprintf("orgs/%s/properties/schema", owner)
+
+	resp, err := g.restClient.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var properties []data.CustomProperty
+	err = json.Unmarshal(responseData, &properties)
+	return properties, err
+}
+
+// CreateOrgProperty creates a custom property in an organization, or updates
+// the property when one with the same name already exists.
+func (g *APIGetter) CreateOrgProperty(owner string, property data.CustomProperty) error {
+	url := fmt.Sprintf("orgs/%s/properties/schema/%s", owner, property.PropertyName)
+	property.PropertyName = ""
+	propertyJSON, err := json.Marshal(property)
+	if err != nil {
+		return err
+	}
+	_, err = g.SendRulesetRequest("PUT", url, bytes.NewReader(propertyJSON))
+	return err
+}
+
+// UpdateRepoPropertyValues sets custom property values for repositories in an
+// organization.
+func (g *APIGetter) UpdateRepoPropertyValues(owner string, update data.RepoPropertyValuesUpdate) error {
+	url := fmt.Sprintf("orgs/%s/properties/values", owner)
+	updateJSON, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	_, err = g.SendRulesetRequest("PATCH", url, bytes.NewReader(updateJSON))
+	return err
+}
+
+// ComparePropertyDefinitions compares a source custom property with the
+// property of the same name in the target. A property is incompatible when
+// source values could not be set in the target, because the value type differs
+// or allowed values are missing. Other differences are returned so they can be
+// reported, but do not stop values from being copied.
+func ComparePropertyDefinitions(source data.CustomProperty, target data.CustomProperty) (bool, []string) {
+	if source.ValueType != target.ValueType {
+		return false, []string{fmt.Sprintf("value type is %s in the target but %s in the source", target.ValueType, source.ValueType)}
+	}
+	var differences []string
+	var missing []string
+	for _, value := range source.AllowedValues {
+		if !containsFold(target.AllowedValues, value) {
+			missing = append(missing, value)
+		}
+	}
+	if len(missing) > 0 {
+		return false, []string{fmt.Sprintf("allowed values %s are missing in the target", strings.Join(missing, ", "))}
+	}
+	if len(target.AllowedValues) > len(source.AllowedValues) {
+		differences = append(differences, "the target allows more values than the source")
+	}
+	if source.Required != target.Required {
+		differences = append(differences, fmt.Sprintf("required is %t in the target but %t in the source", target.Required, source.Required))
+	}
+	if defaultValue(source) != defaultValue(target) {
+		differences = append(differences, fmt.Sprintf("default value is %s in the target but %s in the source", defaultValue(target), defaultValue(source)))
+	}
+	if len(source.ValuesEditableBy) > 0 && len(target.ValuesEditableBy) > 0 && source.ValuesEditableBy != target.ValuesEditableBy {
+		differences = append(differences, fmt.Sprintf("values are editable by %s in the target but %s in the source", target.ValuesEditableBy, source.ValuesEditableBy))
+	}
+	return true, differences
+}
+
+func defaultValue(property data.CustomProperty) string {
+	values := propertyValueStrings(property.DefaultValue)
+	if len(values) == 0 {
+		return "unset"
+	}
+	return strings.Join(values, ", ")
+}
+
+// MigratePropertySchema creates the custom properties of the source that do not
+// exist in the target organization, returning the outcome for each property
+// and the names of the properties whose values can be copied.
+func (g *APIGetter) MigratePropertySchema(owner string, sourceProperties []data.CustomProperty, targetProperties []data.CustomProperty, dryRun bool) ([]data.PropertyMigration, map[string]struct{}) {
+	var results []data.PropertyMigration
+	compatible := make(map[string]struct{})
+	existing := make(map[string]data.CustomProperty)
+	for _, property := range targetProperties {
+		existing[strings.ToLower(property.PropertyName)] = property
+	}
+
+	for _, property := range sourceProperties {
+		result := data.PropertyMigration{
+			Type: "schema",
+			Name: property.PropertyName,
+		}
+		if targetProperty, ok := existing[strings.ToLower(property.PropertyName)]; ok {
+			isCompatible, differences := ComparePropertyDefinitions(property, targetProperty)
+			result.Message = strings.Join(differences, "; ")
+			if !isCompatible {
+				zap.S().Warnf("Custom property %s already exists in %s with an incompatible definition: %s", property.PropertyName, owner, result.Message)
+				result.Action = "incompatible"
+				result.Status = "skipped"
+				results = append(results, result)
+				continue
+			}
+			if len(differences) > 0 {
+				zap.S().Warnf("Custom property %s already exists in %s with a different definition: %s", property.PropertyName, owner, result.Message)
+				result.Action = "differs"
+			} else {
+				zap.S().Debugf("Custom property %s already exists in %s", property.PropertyName, owner)
+				result.Action = "exists"
+			}
+			result.Status = "skipped"
+			compatible[targetProperty.PropertyName] = struct{}{}
+			results = append(results, result)
+			continue
+		}
+
+		result.Action = "create"
+		if dryRun {
+			result.Status = "dry-run"
+		} else if err := g.CreateOrgProperty(owner, property); err != nil {
+			zap.S().Errorf("Error creating custom property %s in %s: %v", property.PropertyName, owner, err)
+			result.Status = "failed"
+			result.Message = ErrorMessage(err)
+			results = append(results, result)
+			continue
+		} else {
+			zap.S().Infof("Created custom property %s in %s", property.PropertyName, owner)
+			result.Status = "success"
+		}
+		compatible[property.PropertyName] = struct{}{}
+		results = append(results, result)
+	}
+	return results, compatible
+}
+
+// MigratePropertyValues sets the custom property values of each source
+// repository on the repository of the same name in the target organization,
+// or the repository it is mapped to. Only the values of properties in
+// compatible are copied, and unset values are left out.
+func (g *APIGetter) MigratePropertyValues(owner string, values []data.RepoPropertyValues, compatible map[string]struct{}, dryRun bool) []data.PropertyMigration {
+	propertyNames := make(map[string]string, len(compatible))
+	for name := range compatible {
+		propertyNames[strings.ToLower(name)] = name
+	}
+
+	var results []data.PropertyMigration
+	for _, repo := range values {
+		var properties []data.PropertyValue
+		var names []string
+		for _, property := range repo.Properties {
+			name, ok := propertyNames[strings.ToLower(property.PropertyName)]
+			if !ok || property.Value == nil {
+				continue
+			}
+			properties = append(properties, data.PropertyValue{PropertyName: name, Value: property.Value})
+			names = append(names, name)
+		}
+		if len(properties) == 0 {
+			continue
+		}
+		sort.Strings(names)
+		targetRepo := g.MappedRepo(repo.RepositoryName)
+		result := data.PropertyMigration{
+			Type:    "values",
+			Name:    targetRepo,
+			Action:  "update",
+			Message: strings.Join(names, ", "),
+		}
+		if dryRun {
+			result.Status = "dry-run"
+		} else if err := g.UpdateRepoPropertyValues(owner, data.RepoPropertyValuesUpdate{RepositoryNames: []string{targetRepo}, Properties: properties}); err != nil {
+			zap.S().Errorf("Error setting custom property values for %s/%s: %v", owner, targetRepo, err)
+			result.Status = "failed"
+			result.Message = ErrorMessage(err)
+		} else {
+			zap.S().Debugf("Set custom property values %s for %s/%s", result.Message, owner, targetRepo)
+			result.Status = "success"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func WritePropertyMigrationToCSV(results []data.PropertyMigration, fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{"Type", "Name", "Action", "Status", "Message"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("failed to write headers: %w", err)
+	}
+	for _, result := range results {
+		record := []string{result.Type, result.Name, result.Action, result.Status, result.Message}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxRetries    = 5
+	defaultMinBackoff    = time.Second
+	defaultMaxBackoff    = 2 * time.Minute
+	defaultReserveBudget = 10
+)
+
+var (
+	rateLimitTransportsMu sync.Mutex
+	rateLimitTransports   []*RateLimitTransport
+)
+
+// RateLimitTransport is an http.RoundTripper that tracks the GitHub rate limit
+// budget from response headers, waits for the budget to reset when it is
+// nearly exhausted, and retries server errors and primary or secondary rate
+// limit responses with exponential backoff and jitter. Server errors and
+// transport errors are only retried for idempotent methods and GraphQL
+// queries, so a POST or mutation that may have reached the server is never
+// sent twice.
+type RateLimitTransport struct {
+	Base          http.RoundTripper
+	Host          string
+	MaxRetries    int
+	MinBackoff    time.Duration
+	MaxBackoff    time.Duration
+	ReserveBudget int
+	Sleep         func(context.Context, time.Duration) error
+
+	mu      sync.Mutex
+	budgets map[string]*rateLimitBudget
+}
+
+type rateLimitBudget struct {
+	limit          int
+	remaining      int
+	startRemaining int
+	used           int
+	reset          time.Time
+}
+
+func NewRateLimitTransport(host string, base http.RoundTripper) *RateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RateLimitTransport{
+		Base:          base,
+		Host:          host,
+		MaxRetries:    defaultMaxRetries,
+		MinBackoff:    defaultMinBackoff,
+		MaxBackoff:    defaultMaxBackoff,
+		ReserveBudget: defaultReserveBudget,
+		Sleep:         sleepContext,
+		budgets:       make(map[string]*rateLimitBudget),
+	}
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := "core"
+	if strings.HasSuffix(req.URL.Path, "/graphql") {
+		resource = "graphql"
+	}
+
+	ctx := req.Context()
+	retryable := isRetryable(req)
+
+	for attempt := 0; ; attempt++ {
+		if err := t.throttle(ctx, resource); err != nil {
+			return nil, err
+		}
+
+		attemptReq := req
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		resp, err := t.Base.RoundTrip(attemptReq)
+		if err != nil {
+			if !retryable || attempt >= t.MaxRetries || ctx.Err() != nil {
+				return nil, err
+			}
+			wait := t.backoff(attempt)
+			zap.S().Infof("Request to %s failed: %v, retrying in %s", req.URL.Path, err, wait)
+			if err := t.Sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		t.updateBudget(resp)
+
+		retry, wait := t.shouldRetry(req, resp, attempt, retryable)
+		if !retry || attempt >= t.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		zap.S().Infof("Request to %s returned %d, retrying in %s (attempt %d of %d)", req.URL.Path, resp.StatusCode, wait.Round(time.Second), attempt+1, t.MaxRetries)
+		io.Copy(io.Discard, resp.Body) // nolint:errcheck
+		resp.Body.Close()
+		if err := t.Sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleepContext waits for d to pass, returning early with the context error
+// when ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttle waits for the rate limit to reset when the remaining budget of the
+// resource is at or below the reserve.
+func (t *RateLimitTransport) throttle(ctx context.Context, resource string) error {
+	t.mu.Lock()
+	budget, ok := t.budgets[resource]
+	var wait time.Duration
+	var remaining int
+	if ok && budget.limit > 0 && budget.remaining <= t.ReserveBudget {
+		wait = time.Until(budget.reset)
+		remaining = budget.remaining
+	}
+	t.mu.Unlock()
+
+	if wait > 0 {
+		zap.S().Infof("Rate limit for %s nearly exhausted (%d remaining), waiting %s for reset", resource, remaining, wait.Round(time.Second))
+		return t.Sleep(ctx, wait)
+	}
+	return nil
+}
+
+func (t *RateLimitTransport) updateBudget(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	used, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Used"))
+	resetUnix, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	budget, ok := t.budgets[resource]
+	if !ok {
+		budget = &rateLimitBudget{startRemaining: remaining + 1}
+		t.budgets[resource] = budget
+	}
+	reset := time.Unix(resetUnix, 0)
+	if ok && reset.After(budget.reset) {
+		budget.used += budget.startRemaining - budget.remaining
+		budget.startRemaining = limit
+	}
+	budget.limit = limit
+	budget.remaining = remaining
+	budget.reset = reset
+	zap.S().Debugf("Rate limit for %s: %d used, %d of %d remaining, resets at %s", resource, used, remaining, limit, reset.Format(time.RFC3339))
+}
+
+// shouldRetry reports whether a response is a server error on a retryable
+// request or a primary or secondary rate limit, and how long to wait before
+// retrying it.
+func (t *RateLimitTransport) shouldRetry(req *http.Request, resp *http.Response, attempt int, retryable bool) (bool, time.Duration) {
+	switch {
+	case resp.StatusCode >= 500:
+		if retryable {
+			return true, t.retryAfter(resp, attempt)
+		}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true, t.retryAfter(resp, attempt)
+	case resp.StatusCode == http.StatusForbidden:
+		if resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			return true, t.retryAfter(resp, attempt)
+		}
+		if responseContains(resp, "rate limit") {
+			return true, t.retryAfter(resp, attempt)
+		}
+	case resp.StatusCode == http.StatusOK && strings.HasSuffix(req.URL.Path, "/graphql"):
+		if responseContains(resp, `"RATE_LIMITED"`) {
+			return true, t.retryAfter(resp, attempt)
+		}
+	}
+	return false, 0
+}
+
+// isRetryable reports whether a request can safely be sent again after the
+// server may already have processed it. GraphQL reads are sent as POST
+// requests, so a POST to the GraphQL endpoint is retryable unless it is a
+// mutation.
+func isRetryable(req *http.Request) bool {
+	if isIdempotent(req.Method) {
+		return true
+	}
+	if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/graphql") || req.GetBody == nil {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+	var payload struct {
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(body).Decode(&payload); err != nil {
+		return false
+	}
+	query := strings.TrimSpace(payload.Query)
+	return len(query) > 0 && !strings.HasPrefix(query, "mutation")
+}
+
+// isIdempotent reports whether a request with the method can safely be sent
+// again after the server may already have processed it.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter uses the Retry-After header or rate limit reset time when present,
+// falling back to exponential backoff.
+func (t *RateLimitTransport) retryAfter(resp *http.Response, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if resetUnix, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			if wait := time.Until(time.Unix(resetUnix, 0)); wait > 0 {
+				return wait + time.Second
+			}
+		}
+	}
+	return t.backoff(attempt)
+}
+
+func (t *RateLimitTransport) backoff(attempt int) time.Duration {
+	wait := time.Duration(float64(t.MinBackoff) * math.Pow(2, float64(attempt)))
+	if wait > t.MaxBackoff || wait <= 0 {
+		wait = t.MaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(wait)/2 + 1))
+	return wait/2 + jitter
+}
+
+// responseContains reads the response body to look for text, replacing the
+// body so it can still be read by the caller.
+func responseContains(resp *http.Response, text string) bool {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(body)), strings.ToLower(text))
+}
+
+// Usage returns the number of requests consumed per rate limit resource.
+func (t *RateLimitTransport) Usage() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usage := make(map[string]int)
+	for resource, budget := range t.budgets {
+		usage[resource] = budget.used + budget.startRemaining - budget.remaining
+	}
+	return usage
+}
+
+// LogRateLimitUsage logs the rate limit budget consumed by every client
+// created with InitializeClients.
+func LogRateLimitUsage() {
+	rateLimitTransportsMu.Lock()
+	defer rateLimitTransportsMu.Unlock()
+	for _, transport := range rateLimitTransports {
+		for resource, used := range transport.Usage() {
+			transport.mu.Lock()
+			remaining := transport.budgets[resource].remaining
+			transport.mu.Unlock()
+			zap.S().Infof("Consumed %d %s rate limit requests on %s, %d remaining", used, resource, transport.Host, remaining)
+		}
+	}
+}
+
+func registerRateLimitTransport(transport *RateLimitTransport) {
+	rateLimitTransportsMu.Lock()
+	defer rateLimitTransportsMu.Unlock()
+	rateLimitTransports = append(rateLimitTransports, transport)
+}
@@ -0,0 +1,364 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer replies to each request with the next handler in the list and
+// records the request bodies it received.
+type fakeServer struct {
+	mu       sync.Mutex
+	handlers []http.HandlerFunc
+	bodies   []string
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	body, _ := io.ReadAll(r.Body)
+	f.bodies = append(f.bodies, string(body))
+	handler := f.handlers[len(f.bodies)-1]
+	f.mu.Unlock()
+	handler(w, r)
+}
+
+func (f *fakeServer) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.bodies)
+}
+
+func newTestTransport(t *testing.T, handlers ...http.HandlerFunc) (*RateLimitTransport, *fakeServer, string, *[]time.Duration) {
+	t.Helper()
+	fake := &fakeServer{handlers: handlers}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	var sleeps []time.Duration
+	transport := NewRateLimitTransport("github.com", server.Client().Transport)
+	transport.Sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return transport, fake, server.URL, &sleeps
+}
+
+func status(code int, headers map[string]string, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for key, value := range headers {
+			w.Header().Set(key, value)
+		}
+		w.WriteHeader(code)
+		io.WriteString(w, body) // nolint:errcheck
+	}
+}
+
+func doRequest(t *testing.T, transport *RateLimitTransport, method, url, body string) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = bytes.NewReader([]byte(body))
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestRateLimitTransportRetryAfterOn429(t *testing.T) {
+	transport, fake, url, sleeps := newTestTransport(t,
+		status(http.StatusTooManyRequests, map[string]string{"Retry-After": "7"}, ""),
+		status(http.StatusOK, nil, "ok"),
+	)
+
+	resp := doRequest(t, transport, http.MethodPost, url+"/orgs/o/rulesets", `{"name":"a"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if fake.calls() != 2 {
+		t.Fatalf("calls = %d, want 2", fake.calls())
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != 7*time.Second {
+		t.Fatalf("sleeps = %v, want [7s]", *sleeps)
+	}
+}
+
+func TestRateLimitTransportSecondaryLimit403(t *testing.T) {
+	transport, fake, url, sleeps := newTestTransport(t,
+		status(http.StatusForbidden, nil, `{"message":"You have exceeded a secondary rate limit."}`),
+		status(http.StatusOK, nil, "ok"),
+	)
+
+	resp := doRequest(t, transport, http.MethodPost, url+"/orgs/o/rulesets", `{"name":"a"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if fake.calls() != 2 || len(*sleeps) != 1 {
+		t.Fatalf("calls = %d, sleeps = %v, want one retry", fake.calls(), *sleeps)
+	}
+}
+
+func TestRateLimitTransportForbiddenNotRetried(t *testing.T) {
+	transport, fake, url, _ := newTestTransport(t,
+		status(http.StatusForbidden, nil, `{"message":"Resource not accessible by integration"}`),
+	)
+
+	resp := doRequest(t, transport, http.MethodGet, url+"/orgs/o/rulesets", "")
+	if resp.StatusCode != http.StatusForbidden || fake.calls() != 1 {
+		t.Fatalf("status = %d, calls = %d, want 403 without retry", resp.StatusCode, fake.calls())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "Resource not accessible") {
+		t.Fatalf("body was not preserved: %q", body)
+	}
+}
+
+func TestRateLimitTransportServerErrorBackoff(t *testing.T) {
+	transport, fake, url, sleeps := newTestTransport(t,
+		status(http.StatusBadGateway, nil, ""),
+		status(http.StatusBadGateway, nil, ""),
+		status(http.StatusBadGateway, nil, ""),
+		status(http.StatusOK, nil, "ok"),
+	)
+	transport.MinBackoff = time.Second
+	transport.MaxBackoff = time.Minute
+
+	resp := doRequest(t, transport, http.MethodGet, url+"/orgs/o/rulesets", "")
+	if resp.StatusCode != http.StatusOK || fake.calls() != 4 {
+		t.Fatalf("status = %d, calls = %d, want 200 after 4 calls", resp.StatusCode, fake.calls())
+	}
+	if len(*sleeps) != 3 {
+		t.Fatalf("sleeps = %v, want 3", *sleeps)
+	}
+	for attempt, wait := range *sleeps {
+		base := time.Second << attempt
+		if wait < base/2 || wait > base {
+			t.Errorf("attempt %d waited %s, want between %s and %s", attempt, wait, base/2, base)
+		}
+	}
+}
+
+func TestRateLimitTransportServerErrorGivesUp(t *testing.T) {
+	handlers := make([]http.HandlerFunc, 0)
+	for i := 0; i < 3; i++ {
+		handlers = append(handlers, status(http.StatusServiceUnavailable, nil, ""))
+	}
+	transport, fake, url, _ := newTestTransport(t, handlers...)
+	transport.MaxRetries = 2
+
+	resp := doRequest(t, transport, http.MethodDelete, url+"/orgs/o/rulesets/1", "")
+	if resp.StatusCode != http.StatusServiceUnavailable || fake.calls() != 3 {
+		t.Fatalf("status = %d, calls = %d, want 503 after 3 calls", resp.StatusCode, fake.calls())
+	}
+}
+
+func TestRateLimitTransportPostServerErrorNotRetried(t *testing.T) {
+	transport, fake, url, sleeps := newTestTransport(t,
+		status(http.StatusBadGateway, nil, ""),
+		status(http.StatusOK, nil, "ok"),
+	)
+
+	resp := doRequest(t, transport, http.MethodPost, url+"/orgs/o/rulesets", `{"name":"a"}`)
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", resp.StatusCode)
+	}
+	if fake.calls() != 1 || len(*sleeps) != 0 {
+		t.Fatalf("calls = %d, sleeps = %v, want POST sent once", fake.calls(), *sleeps)
+	}
+}
+
+func TestRateLimitTransportPostTransportErrorNotRetried(t *testing.T) {
+	var calls int
+	transport := NewRateLimitTransport("github.com", roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return nil, io.ErrUnexpectedEOF
+	}))
+	transport.Sleep = func(context.Context, time.Duration) error { return nil }
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.github.com/orgs/o/rulesets", strings.NewReader(`{}`))
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+
+	calls = 0
+	req, _ = http.NewRequest(http.MethodPut, "https://api.github.com/orgs/o/rulesets/1", strings.NewReader(`{}`))
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != transport.MaxRetries+1 {
+		t.Fatalf("calls = %d, want %d", calls, transport.MaxRetries+1)
+	}
+}
+
+func TestRateLimitTransportGraphQLServerError(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantCalls int
+	}{
+		{name: "query", body: `{"query":"query($owner: String!) {organization(login: $owner) {id}}"}`, wantCalls: 2},
+		{name: "anonymous query", body: `{"query":"{viewer{login}}"}`, wantCalls: 2},
+		{name: "mutation", body: `{"query":"mutation {addStar(input: {}) {clientMutationId}}"}`, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			transport, fake, url, _ := newTestTransport(t,
+				status(http.StatusBadGateway, nil, ""),
+				status(http.StatusOK, nil, `{"data":{}}`),
+			)
+
+			doRequest(t, transport, http.MethodPost, url+"/api/graphql", tt.body)
+			if fake.calls() != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", fake.calls(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRateLimitTransportKeepsCallerRequest(t *testing.T) {
+	var bodies []io.ReadCloser
+	transport := NewRateLimitTransport("github.com", roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		bodies = append(bodies, req.Body)
+		io.Copy(io.Discard, req.Body) // nolint:errcheck
+		if len(bodies) == 1 {
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	}))
+	transport.Sleep = func(context.Context, time.Duration) error { return nil }
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.github.com/orgs/o/rulesets", strings.NewReader(`{}`))
+	body := req.Body
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("calls = %d, want 2", len(bodies))
+	}
+	if req.Body != body {
+		t.Error("caller's request body was replaced")
+	}
+	if bodies[1] == body {
+		t.Error("retry reused the consumed body")
+	}
+}
+
+func TestRateLimitTransportContextCanceledDuringWait(t *testing.T) {
+	var calls int
+	transport := NewRateLimitTransport("github.com", roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		header := http.Header{"Retry-After": []string{"3600"}}
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Header: header, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/orgs/o/rulesets", nil)
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := transport.RoundTrip(req)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RoundTrip kept waiting after the context was canceled")
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRateLimitTransportReserveBudgetThrottle(t *testing.T) {
+	reset := time.Now().Add(30 * time.Second).Unix()
+	headers := map[string]string{
+		"X-RateLimit-Limit":     "5000",
+		"X-RateLimit-Remaining": "3",
+		"X-RateLimit-Used":      "4997",
+		"X-RateLimit-Reset":     strconv.FormatInt(reset, 10),
+		"X-RateLimit-Resource":  "core",
+	}
+	transport, fake, url, sleeps := newTestTransport(t,
+		status(http.StatusOK, headers, "ok"),
+		status(http.StatusOK, nil, "ok"),
+	)
+
+	doRequest(t, transport, http.MethodGet, url+"/orgs/o/repos", "")
+	if len(*sleeps) != 0 {
+		t.Fatalf("first request slept %v", *sleeps)
+	}
+	doRequest(t, transport, http.MethodGet, url+"/orgs/o/repos", "")
+	if fake.calls() != 2 {
+		t.Fatalf("calls = %d, want 2", fake.calls())
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] <= 0 || (*sleeps)[0] > 30*time.Second {
+		t.Fatalf("sleeps = %v, want one wait until reset", *sleeps)
+	}
+}
+
+func TestRateLimitTransportGraphQLRateLimited(t *testing.T) {
+	transport, fake, url, sleeps := newTestTransport(t,
+		status(http.StatusOK, nil, `{"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]}`),
+		status(http.StatusOK, nil, `{"data":{}}`),
+	)
+
+	resp := doRequest(t, transport, http.MethodPost, url+"/api/graphql", `{"query":"{viewer{login}}"}`)
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"data":{}}` {
+		t.Fatalf("body = %q, want retried response", body)
+	}
+	if fake.calls() != 2 || len(*sleeps) != 1 {
+		t.Fatalf("calls = %d, sleeps = %v, want one retry", fake.calls(), *sleeps)
+	}
+}
+
+func TestRateLimitTransportReplaysBody(t *testing.T) {
+	transport, fake, url, _ := newTestTransport(t,
+		status(http.StatusTooManyRequests, map[string]string{"Retry-After": "1"}, ""),
+		status(http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0"}, ""),
+		status(http.StatusCreated, nil, "{}"),
+	)
+
+	payload := `{"name":"main","enforcement":"active"}`
+	resp := doRequest(t, transport, http.MethodPost, url+"/orgs/o/rulesets", payload)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	if len(fake.bodies) != 3 {
+		t.Fatalf("calls = %d, want 3", len(fake.bodies))
+	}
+	for i, body := range fake.bodies {
+		if body != payload {
+			t.Errorf("attempt %d body = %q, want %q", i, body, payload)
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"go.uber.org/zap"
+)
+
+// KnownRuleType reports whether a rule type has its own column in ruleset csv
+// files.
+func KnownRuleType(ruleType string) bool {
+	for _, knownType := range data.HeaderMap {
+		if knownType == ruleType {
+			return true
+		}
+	}
+	return false
+}
+
+// parameterJSONNames returns the JSON names of the given data.Parameters
+// fields, or of every field when all is set.
+func parameterJSONNames(fields map[string]map[string]struct{}, all bool) map[string]struct{} {
+	jsonNames := make(map[string]struct{})
+	t := reflect.TypeOf(data.Parameters{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := fields[field.Name]; !ok && !all {
+			continue
+		}
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if len(jsonName) > 0 && jsonName != "-" {
+			jsonNames[jsonName] = struct{}{}
+		}
+	}
+	return jsonNames
+}
+
+// ruleParameterJSONNames returns the JSON names of the valid parameters of a
+// rule type.
+func ruleParameterJSONNames(ruleType string) map[string]struct{} {
+	return parameterJSONNames(GetValidFields(ruleType), false)
+}
+
+// unknownParameters returns the raw parameters of a rule whose names are not
+// in known.
+func unknownParameters(rawParameters json.RawMessage, known map[string]struct{}) (map[string]json.RawMessage, error) {
+	var parameters map[string]json.RawMessage
+	if err := json.Unmarshal(rawParameters, &parameters); err != nil {
+		return nil, err
+	}
+	for name := range parameters {
+		if _, ok := known[name]; ok {
+			delete(parameters, name)
+		}
+	}
+	return parameters, nil
+}
+
+// UnsupportedRules lists the rule types and parameters of a ruleset that are
+// not natively supported, as type or type.parameter. Parameters are supported
+// when they are valid fields of their rule type, matching the parameters that
+// RawRulesToCSV leaves out of the RulesRawJSON column.
+func UnsupportedRules(ruleset data.RepoRuleset) []string {
+	var unsupported []string
+	for _, rule := range ruleset.Rules {
+		if !KnownRuleType(rule.Type) {
+			unsupported = append(unsupported, rule.Type)
+			continue
+		}
+		if len(rule.RawParameters) == 0 {
+			continue
+		}
+		parameters, err := unknownParameters(rule.RawParameters, ruleParameterJSONNames(rule.Type))
+		if err != nil {
+			zap.S().Debugf("Failed to read parameters of rule %s: %v", rule.Type, err)
+			continue
+		}
+		for name := range parameters {
+			unsupported = append(unsupported, fmt.Sprintf("%s.%s", rule.Type, name))
+		}
+	}
+	sort.Strings(unsupported)
+	return unsupported
+}
+
+// WarnUnsupportedRules warns about the rule types and parameters of a ruleset
+// that are not natively supported and are passed through as raw JSON.
+func WarnUnsupportedRules(ruleset data.RepoRuleset) {
+	if unsupported := UnsupportedRules(ruleset); len(unsupported) > 0 {
+		zap.S().Warnf("Ruleset %s from %s has rules or parameters that are not natively supported and are copied as raw JSON: %s", ruleset.Name, ruleset.Source, strings.Join(unsupported, ", "))
+	}
+}
+
+// RawRulesToCSV formats the rules and parameters that the rule columns of a
+// ruleset csv file cannot hold as a JSON array of rules.
+func RawRulesToCSV(rules []data.Rules) (string, error) {
+	var rawRules []data.RawRule
+	for _, rule := range rules {
+		if !KnownRuleType(rule.Type) {
+			rawRules = append(rawRules, data.RawRule{Type: rule.Type, Parameters: rule.RawParameters})
+			continue
+		}
+		if len(rule.RawParameters) == 0 {
+			continue
+		}
+		parameters, err := unknownParameters(rule.RawParameters, ruleParameterJSONNames(rule.Type))
+		if err != nil {
+			return "", err
+		}
+		if len(parameters) == 0 {
+			continue
+		}
+		parametersJSON, err := json.Marshal(parameters)
+		if err != nil {
+			return "", err
+		}
+		rawRules = append(rawRules, data.RawRule{Type: rule.Type, Parameters: parametersJSON})
+	}
+	if len(rawRules) == 0 {
+		return "", nil
+	}
+	rawJSON, err := json.Marshal(rawRules)
+	if err != nil {
+		return "", err
+	}
+	return string(rawJSON), nil
+}
+
+// ApplyRawRules adds the rules of a RulesRawJSON csv value to rules. Parameters
+// of a rule type already in rules are merged into that rule.
+func ApplyRawRules(rules []data.Rules, rawJSON string) ([]data.Rules, error) {
+	if len(strings.TrimSpace(rawJSON)) == 0 {
+		return rules, nil
+	}
+	var rawRules []data.RawRule
+	if err := json.Unmarshal([]byte(rawJSON), &rawRules); err != nil {
+		return rules, err
+	}
+	for _, rawRule := range rawRules {
+		i := 0
+		for i < len(rules) && rules[i].Type != rawRule.Type {
+			i++
+		}
+		if i == len(rules) {
+			rules = append(rules, data.Rules{Type: rawRule.Type})
+		}
+		if len(rawRule.Parameters) == 0 {
+			continue
+		}
+		rules[i].RawParameters = rawRule.Parameters
+		if !KnownRuleType(rawRule.Type) {
+			continue
+		}
+		if rules[i].Parameters == nil {
+			rules[i].Parameters = &data.Parameters{}
+		}
+		if err := json.Unmarshal(rawRule.Parameters, rules[i].Parameters); err != nil {
+			return rules, fmt.Errorf("parameters of rule %s: %w", rawRule.Type, err)
+		}
+	}
+	return rules, nil
+}
+
+// mergeRawParameters adds the raw parameters of a rule that data.Parameters
+// does not have to its processed parameters.
+func mergeRawParameters(parameters interface{}, rawParameters json.RawMessage) (interface{}, error) {
+	unknown, err := unknownParameters(rawParameters, parameterJSONNames(nil, true))
+	if err != nil || len(unknown) == 0 {
+		return parameters, err
+	}
+	parametersJSON, err := json.Marshal(parameters)
+	if err != nil {
+		return parameters, err
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(parametersJSON, &merged); err != nil {
+		return parameters, err
+	}
+	for name, value := range unknown {
+		merged[name] = value
+	}
+	return merged, nil
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func TestUnsupportedRulesMatchesRawRulesToCSV(t *testing.T) {
+	rules := []data.Rules{
+		{
+			Type:          "pull_request",
+			Parameters:    &data.Parameters{RequiredApprovingReviewCount: 1},
+			RawParameters: json.RawMessage(`{"required_approving_review_count":1,"pattern":"main","allowed_merge_methods":["squash"]}`),
+		},
+		{Type: "future_rule", RawParameters: json.RawMessage(`{"enabled":true}`)},
+	}
+
+	unsupported := UnsupportedRules(data.RepoRuleset{Rules: rules})
+	want := []string{"future_rule", "pull_request.allowed_merge_methods", "pull_request.pattern"}
+	if !reflect.DeepEqual(unsupported, want) {
+		t.Errorf("UnsupportedRules = %v, want %v", unsupported, want)
+	}
+
+	rawJSON, err := RawRulesToCSV(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rawRules []data.RawRule
+	if err := json.Unmarshal([]byte(rawJSON), &rawRules); err != nil {
+		t.Fatal(err)
+	}
+	var passedThrough []string
+	for _, rawRule := range rawRules {
+		if !KnownRuleType(rawRule.Type) {
+			passedThrough = append(passedThrough, rawRule.Type)
+			continue
+		}
+		var parameters map[string]json.RawMessage
+		if err := json.Unmarshal(rawRule.Parameters, &parameters); err != nil {
+			t.Fatal(err)
+		}
+		for name := range parameters {
+			passedThrough = append(passedThrough, rawRule.Type+"."+name)
+		}
+	}
+	if len(passedThrough) != len(want) {
+		t.Errorf("RulesRawJSON holds %v, want the unsupported %v", passedThrough, want)
+	}
+	for _, name := range want {
+		found := false
+		for _, each := range passedThrough {
+			found = found || each == name
+		}
+		if !found {
+			t.Errorf("RulesRawJSON %s is missing %s", rawJSON, name)
+		}
+	}
+}
+
+func TestUnsupportedRulesKnowsUpdateParameters(t *testing.T) {
+	var rule data.Rules
+	if err := json.Unmarshal([]byte(`{"type":"update","parameters":{"update_allows_fetch_and_merge":true}}`), &rule); err != nil {
+		t.Fatal(err)
+	}
+	ruleset := data.RepoRuleset{Rules: []data.Rules{rule}}
+
+	if unsupported := UnsupportedRules(ruleset); len(unsupported) > 0 {
+		t.Errorf("UnsupportedRules = %v, want none", unsupported)
+	}
+	rawJSON, err := RawRulesToCSV(ruleset.Rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rawJSON) > 0 {
+		t.Errorf("RulesRawJSON = %s, want empty", rawJSON)
+	}
+}
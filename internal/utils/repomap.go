@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// RepoMap renames source repositories to target repositories, either by exact
+// name or by regular expression rules applied in the order they are listed.
+type RepoMap struct {
+	names map[string]string
+	rules []repoMapRule
+}
+
+type repoMapRule struct {
+	pattern *regexp.Regexp
+	target  string
+}
+
+// Lookup returns the target repository name mapped to a source repository
+// name. Exact names are matched case insensitively and take precedence over
+// regular expression rules. A nil RepoMap has no mappings.
+func (m *RepoMap) Lookup(source string) (string, bool) {
+	if m == nil || len(source) == 0 {
+		return "", false
+	}
+	if target, ok := m.names[strings.ToLower(source)]; ok {
+		return target, true
+	}
+	for _, rule := range m.rules {
+		if rule.pattern.MatchString(source) {
+			return rule.pattern.ReplaceAllString(source, rule.target), true
+		}
+	}
+	return "", false
+}
+
+// Target returns the target repository name for a source repository, which is
+// the source name when it is not mapped.
+func (m *RepoMap) Target(source string) string {
+	if target, ok := m.Lookup(source); ok {
+		return target
+	}
+	return source
+}
+
+// ReadRepoMap reads repository mappings from a csv or yaml file. Mappings
+// with a Match of regex treat Source as a regular expression that must match
+// the whole repository name, and Target may reference its groups as $1.
+func ReadRepoMap(fileName string) (*RepoMap, error) {
+	var mappings []data.RepoMapping
+	switch filepath.Ext(fileName) {
+	case ".yaml", ".yml":
+		yamlData, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read repo map: %w", err)
+		}
+		if err := yaml.Unmarshal(yamlData, &mappings); err != nil {
+			return nil, fmt.Errorf("failed to parse repo map: %w", err)
+		}
+	default:
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open repo map: %w", err)
+		}
+		defer f.Close()
+		csvReader := csv.NewReader(f)
+		csvReader.FieldsPerRecord = -1
+		records, err := csvReader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read repo map: %w", err)
+		}
+		if len(records) == 0 {
+			return nil, fmt.Errorf("repo map %s is empty", fileName)
+		}
+		columns := RulesetCSVColumns{}
+		for i, header := range records[0] {
+			columns[header] = i
+		}
+		for _, header := range []string{"Source", "Target"} {
+			if _, ok := columns[header]; !ok {
+				return nil, fmt.Errorf("repo map %s is missing the %s column", fileName, header)
+			}
+		}
+		for _, record := range records[1:] {
+			mappings = append(mappings, data.RepoMapping{
+				Source: columns.Value(record, "Source"),
+				Target: columns.Value(record, "Target"),
+				Match:  columns.Value(record, "Match"),
+			})
+		}
+	}
+
+	repoMap := &RepoMap{names: make(map[string]string)}
+	for _, mapping := range mappings {
+		if len(mapping.Source) == 0 || len(mapping.Target) == 0 {
+			continue
+		}
+		switch mapping.Match {
+		case "", "exact":
+			repoMap.names[strings.ToLower(mapping.Source)] = mapping.Target
+		case "regex":
+			pattern, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", mapping.Source))
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q in repo map: %w", mapping.Source, err)
+			}
+			repoMap.rules = append(repoMap.rules, repoMapRule{pattern: pattern, target: mapping.Target})
+		default:
+			return nil, fmt.Errorf("invalid match %q in repo map, valid values are 'exact' or 'regex'", mapping.Match)
+		}
+	}
+	return repoMap, nil
+}
+
+// MapRulesetRepositories renames the repository a repository level ruleset is
+// created in, and the repository name conditions of an organization ruleset,
+// using the repo map set on the APIGetter.
+func (g *APIGetter) MapRulesetRepositories(ruleset data.RepoRuleset) data.RepoRuleset {
+	if g.repoMap == nil {
+		return ruleset
+	}
+	switch ruleset.SourceType {
+	case "Repository":
+		if owner, repo, found := strings.Cut(ruleset.Source, "/"); found {
+			ruleset.Source = fmt.Sprintf("%s/%s", owner, g.MappedRepo(repo))
+		}
+	case "Organization":
+		if ruleset.Conditions != nil && ruleset.Conditions.RepositoryName != nil {
+			names := *ruleset.Conditions.RepositoryName
+			names.Include = g.mapRepoPatterns(names.Include)
+			names.Exclude = g.mapRepoPatterns(names.Exclude)
+			conditions := *ruleset.Conditions
+			conditions.RepositoryName = &names
+			ruleset.Conditions = &conditions
+		}
+	}
+	return ruleset
+}
+
+// MappedRepo returns the target repository name for a source repository.
+func (g *APIGetter) MappedRepo(repo string) string {
+	target := g.repoMap.Target(repo)
+	if target != repo {
+		zap.S().Debugf("Mapping repository %s to %s", repo, target)
+	}
+	return target
+}
+
+func (g *APIGetter) mapRepoPatterns(patterns []string) []string {
+	mapped := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		if pattern == "~ALL" {
+			mapped[i] = pattern
+			continue
+		}
+		mapped[i] = g.MappedRepo(pattern)
+	}
+	return mapped
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadRepoMap(t *testing.T) {
+	files := map[string]string{
+		"map.csv": `Source,Match,Target
+Legacy-API,,api
+svc-(.*),regex,service-$1
+svc-core,regex,core
+app,regex,application
+`,
+		"map.yaml": `- source: Legacy-API
+  target: api
+- source: svc-(.*)
+  target: service-$1
+  match: regex
+- source: svc-core
+  target: core
+  match: regex
+- source: app
+  target: application
+  match: regex
+`,
+	}
+	tests := []struct {
+		source string
+		want   string
+		mapped bool
+	}{
+		{source: "legacy-api", want: "api", mapped: true},
+		{source: "svc-billing", want: "service-billing", mapped: true},
+		{source: "svc-core", want: "service-core", mapped: true},
+		{source: "app", want: "application", mapped: true},
+		{source: "my-app", want: "my-app"},
+		{source: "apps", want: "apps"},
+		{source: "", want: ""},
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(fileName, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			repoMap, err := ReadRepoMap(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tt := range tests {
+				target, mapped := repoMap.Lookup(tt.source)
+				if mapped != tt.mapped || repoMap.Target(tt.source) != tt.want || (mapped && target != tt.want) {
+					t.Errorf("Lookup(%q) = %q, %v, want %q, %v", tt.source, target, mapped, tt.want, tt.mapped)
+				}
+			}
+		})
+	}
+}
+
+func TestReadRepoMapErrors(t *testing.T) {
+	files := map[string]string{
+		"invalid regex":  "Source,Match,Target\nsvc-(,regex,service\n",
+		"invalid match":  "Source,Match,Target\nsvc-*,glob,service\n",
+		"missing column": "Source,Match\nsvc,exact\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "map.csv")
+			if err := os.WriteFile(fileName, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ReadRepoMap(fileName); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+// StateFile records the progress of a create run on disk after every change,
+// so an interrupted migration can be resumed without repeating completed work.
+type StateFile struct {
+	path  string
+	mu    sync.Mutex
+	state data.MigrationState
+	index map[string]int
+}
+
+func NewStateFile(path string, owner string) *StateFile {
+	return &StateFile{
+		path:  path,
+		state: data.MigrationState{Owner: owner},
+		index: make(map[string]int),
+	}
+}
+
+func ReadStateFile(path string) (*StateFile, error) {
+	stateJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	s := &StateFile{path: path, index: make(map[string]int)}
+	if err := json.Unmarshal(stateJSON, &s.state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	for i, entry := range s.state.Entries {
+		s.index[StateKey(entry.SourceID, entry.RulesetLevel, entry.Source, entry.RulesetName)] = i
+	}
+	return s, nil
+}
+
+// StateKey identifies a ruleset across runs by its source ID, level, source
+// and name.
+func StateKey(sourceID int, level string, source string, name string) string {
+	return fmt.Sprintf("%d|%s|%s|%s", sourceID, level, source, name)
+}
+
+func (s *StateFile) Path() string {
+	return s.path
+}
+
+// Completed reports whether the entry was created, updated or skipped by a
+// previous run.
+func (s *StateFile) Completed(entry data.PlanEntry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.index[StateKey(entry.SourceID, entry.RulesetLevel, entry.Source, entry.RulesetName)]
+	if !ok {
+		return false
+	}
+	status := s.state.Entries[i].Status
+	return status == "completed" || status == "skipped"
+}
+
+// Record sets the status of the entry, adding it when it is not yet tracked,
+// and saves the state file.
+func (s *StateFile) Record(entry data.PlanEntry, status string, targetID int, errorMessage string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := StateKey(entry.SourceID, entry.RulesetLevel, entry.Source, entry.RulesetName)
+	stateEntry := data.StateEntry{
+		SourceID:     entry.SourceID,
+		RulesetLevel: entry.RulesetLevel,
+		Source:       entry.Source,
+		Target:       entry.Target,
+		RulesetName:  entry.RulesetName,
+		Status:       status,
+		TargetID:     targetID,
+		Error:        errorMessage,
+	}
+	if i, ok := s.index[key]; ok {
+		s.state.Entries[i] = stateEntry
+	} else {
+		s.index[key] = len(s.state.Entries)
+		s.state.Entries = append(s.state.Entries, stateEntry)
+	}
+	return s.save()
+}
+
+// Track adds the entries of a plan as pending, leaving the status of entries
+// already tracked unchanged, and saves the state file.
+func (s *StateFile) Track(entries []data.PlanEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		key := StateKey(entry.SourceID, entry.RulesetLevel, entry.Source, entry.RulesetName)
+		if _, ok := s.index[key]; ok {
+			continue
+		}
+		s.index[key] = len(s.state.Entries)
+		s.state.Entries = append(s.state.Entries, data.StateEntry{
+			SourceID:     entry.SourceID,
+			RulesetLevel: entry.RulesetLevel,
+			Source:       entry.Source,
+			Target:       entry.Target,
+			RulesetName:  entry.RulesetName,
+			Status:       "pending",
+		})
+	}
+	return s.save()
+}
+
+func (s *StateFile) save() error {
+	s.state.UpdatedAt = time.Now().Format(time.RFC3339)
+	stateJSON, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, stateJSON, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// RulesetIDFromResponse returns the ID of the ruleset in a create or update
+// response, or 0 when it cannot be determined.
+func RulesetIDFromResponse(response []byte) int {
+	var ruleset struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(response, &ruleset); err != nil {
+		return 0
+	}
+	return ruleset.ID
+}
+
+// ReadErrorRulesetsFromCSV reads a report written by WriteErrorRulesetsToCSV
+// and returns the Source and RulesetName of each row, keyed by RetryKey.
+func ReadErrorRulesetsFromCSV(fileName string) (map[string]struct{}, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(records) == 0 || len(records[0]) < 2 || records[0][0] != "Source" || records[0][1] != "RulesetName" {
+		return nil, fmt.Errorf("%s is not a ruleset error report", fileName)
+	}
+	retry := make(map[string]struct{})
+	for _, record := range records[1:] {
+		retry[RetryKey(record[0], record[1])] = struct{}{}
+	}
+	return retry, nil
+}
+
+func RetryKey(source string, name string) string {
+	return fmt.Sprintf("%s|%s", source, name)
+}
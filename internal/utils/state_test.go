@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func TestStateKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry data.PlanEntry
+		want  string
+	}{
+		{
+			name:  "organization ruleset",
+			entry: data.PlanEntry{SourceID: 12, RulesetLevel: "Organization", Source: "source", RulesetName: "main"},
+			want:  "12|Organization|source|main",
+		},
+		{
+			name:  "repository ruleset",
+			entry: data.PlanEntry{SourceID: 34, RulesetLevel: "Repository", Source: "source/repo", RulesetName: "main"},
+			want:  "34|Repository|source/repo|main",
+		},
+		{
+			name:  "ruleset from a file without a source ID",
+			entry: data.PlanEntry{RulesetLevel: "Enterprise", Source: "enterprise", RulesetName: "tags|v*"},
+			want:  "0|Enterprise|enterprise|tags|v*",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StateKey(tt.entry.SourceID, tt.entry.RulesetLevel, tt.entry.Source, tt.entry.RulesetName); got != tt.want {
+				t.Errorf("StateKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateFileCompleted(t *testing.T) {
+	recorded := data.PlanEntry{SourceID: 12, RulesetLevel: "Organization", Source: "source", Target: "target", RulesetName: "main"}
+	tests := []struct {
+		name   string
+		status string
+		entry  data.PlanEntry
+		want   bool
+	}{
+		{name: "completed", status: "completed", entry: recorded, want: true},
+		{name: "skipped", status: "skipped", entry: recorded, want: true},
+		{name: "failed", status: "failed", entry: recorded, want: false},
+		{name: "pending", status: "pending", entry: recorded, want: false},
+		{
+			name:   "same name at another level",
+			status: "completed",
+			entry:  data.PlanEntry{SourceID: 12, RulesetLevel: "Repository", Source: "source", RulesetName: "main"},
+			want:   false,
+		},
+		{
+			name:   "same name with another source ID",
+			status: "completed",
+			entry:  data.PlanEntry{SourceID: 13, RulesetLevel: "Organization", Source: "source", RulesetName: "main"},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			state := NewStateFile(path, "target")
+			if err := state.Record(recorded, tt.status, 0, ""); err != nil {
+				t.Fatal(err)
+			}
+			if got := state.Completed(tt.entry); got != tt.want {
+				t.Errorf("Completed() = %v, want %v", got, tt.want)
+			}
+
+			resumed, err := ReadStateFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resumed.Completed(tt.entry); got != tt.want {
+				t.Errorf("Completed() after reading the state file = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateFileTrackAndRecord(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	first := data.PlanEntry{SourceID: 1, RulesetLevel: "Organization", Source: "source", Target: "target", RulesetName: "first"}
+	second := data.PlanEntry{SourceID: 2, RulesetLevel: "Organization", Source: "source", Target: "target", RulesetName: "second"}
+
+	state := NewStateFile(path, "target")
+	if err := state.Track([]data.PlanEntry{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Record(first, "completed", 101, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Record(second, "failed", 0, "Validation Failed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Track([]data.PlanEntry{first, second}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "state.json" {
+		t.Errorf("files = %v, want only state.json without a leftover temporary file", files)
+	}
+
+	resumed, err := ReadStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []data.StateEntry{
+		{SourceID: 1, RulesetLevel: "Organization", Source: "source", Target: "target", RulesetName: "first", Status: "completed", TargetID: 101},
+		{SourceID: 2, RulesetLevel: "Organization", Source: "source", Target: "target", RulesetName: "second", Status: "failed", Error: "Validation Failed"},
+	}
+	if !reflect.DeepEqual(resumed.state.Entries, want) {
+		t.Errorf("entries = %+v, want %+v", resumed.state.Entries, want)
+	}
+	if resumed.state.Owner != "target" {
+		t.Errorf("owner = %q, want target", resumed.state.Owner)
+	}
+}
+
+func TestReadStateFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "missing file", path: filepath.Join(dir, "missing.json"), wantErr: "failed to read state file"},
+		{name: "invalid json", path: invalid, wantErr: "failed to parse state file"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadStateFile(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadErrorRulesetsFromCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     map[string]struct{}
+		wantErr  string
+	}{
+		{
+			name:     "error report",
+			contents: "Source,RulesetName,Action,Error\nsource,main,create,Validation Failed\nsource/repo,\"tags, v*\",update,Not Found\n",
+			want: map[string]struct{}{
+				RetryKey("source", "main"):          {},
+				RetryKey("source/repo", "tags, v*"): {},
+			},
+		},
+		{
+			name:     "error report without rows",
+			contents: "Source,RulesetName,Action,Error\n",
+			want:     map[string]struct{}{},
+		},
+		{
+			name:     "ruleset csv file",
+			contents: "RulesetLevel,RepositoryName,RulesetName\nOrganization,,main\n",
+			wantErr:  "is not a ruleset error report",
+		},
+		{
+			name:    "empty file",
+			wantErr: "is not a ruleset error report",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "errors.csv")
+			if err := os.WriteFile(path, []byte(tt.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadErrorRulesetsFromCSV(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadErrorRulesetsFromCSV() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorReportRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.csv")
+	errorRulesets := []data.ErrorRulesets{
+		{Source: "source", RulesetName: "main", Action: "create", Error: "Validation Failed"},
+		{Source: "source/repo", RulesetName: "release|v*", Action: "replace", Error: "Not Found"},
+	}
+	if err := WriteErrorRulesetsToCSV(errorRulesets, path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadErrorRulesetsFromCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct{}{
+		RetryKey("source", "main"):            {},
+		RetryKey("source/repo", "release|v*"): {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("retry keys = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+	"github.com/katiem0/gh-migrate-rulesets/internal/evaluate"
+	"go.uber.org/zap"
+)
+
+// RepoSelector selects the repositories a ruleset template is applied to.
+// Every selector that is set must match, and a repository matches a selector
+// when it matches any of its values.
+type RepoSelector struct {
+	Patterns     []string
+	Topics       []string
+	Visibilities []string
+	Properties   map[string][]string
+}
+
+// SelectRepositories returns the repositories matched by a selector. Property
+// values are keyed by lowercase repository name, as returned by
+// GetOrgPropertyValues.
+func SelectRepositories(repos []data.RepoInfo, selector RepoSelector, propertyValues map[string]map[string][]string) []data.RepoInfo {
+	var propertyPatterns *data.PropertyPatterns
+	if len(selector.Properties) > 0 {
+		propertyPatterns = &data.PropertyPatterns{}
+		for name, values := range selector.Properties {
+			propertyPatterns.Include = append(propertyPatterns.Include, data.PropertyPattern{Name: name, PropertyValues: values})
+		}
+	}
+
+	var selected []data.RepoInfo
+	for _, repo := range repos {
+		if len(selector.Patterns) > 0 && !evaluate.MatchRepositoryName(&data.NamePatterns{Include: selector.Patterns}, repo.Name) {
+			continue
+		}
+		if len(selector.Topics) > 0 && !hasAnyTopic(repo, selector.Topics) {
+			continue
+		}
+		if len(selector.Visibilities) > 0 && !containsFold(selector.Visibilities, repo.Visibility) {
+			continue
+		}
+		if propertyPatterns != nil && !evaluate.MatchRepositoryProperty(propertyPatterns, propertyValues[strings.ToLower(repo.Name)]) {
+			continue
+		}
+		selected = append(selected, repo)
+	}
+	return selected
+}
+
+func hasAnyTopic(repo data.RepoInfo, topics []string) bool {
+	for _, node := range repo.RepositoryTopics.Nodes {
+		if containsFold(topics, node.Topic.Name) {
+			return true
+		}
+	}
+	if repo.RepositoryTopics.PageInfo.HasNextPage {
+		zap.S().Warnf("Only the first %d topics of repository %s were fetched, so it may be missing from the selected repositories", len(repo.RepositoryTopics.Nodes), repo.Name)
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// RepoTemplateRuleset converts a ruleset into the request body used to create
+// it at the repository level. Repository name and property conditions only
+// apply to organization rulesets, so only ref name conditions are kept.
+func RepoTemplateRuleset(ruleset data.RepoRuleset) (data.CreateRuleset, error) {
+	createRuleset, err := ProcessRulesets(ruleset)
+	if err != nil {
+		return createRuleset, err
+	}
+	conditions := CleanConditions(createRuleset.Conditions)
+	if createRuleset.Target == "push" || conditions == nil || conditions.RefName == nil {
+		createRuleset.Conditions = nil
+	} else {
+		createRuleset.Conditions = &data.Conditions{RefName: conditions.RefName}
+	}
+	return createRuleset, nil
+}
+
+// TemplateVariables returns the variables substituted into a ruleset template
+// for a repository.
+func TemplateVariables(owner string, repo data.RepoInfo) map[string]string {
+	return map[string]string{
+		"owner":          owner,
+		"repo":           repo.Name,
+		"default_branch": repo.DefaultBranchRef.Name,
+	}
+}
+
+// ApplyTemplateVariables replaces each {{name}} in the string values of a
+// ruleset, such as its name or required workflow paths, with the value of the
+// variable. Unknown variables are reported as an error.
+func ApplyTemplateVariables(createRuleset data.CreateRuleset, variables map[string]string) (data.CreateRuleset, error) {
+	rulesetJSON, err := json.Marshal(createRuleset)
+	if err != nil {
+		return createRuleset, err
+	}
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	replacements := make([]string, 0, 2*len(variables))
+	for _, name := range names {
+		value, err := json.Marshal(variables[name])
+		if err != nil {
+			return createRuleset, err
+		}
+		replacements = append(replacements, fmt.Sprintf("{{%s}}", name), strings.Trim(string(value), `"`))
+	}
+	replaced := strings.NewReplacer(replacements...).Replace(string(rulesetJSON))
+	if start := strings.Index(replaced, "{{"); start >= 0 {
+		if end := strings.Index(replaced[start:], "}}"); end >= 0 {
+			return createRuleset, fmt.Errorf("unknown template variable %s", replaced[start:start+end+2])
+		}
+	}
+
+	var templated data.CreateRuleset
+	if err := json.Unmarshal([]byte(replaced), &templated); err != nil {
+		return createRuleset, err
+	}
+	return templated, nil
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+func newTestRepoInfo(t *testing.T, name string, visibility string, topics ...string) data.RepoInfo {
+	t.Helper()
+	nodes := make([]map[string]interface{}, len(topics))
+	for i, topic := range topics {
+		nodes[i] = map[string]interface{}{"topic": map[string]string{"name": topic}}
+	}
+	repoJSON, err := json.Marshal(map[string]interface{}{
+		"name":             name,
+		"visibility":       visibility,
+		"defaultBranchRef": map[string]string{"name": "main"},
+		"repositoryTopics": map[string]interface{}{"nodes": nodes},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var repo data.RepoInfo
+	if err := json.Unmarshal(repoJSON, &repo); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestSelectRepositories(t *testing.T) {
+	repos := []data.RepoInfo{
+		newTestRepoInfo(t, "api-service", "PRIVATE", "go", "backend"),
+		newTestRepoInfo(t, "web-app", "PUBLIC", "frontend"),
+		newTestRepoInfo(t, "api-docs", "INTERNAL"),
+	}
+	propertyValues := map[string]map[string][]string{
+		"api-service": {"team": {"platform"}},
+		"web-app":     {"team": {"web"}},
+	}
+	tests := []struct {
+		name     string
+		selector RepoSelector
+		want     []string
+	}{
+		{name: "empty selector", selector: RepoSelector{}, want: []string{"api-service", "web-app", "api-docs"}},
+		{name: "name pattern", selector: RepoSelector{Patterns: []string{"api-*"}}, want: []string{"api-service", "api-docs"}},
+		{name: "any of the topics", selector: RepoSelector{Topics: []string{"Backend", "frontend"}}, want: []string{"api-service", "web-app"}},
+		{name: "visibility", selector: RepoSelector{Visibilities: []string{"internal", "public"}}, want: []string{"web-app", "api-docs"}},
+		{name: "property", selector: RepoSelector{Properties: map[string][]string{"team": {"platform"}}}, want: []string{"api-service"}},
+		{
+			name:     "every selector must match",
+			selector: RepoSelector{Patterns: []string{"api-*"}, Visibilities: []string{"private"}, Topics: []string{"go"}},
+			want:     []string{"api-service"},
+		},
+		{
+			name:     "no repository matches",
+			selector: RepoSelector{Patterns: []string{"web-*"}, Topics: []string{"backend"}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, repo := range SelectRepositories(repos, tt.selector, propertyValues) {
+				got = append(got, repo.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectRepositories() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectRepositoriesTopicsBeyondTwenty(t *testing.T) {
+	var query string
+	topics := make([]string, 25)
+	for i := range topics {
+		topics[i] = fmt.Sprintf(`{"topic":{"name":"topic-%d"}}`, i+1)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&body) // nolint:errcheck
+		query = body.Query
+		w.Write([]byte(`{"data":{"repository":{"name":"api","repositoryTopics":{"nodes":[` + strings.Join(topics, ",") + `],"pageInfo":{"hasNextPage":false}}}}}`)) // nolint:errcheck
+	})
+	g := newTestGetter(t, mux)
+
+	repos, err := g.GatherRepositories("org", []string{"api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(query, "repositoryTopics(first: 100)") {
+		t.Errorf("query = %s, want it to fetch 100 topics", query)
+	}
+	if selected := SelectRepositories(repos, RepoSelector{Topics: []string{"topic-25"}}, nil); len(selected) != 1 {
+		t.Errorf("selected = %+v, want the repository with topic-25", selected)
+	}
+}
+
+func TestApplyTemplateVariables(t *testing.T) {
+	variables := TemplateVariables("octo-org", newTestRepoInfo(t, "api", "PRIVATE"))
+	tests := []struct {
+		name      string
+		ruleset   data.CreateRuleset
+		variables map[string]string
+		want      data.CreateRuleset
+		wantErr   string
+	}{
+		{
+			name: "name and ref conditions",
+			ruleset: data.CreateRuleset{
+				Name:       "{{repo}} protection",
+				Conditions: &data.Conditions{RefName: &data.RefPatterns{Include: []string{"refs/heads/{{default_branch}}"}, Exclude: []string{}}},
+				Rules:      []data.CreateRules{},
+			},
+			variables: variables,
+			want: data.CreateRuleset{
+				Name:       "api protection",
+				Conditions: &data.Conditions{RefName: &data.RefPatterns{Include: []string{"refs/heads/main"}, Exclude: []string{}}},
+				Rules:      []data.CreateRules{},
+			},
+		},
+		{
+			name: "required workflow path",
+			ruleset: data.CreateRuleset{
+				Name:  "workflows",
+				Rules: []data.CreateRules{{Type: "workflows", Parameters: data.Parameters{Workflows: []data.Workflows{{Path: ".github/workflows/{{owner}}-{{repo}}.yml", RepositoryID: 5}}}}},
+			},
+			variables: variables,
+			want: data.CreateRuleset{
+				Name: "workflows",
+				Rules: []data.CreateRules{{Type: "workflows", Parameters: map[string]interface{}{
+					"workflows": []interface{}{map[string]interface{}{"path": ".github/workflows/octo-org-api.yml", "repository_id": float64(5)}},
+				}}},
+			},
+		},
+		{
+			name:      "value that needs escaping",
+			ruleset:   data.CreateRuleset{Name: "{{repo}}", Rules: []data.CreateRules{}},
+			variables: map[string]string{"repo": `say "hi"\`},
+			want:      data.CreateRuleset{Name: `say "hi"\`, Rules: []data.CreateRules{}},
+		},
+		{
+			name:      "unknown variable",
+			ruleset:   data.CreateRuleset{Name: "{{repo}} for {{team}}", Rules: []data.CreateRules{}},
+			variables: variables,
+			wantErr:   "unknown template variable {{team}}",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyTemplateVariables(tt.ruleset, tt.variables)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ApplyTemplateVariables() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
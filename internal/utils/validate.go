@@ -0,0 +1,449 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+var validRulesetLevels = []string{"Organization", "Repository", "Enterprise"}
+var validTargets = []string{"branch", "tag", "push", "repository"}
+var validEnforcements = []string{"active", "evaluate", "disabled"}
+var validActorTypes = []string{"Integration", "OrganizationAdmin", "RepositoryRole", "Team", "DeployKey", "EnterpriseOwner"}
+var validBypassModes = []string{"always", "pull_request", "exempt"}
+
+// parameterEnums lists the accepted values of string parameters that only
+// accept a fixed set of values.
+var parameterEnums = map[string][]string{
+	"MergeMethod":             {"MERGE", "SQUASH", "REBASE"},
+	"GroupingStrategy":        {"ALLGREEN", "HEADGREEN"},
+	"Operator":                {"starts_with", "ends_with", "contains", "regex"},
+	"AlertsThreshold":         {"none", "errors", "errors_and_warnings", "all"},
+	"SecurityAlertsThreshold": {"none", "critical", "high_or_higher", "medium_or_higher", "all"},
+}
+
+// requiredParameters lists the parameters a rule cannot be created without.
+var requiredParameters = map[string][]string{
+	"merge_queue":                 {"CheckResponseTimeoutMinutes", "GroupingStrategy", "MaxEntriesToBuild", "MaxEntriesToMerge", "MergeMethod", "MinEntriesToMerge", "MinEntriesToMergeWaitMinutes"},
+	"required_deployments":        {"RequiredDeploymentEnvironments"},
+	"required_status_checks":      {"RequiredStatusChecks"},
+	"commit_message_pattern":      {"Operator", "Pattern"},
+	"commit_author_email_pattern": {"Operator", "Pattern"},
+	"committer_email_pattern":     {"Operator", "Pattern"},
+	"branch_name_pattern":         {"Operator", "Pattern"},
+	"tag_name_pattern":            {"Operator", "Pattern"},
+	"file_path_restriction":       {"RestrictedFilePaths"},
+	"max_file_path_length":        {"MaxFilePathLength"},
+	"file_extension_restriction":  {"RestrictedFileExtensions"},
+	"max_file_size":               {"MaxFileSize"},
+	"workflows":                   {"Workflows"},
+	"code_scanning":               {"CodeScanningTools"},
+	"repository_name":             {"Pattern"},
+}
+
+// ruleTargets lists the ruleset targets each rule can be used with.
+var ruleTargets = map[string][]string{
+	"creation":                    {"branch", "tag"},
+	"update":                      {"branch", "tag"},
+	"deletion":                    {"branch", "tag"},
+	"required_linear_history":     {"branch", "tag"},
+	"merge_queue":                 {"branch"},
+	"required_deployments":        {"branch", "tag"},
+	"required_signatures":         {"branch", "tag"},
+	"pull_request":                {"branch"},
+	"required_status_checks":      {"branch", "tag"},
+	"non_fast_forward":            {"branch", "tag"},
+	"commit_message_pattern":      {"branch", "tag"},
+	"commit_author_email_pattern": {"branch", "tag"},
+	"committer_email_pattern":     {"branch", "tag"},
+	"branch_name_pattern":         {"branch"},
+	"tag_name_pattern":            {"tag"},
+	"file_path_restriction":       {"push"},
+	"max_file_path_length":        {"push"},
+	"file_extension_restriction":  {"push"},
+	"max_file_size":               {"push"},
+	"workflows":                   {"branch"},
+	"code_scanning":               {"branch"},
+	"repository_create":           {"repository"},
+	"repository_delete":           {"repository"},
+	"repository_transfer":         {"repository"},
+	"repository_name":             {"repository"},
+	"repository_visibility":       {"repository"},
+}
+
+// requiredCSVHeaders are the columns a ruleset csv file cannot be imported
+// without. Every other column is optional and reads as empty when missing.
+var requiredCSVHeaders = []string{
+	"RulesetLevel",
+	"RulesetName",
+	"Target",
+	"Enforcement",
+}
+
+type csvValidator struct {
+	headers []string
+	columns map[string]int
+	issues  []data.ValidationIssue
+}
+
+func (v *csvValidator) report(row int, header string, severity string, format string, args ...interface{}) {
+	column := 0
+	if i, ok := v.columns[header]; ok {
+		column = i + 1
+	}
+	v.issues = append(v.issues, data.ValidationIssue{
+		Row:      row,
+		Column:   column,
+		Header:   header,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *csvValidator) value(record []string, header string) string {
+	if i, ok := v.columns[header]; ok && i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+// ValidateRulesetsCSV checks csv file data against the ruleset csv schema
+// without making any API calls, returning every issue found with the row and
+// column it was found in. Rows are numbered from 1 for the header row.
+func ValidateRulesetsCSV(fileData [][]string) []data.ValidationIssue {
+	v := &csvValidator{columns: make(map[string]int)}
+	if len(fileData) == 0 {
+		v.report(1, "", "error", "file is empty")
+		return v.issues
+	}
+
+	v.headers = fileData[0]
+	known := make(map[string]struct{}, len(data.RulesetCSVHeaders))
+	for _, header := range data.RulesetCSVHeaders {
+		known[header] = struct{}{}
+	}
+	for i, header := range v.headers {
+		if _, ok := v.columns[header]; ok {
+			v.issues = append(v.issues, data.ValidationIssue{Row: 1, Column: i + 1, Header: header, Severity: "error", Message: "duplicate column"})
+			continue
+		}
+		v.columns[header] = i
+		if _, ok := known[header]; !ok {
+			v.report(1, header, "warning", "unknown column will be ignored")
+		}
+	}
+	for _, header := range requiredCSVHeaders {
+		if _, ok := v.columns[header]; !ok {
+			v.report(1, header, "error", "missing required column")
+		}
+	}
+
+	names := make(map[string]int)
+	for i, record := range fileData[1:] {
+		row := i + 2
+		if len(record) != len(v.headers) {
+			v.report(row, "", "error", "expected %d columns but found %d", len(v.headers), len(record))
+			continue
+		}
+		record = RulesetCSVColumns(v.columns).Record(record)
+		v.validateRow(row, record)
+
+		key := strings.Join([]string{v.value(record, "RulesetLevel"), v.value(record, "RepositoryName"), v.value(record, "RulesetName")}, "/")
+		if previous, ok := names[key]; ok {
+			v.report(row, "RulesetName", "error", "duplicate ruleset name, also defined in row %d", previous)
+		} else {
+			names[key] = row
+		}
+	}
+	return v.issues
+}
+
+func (v *csvValidator) validateRow(row int, record []string) {
+	level := v.value(record, "RulesetLevel")
+	target := v.value(record, "Target")
+	v.checkEnum(row, "RulesetLevel", level, validRulesetLevels)
+	v.checkEnum(row, "Target", target, validTargets)
+	v.checkEnum(row, "Enforcement", v.value(record, "Enforcement"), validEnforcements)
+	if len(v.value(record, "RulesetName")) == 0 {
+		v.report(row, "RulesetName", "error", "ruleset name is empty")
+	}
+	if level == "Repository" && len(v.value(record, "RepositoryName")) == 0 {
+		v.report(row, "RepositoryName", "error", "repository name is required for Repository level rulesets")
+	}
+	if ruleID := v.value(record, "RuleID"); len(ruleID) > 0 {
+		if _, err := strconv.Atoi(ruleID); err != nil {
+			v.report(row, "RuleID", "error", "%q is not an integer", ruleID)
+		}
+	}
+	if version := v.value(record, "SchemaVersion"); len(version) > 0 {
+		if number, err := strconv.Atoi(version); err != nil {
+			v.report(row, "SchemaVersion", "error", "%q is not an integer", version)
+		} else if number > data.RulesetCSVSchemaVersion {
+			v.report(row, "SchemaVersion", "warning", "schema version %d is newer than the supported version %d, so new columns will be ignored", number, data.RulesetCSVSchemaVersion)
+		}
+	}
+	if protected := v.value(record, "ConditionsRepoNameProtected"); len(protected) > 0 {
+		if _, err := strconv.ParseBool(protected); err != nil {
+			v.report(row, "ConditionsRepoNameProtected", "error", "%q is not true or false", protected)
+		}
+	}
+
+	v.validateBypassActors(row, v.value(record, "BypassActors"))
+	v.validateConditions(row, record, level, target)
+	for i, header := range v.headers {
+		ruleType, ok := data.HeaderMap[header]
+		if !ok || len(record[i]) == 0 {
+			continue
+		}
+		v.validateRule(row, header, ruleType, target, record[i])
+	}
+	v.validateRawRules(row, v.value(record, "RulesRawJSON"))
+}
+
+// validateRawRules checks that the RulesRawJSON column holds a JSON array of
+// rules. Its parameters are passed through as is, so they are not checked.
+func (v *csvValidator) validateRawRules(row int, value string) {
+	if len(strings.TrimSpace(value)) == 0 {
+		return
+	}
+	rules, err := ApplyRawRules(nil, value)
+	if err != nil {
+		v.report(row, "RulesRawJSON", "error", "%q is not a JSON array of rules: %v", value, err)
+		return
+	}
+	for _, rule := range rules {
+		if len(rule.Type) == 0 {
+			v.report(row, "RulesRawJSON", "error", "every rule must have a type")
+		}
+	}
+}
+
+func (v *csvValidator) checkEnum(row int, header string, value string, valid []string) bool {
+	for _, validValue := range valid {
+		if value == validValue {
+			return true
+		}
+	}
+	v.report(row, header, "error", "%q is not one of %s", value, strings.Join(valid, ", "))
+	return false
+}
+
+func (v *csvValidator) validateBypassActors(row int, actors string) {
+	if len(actors) == 0 {
+		return
+	}
+	for _, actor := range SplitEscaped(actors, '|') {
+		actorData := SplitCSVValue(actor, ';')
+		if len(actorData) != 4 {
+			v.report(row, "BypassActors", "error", "bypass actor %q is not in the format ID;Type;Name;Mode", actor)
+			continue
+		}
+		if _, err := strconv.Atoi(actorData[0]); err != nil && actorData[1] != "DeployKey" {
+			v.report(row, "BypassActors", "error", "bypass actor %q has an ID that is not an integer", actor)
+		}
+		v.checkEnum(row, "BypassActors", actorData[1], validActorTypes)
+		v.checkEnum(row, "BypassActors", actorData[3], validBypassModes)
+	}
+}
+
+func (v *csvValidator) validateConditions(row int, record []string, level string, target string) {
+	refInclude := v.value(record, "ConditionsRefNameInclude")
+	refExclude := v.value(record, "ConditionsRefNameExclude")
+	if target == "push" || target == "repository" {
+		if len(refInclude) > 0 || len(refExclude) > 0 {
+			v.report(row, "ConditionsRefNameInclude", "warning", "ref name conditions are ignored for %s rulesets", target)
+		}
+	} else if (target == "branch" || target == "tag") && len(refInclude) == 0 {
+		v.report(row, "ConditionsRefNameInclude", "error", "at least one ref name pattern is required for %s rulesets", target)
+	}
+	if target == "tag" {
+		v.checkRefPrefix(row, "ConditionsRefNameInclude", refInclude, "refs/tags/")
+		v.checkRefPrefix(row, "ConditionsRefNameExclude", refExclude, "refs/tags/")
+	} else if target == "branch" {
+		v.checkRefPrefix(row, "ConditionsRefNameInclude", refInclude, "refs/heads/")
+		v.checkRefPrefix(row, "ConditionsRefNameExclude", refExclude, "refs/heads/")
+	}
+
+	for _, header := range []string{"ConditionRepoPropertyInclude", "ConditionRepoPropertyExclude"} {
+		properties := v.value(record, header)
+		if len(properties) == 0 {
+			continue
+		}
+		for _, property := range SplitEscaped(properties, '|') {
+			parts := SplitEscaped(property, ';')
+			if _, isGroup := trimEscapedDelimiters(parts[len(parts)-1], '{', '}'); len(parts) != 3 || !isGroup {
+				v.report(row, header, "error", "property %q is not in the format Name;Source;{Value|...}", property)
+			}
+		}
+	}
+
+	switch level {
+	case "Organization":
+		if len(v.value(record, "ConditionsRepoNameInclude")) == 0 && len(v.value(record, "ConditionRepoPropertyInclude")) == 0 {
+			v.report(row, "ConditionsRepoNameInclude", "error", "organization rulesets require a repository name or repository property condition")
+		}
+	case "Enterprise":
+		if len(v.value(record, "ConditionsOrgNameInclude")) == 0 && len(v.value(record, "ConditionsOrgID")) == 0 {
+			v.report(row, "ConditionsOrgNameInclude", "error", "enterprise rulesets require an organization name or organization ID condition")
+		}
+	}
+	if orgIDs := v.value(record, "ConditionsOrgID"); len(orgIDs) > 0 {
+		for _, org := range SplitEscaped(orgIDs, '|') {
+			if _, err := strconv.Atoi(SplitCSVValue(org, ';')[0]); err != nil {
+				v.report(row, "ConditionsOrgID", "error", "organization %q is not in the format ID;Login", org)
+			}
+		}
+	}
+}
+
+func (v *csvValidator) checkRefPrefix(row int, header string, patterns string, prefix string) {
+	if len(patterns) == 0 {
+		return
+	}
+	for _, pattern := range SplitCSVValue(patterns, ';') {
+		if pattern == "~ALL" || pattern == "~DEFAULT_BRANCH" || strings.HasPrefix(pattern, prefix) {
+			continue
+		}
+		v.report(row, header, "warning", "ref pattern %q does not start with %s and will not match any refs", pattern, prefix)
+	}
+}
+
+func (v *csvValidator) validateRule(row int, header string, ruleType string, target string, value string) {
+	if targets, ok := ruleTargets[ruleType]; ok && len(target) > 0 {
+		compatible := false
+		for _, validTarget := range targets {
+			if target == validTarget {
+				compatible = true
+			}
+		}
+		if !compatible {
+			v.report(row, header, "error", "%s rules can only be used with %s rulesets, not %s", ruleType, strings.Join(targets, " or "), target)
+		}
+	}
+
+	validFields := GetValidFields(ruleType)
+	if value == "true" {
+		if required := requiredParameters[ruleType]; len(required) > 0 {
+			v.report(row, header, "error", "%s rules require the parameters %s", ruleType, strings.Join(required, ", "))
+		}
+		return
+	}
+	if validFields == nil {
+		v.report(row, header, "error", "%q is not valid, %s rules have no parameters and must be true", value, ruleType)
+		return
+	}
+
+	parameters := make(map[string]struct{})
+	for _, pair := range SplitEscaped(value, '|') {
+		name, parameter, found := CutEscaped(pair, ':')
+		if !found {
+			v.report(row, header, "error", "parameter %q is not in the format Name:value", pair)
+			continue
+		}
+		name = UnescapeCSVValue(name)
+		subFields, ok := validFields[name]
+		if !ok {
+			v.report(row, header, "error", "%s is not a parameter of %s rules", name, ruleType)
+			continue
+		}
+		parameters[name] = struct{}{}
+		field, _ := reflect.TypeOf(data.Parameters{}).FieldByName(name)
+		v.validateParameter(row, header, name, field.Type, subFields, parameter)
+	}
+	for _, required := range requiredParameters[ruleType] {
+		if _, ok := parameters[required]; !ok {
+			v.report(row, header, "error", "%s rules require the parameter %s", ruleType, required)
+		}
+	}
+}
+
+func (v *csvValidator) validateParameter(row int, header string, name string, fieldType reflect.Type, subFields map[string]struct{}, value string) {
+	key := name[strings.LastIndex(name, ".")+1:]
+	if fieldType.Kind() != reflect.Slice {
+		value = UnescapeCSVValue(value)
+	}
+	switch fieldType.Kind() {
+	case reflect.Int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			v.report(row, header, "error", "%s value %q is not an integer", name, value)
+		} else if number < 0 {
+			v.report(row, header, "error", "%s value %d must not be negative", name, number)
+		} else if key == "RequiredApprovingReviewCount" && number > 10 {
+			v.report(row, header, "error", "%s value %d must be between 0 and 10", name, number)
+		}
+	case reflect.Bool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			v.report(row, header, "error", "%s value %q is not true or false", name, value)
+		}
+	case reflect.String:
+		if valid, ok := parameterEnums[key]; ok {
+			v.checkParameterEnum(row, header, name, value, valid)
+		}
+	case reflect.Slice:
+		if fieldType.Elem().Kind() == reflect.String {
+			if _, isList := trimEscapedDelimiters(value, '[', ']'); !isList {
+				v.report(row, header, "error", "%s value %q is not a list in the format [value ...]", name, value)
+			}
+			return
+		}
+		for _, group := range SplitEscaped(value, ';') {
+			pairs, isGroup := trimEscapedDelimiters(group, '{', '}')
+			if !isGroup {
+				v.report(row, header, "error", "%s value %q is not in the format {Name=value|...}", name, group)
+				continue
+			}
+			for _, pair := range SplitEscaped(pairs, '|') {
+				subName, subValue, found := CutEscaped(pair, '=')
+				if !found {
+					v.report(row, header, "error", "%s value %q is not in the format Name=value", name, pair)
+					continue
+				}
+				subName = UnescapeCSVValue(subName)
+				if subName == "RepositoryName" && name == "Workflows" {
+					continue
+				}
+				if _, ok := subFields[subName]; !ok {
+					v.report(row, header, "error", "%s is not a field of %s", subName, name)
+					continue
+				}
+				subField, _ := fieldType.Elem().FieldByName(subName)
+				subType := subField.Type
+				if subType.Kind() == reflect.Ptr {
+					subType = subType.Elem()
+				}
+				v.validateParameter(row, header, name+"."+subName, subType, nil, subValue)
+			}
+		}
+	}
+}
+
+func (v *csvValidator) checkParameterEnum(row int, header string, name string, value string, valid []string) {
+	for _, validValue := range valid {
+		if value == validValue {
+			return
+		}
+	}
+	v.report(row, header, "error", "%s value %q is not one of %s", name, value, strings.Join(valid, ", "))
+}
+
+// WriteValidationIssues writes validation issues to w, one per line.
+func WriteValidationIssues(fileName string, issues []data.ValidationIssue, w io.Writer) error {
+	for _, issue := range issues {
+		location := fmt.Sprintf("%s:%d", fileName, issue.Row)
+		if issue.Column > 0 {
+			location = fmt.Sprintf("%s:%d:%d", fileName, issue.Row, issue.Column)
+		}
+		if len(issue.Header) > 0 {
+			location = fmt.Sprintf("%s (%s)", location, issue.Header)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s: %s\n", location, issue.Severity, issue.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/katiem0/gh-migrate-rulesets/internal/data"
+)
+
+var validateTestHeaders = []string{
+	"RulesetLevel",
+	"RepositoryName",
+	"RulesetName",
+	"Target",
+	"Enforcement",
+	"RuleID",
+	"ConditionsRefNameInclude",
+	"ConditionsRepoNameInclude",
+	"RulesMaxFileSize",
+	"RulesPullRequest",
+	"RulesDeletion",
+	"BypassActors",
+}
+
+// validateTestRow returns a valid organization ruleset row with the given
+// columns replaced.
+func validateTestRow(name string, columns map[string]string) []string {
+	row := []string{"Organization", "", name, "branch", "active", "", "refs/heads/main", "~ALL", "", "", "true", ""}
+	for header, value := range columns {
+		for i, h := range validateTestHeaders {
+			if h == header {
+				row[i] = value
+			}
+		}
+	}
+	return row
+}
+
+func TestValidateRulesetsCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]string
+		want []data.ValidationIssue
+	}{
+		{
+			name: "valid row",
+			rows: [][]string{validateTestRow("main", nil)},
+		},
+		{
+			name: "rule ID that is not an integer",
+			rows: [][]string{validateTestRow("main", map[string]string{"RuleID": "abc"})},
+			want: []data.ValidationIssue{
+				{Row: 2, Column: 6, Header: "RuleID", Severity: "error", Message: `"abc" is not an integer`},
+			},
+		},
+		{
+			name: "rule parameter that is not an integer",
+			rows: [][]string{validateTestRow("main", map[string]string{"RulesPullRequest": "RequiredApprovingReviewCount:two"})},
+			want: []data.ValidationIssue{
+				{Row: 2, Column: 10, Header: "RulesPullRequest", Severity: "error", Message: `RequiredApprovingReviewCount value "two" is not an integer`},
+			},
+		},
+		{
+			name: "enforcement that is not valid",
+			rows: [][]string{validateTestRow("main", map[string]string{"Enforcement": "enabled"})},
+			want: []data.ValidationIssue{
+				{Row: 2, Column: 5, Header: "Enforcement", Severity: "error", Message: `"enabled" is not one of active, evaluate, disabled`},
+			},
+		},
+		{
+			name: "bypass mode that is not valid",
+			rows: [][]string{validateTestRow("main", map[string]string{"BypassActors": "5;Team;team;sometimes"})},
+			want: []data.ValidationIssue{
+				{Row: 2, Column: 12, Header: "BypassActors", Severity: "error", Message: `"sometimes" is not one of always, pull_request, exempt`},
+			},
+		},
+		{
+			name: "push rule on a branch ruleset",
+			rows: [][]string{validateTestRow("main", map[string]string{"RulesMaxFileSize": "MaxFileSize:10"})},
+			want: []data.ValidationIssue{
+				{Row: 2, Column: 9, Header: "RulesMaxFileSize", Severity: "error", Message: "max_file_size rules can only be used with push rulesets, not branch"},
+			},
+		},
+		{
+			name: "issues in a later row",
+			rows: [][]string{
+				validateTestRow("main", nil),
+				validateTestRow("release", map[string]string{"Target": "commit"}),
+			},
+			want: []data.ValidationIssue{
+				{Row: 3, Column: 4, Header: "Target", Severity: "error", Message: `"commit" is not one of branch, tag, push, repository`},
+				{Row: 3, Column: 11, Header: "RulesDeletion", Severity: "error", Message: "deletion rules can only be used with branch or tag rulesets, not commit"},
+			},
+		},
+		{
+			name: "duplicate ruleset name",
+			rows: [][]string{validateTestRow("main", nil), validateTestRow("main", nil)},
+			want: []data.ValidationIssue{
+				{Row: 3, Column: 3, Header: "RulesetName", Severity: "error", Message: "duplicate ruleset name, also defined in row 2"},
+			},
+		},
+		{
+			name: "row with missing columns",
+			rows: [][]string{{"Organization", "", "main"}},
+			want: []data.ValidationIssue{
+				{Row: 2, Severity: "error", Message: "expected 12 columns but found 3"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fileData := append([][]string{validateTestHeaders}, tt.rows...)
+			got := ValidateRulesetsCSV(fileData)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateRulesetsCSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRulesetsCSVHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileData [][]string
+		want     []data.ValidationIssue
+	}{
+		{
+			name: "empty file",
+			want: []data.ValidationIssue{{Row: 1, Severity: "error", Message: "file is empty"}},
+		},
+		{
+			name:     "missing required column",
+			fileData: [][]string{{"RulesetLevel", "RulesetName", "Target"}},
+			want:     []data.ValidationIssue{{Row: 1, Header: "Enforcement", Severity: "error", Message: "missing required column"}},
+		},
+		{
+			name:     "duplicate and unknown columns",
+			fileData: [][]string{{"RulesetLevel", "RulesetName", "Target", "Enforcement", "Target", "Owner"}},
+			want: []data.ValidationIssue{
+				{Row: 1, Column: 5, Header: "Target", Severity: "error", Message: "duplicate column"},
+				{Row: 1, Column: 6, Header: "Owner", Severity: "warning", Message: "unknown column will be ignored"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateRulesetsCSV(tt.fileData)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateRulesetsCSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteValidationIssues(t *testing.T) {
+	issues := []data.ValidationIssue{
+		{Row: 1, Severity: "error", Message: "file is empty"},
+		{Row: 3, Column: 9, Header: "RulesMaxFileSize", Severity: "error", Message: "max_file_size rules can only be used with push rulesets, not branch"},
+	}
+	var out bytes.Buffer
+	if err := WriteValidationIssues("rulesets.csv", issues, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "rulesets.csv:1: error: file is empty\n" +
+		"rulesets.csv:3:9 (RulesMaxFileSize): error: max_file_size rules can only be used with push rulesets, not branch\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
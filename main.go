@@ -4,12 +4,16 @@ import (
 	"os"
 
 	"github.com/katiem0/gh-migrate-rulesets/cmd"
+	"github.com/katiem0/gh-migrate-rulesets/internal/utils"
 )
 
 func main() {
 
 	cmd := cmd.NewCmdRoot()
-	if err := cmd.Execute(); err != nil {
+	err := cmd.Execute()
+	// Log the consumed budget even when a command fails part way through.
+	utils.LogRateLimitUsage()
+	if err != nil {
 		os.Exit(1)
 	}
 }